@@ -2,4 +2,8 @@ module github.com/elthrasher/http-lambda-invoker
 
 go 1.14
 
-require github.com/aws/aws-sdk-go v1.40.13
+require (
+	github.com/aws/aws-sdk-go v1.40.13
+	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
+	gopkg.in/yaml.v3 v3.0.1
+)
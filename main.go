@@ -0,0 +1,9 @@
+// Command http-lambda-invoker serves HTTP by invoking a Lambda function with
+// API Gateway proxy events. See package invoker for the details.
+package main
+
+import "github.com/elthrasher/http-lambda-invoker/pkg/invoker"
+
+func main() {
+	invoker.Main()
+}
@@ -0,0 +1,92 @@
+package invoker
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"text/template"
+)
+
+const combinedLogFormat = `{{.Host}} - - [{{.Time.Format "02/Jan/2006:15:04:05 -0700"}}] "{{.Method}} {{.RequestURI}} {{.Proto}}" {{.Status}} {{.BytesOrDash}} "{{.Referer}}" "{{.UserAgent}}"`
+
+// Build the access log formatter for ACCESS_LOG, which is json, combined, off
+// or a text/template. Returns nil when access logs are off.
+func newAccessLog(format string) (func(*requestSummary) []byte, error) {
+	switch format {
+	case "off":
+		return nil, nil
+	case "json":
+		return func(e *requestSummary) []byte {
+			fields := logFields{
+				"request_id":  e.RequestID,
+				"remote_addr": e.RemoteAddr,
+				"method":      e.Method,
+				"path":        e.Path,
+				"status":      e.Status,
+				"bytes":       e.Bytes,
+				"latency_ms":  e.Latency.Milliseconds(),
+			}
+			if e.CorrelationID != "" {
+				fields["correlation_id"] = e.CorrelationID
+			}
+			if e.Function != "" {
+				fields["function"] = e.Function
+			}
+			if e.Route != "" {
+				fields["route"] = e.Route
+			}
+			if e.Query != "" {
+				fields["query"] = e.Query
+			}
+			return jsonLogLine(infoLevel, "Access", fields)
+		}, nil
+	case "combined":
+		format = combinedLogFormat
+	}
+
+	tmpl, err := template.New("ACCESS_LOG").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACCESS_LOG %q: %v", format, err)
+	}
+	return func(e *requestSummary) []byte {
+		var line bytes.Buffer
+		if err := tmpl.Execute(&line, accessLogView{e}); err != nil {
+			fmt.Fprintf(&line, "invalid ACCESS_LOG: %v", err)
+		}
+		line.WriteByte('\n')
+		return line.Bytes()
+	}, nil
+}
+
+// Extra values for templates, following Apache's formats.
+type accessLogView struct {
+	*requestSummary
+}
+
+func (v accessLogView) Host() string {
+	if host, _, err := net.SplitHostPort(v.RemoteAddr); err == nil {
+		return host
+	}
+	return v.RemoteAddr
+}
+
+func (v accessLogView) RequestURI() string {
+	if v.Query == "" {
+		return v.Path
+	}
+	return v.Path + "?" + v.Query
+}
+
+func (v accessLogView) BytesOrDash() string {
+	if v.Bytes == 0 {
+		return "-"
+	}
+	return fmt.Sprint(v.Bytes)
+}
+
+// Write each request's access log line with format from newAccessLog.
+func accessLogObserver(format func(*requestSummary) []byte) func(*requestSummary) {
+	return func(s *requestSummary) {
+		defaultLogger.write(format(s))
+	}
+}
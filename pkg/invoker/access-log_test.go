@@ -0,0 +1,77 @@
+package invoker
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var out bytes.Buffer
+	defaultLogger.mu.Lock()
+	previous := defaultLogger.out
+	defaultLogger.out = &out
+	defaultLogger.mu.Unlock()
+	t.Cleanup(func() {
+		defaultLogger.mu.Lock()
+		defaultLogger.out = previous
+		defaultLogger.mu.Unlock()
+	})
+	return &out
+}
+
+func accessLogged(t *testing.T, format string) string {
+	out := captureLogs(t)
+	f, err := newAccessLog(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var observers []func(*requestSummary)
+	if f != nil {
+		observers = append(observers, accessLogObserver(f))
+	}
+	h := observeRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setRequestFunction(r, &route{Path: "/users"}, "users")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}), observers...)
+	req := httptest.NewRequest("POST", "/users?x=1", nil)
+	req.Header.Set("User-Agent", "curl/7.68.0")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return out.String()
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(accessLogged(t, "json")), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["method"] != "POST" || entry["path"] != "/users" || entry["status"] != 201.0 || entry["bytes"] != 5.0 || entry["function"] != "users" {
+		t.Errorf("unexpected access log entry %v", entry)
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Error("expected latency_ms in the access log")
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	line := accessLogged(t, "combined")
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") || !strings.HasSuffix(line, `] "POST /users?x=1 HTTP/1.1" 201 5 "" "curl/7.68.0"`+"\n") {
+		t.Errorf("unexpected combined log line %q", line)
+	}
+}
+
+func TestAccessLogTemplate(t *testing.T) {
+	if line := accessLogged(t, "{{.Method}} {{.Path}} {{.Status}} {{.Bytes}} {{.Function}}"); line != "POST /users 201 5 users\n" {
+		t.Errorf("unexpected templated log line %q", line)
+	}
+	if line := accessLogged(t, "off"); line != "" {
+		t.Errorf("expected no access log when off, got %q", line)
+	}
+	if _, err := newAccessLog("{{.Method"); err == nil {
+		t.Error("expected an error for an invalid ACCESS_LOG template")
+	}
+}
@@ -0,0 +1,154 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A route added through the admin API, which has an ID to change it by.
+// Configured routes are listed without one.
+type adminRoute struct {
+	ID int `json:"id,omitempty"`
+	*route
+}
+
+// Every route in the order they're matched in.
+func listRoutes() []adminRoute {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	list := append([]adminRoute{}, runtimeRoutes...)
+	for _, table := range []routeTable{configuredRoutes, discoveredRoutes} {
+		for _, rt := range table {
+			list = append(list, adminRoute{route: rt})
+		}
+	}
+	return list
+}
+
+// Add a route ahead of all the others, so it can override configured ones.
+func addRuntimeRoute(rt *route) adminRoute {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	added := adminRoute{nextRouteID, rt}
+	nextRouteID++
+	runtimeRoutes = append([]adminRoute{added}, runtimeRoutes...)
+	publishRoutes()
+	return added
+}
+
+// Replace the route with this ID, or remove it when rt is nil. Returns false
+// if there's no such route.
+func replaceRuntimeRoute(id int, rt *route) bool {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	for i, existing := range runtimeRoutes {
+		if existing.ID != id {
+			continue
+		}
+		if rt == nil {
+			runtimeRoutes = append(runtimeRoutes[:i:i], runtimeRoutes[i+1:]...)
+		} else {
+			runtimeRoutes = append(runtimeRoutes[:i:i], append([]adminRoute{{id, rt}}, runtimeRoutes[i+1:]...)...)
+		}
+		publishRoutes()
+		return true
+	}
+	return false
+}
+
+func findRuntimeRoute(id int) (adminRoute, bool) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	for _, rt := range runtimeRoutes {
+		if rt.ID == id {
+			return rt, true
+		}
+	}
+	return adminRoute{}, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Read a route from the request body, answering with a 400 if it's invalid.
+func readRoute(w http.ResponseWriter, r *http.Request) (*route, bool) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	rt := &route{}
+	err := decoder.Decode(rt)
+	if err == nil {
+		err = rt.compile()
+	}
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, fmt.Sprintf("Invalid route: %v", err))
+		return nil, false
+	}
+	return rt, true
+}
+
+// A REST API to list the routes and to add, update and remove routes at
+// runtime. Routes added this way are matched before configured ones, and are
+// kept when the configuration is reloaded but not when the proxy restarts.
+func routesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/routes" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, listRoutes())
+		case http.MethodPost:
+			rt, ok := readRoute(w, r)
+			if !ok {
+				return
+			}
+			added := addRuntimeRoute(rt)
+			logInfo("Added route", logFields{"id": added.ID, "method": rt.Method, "path": rt.Path, "function": rt.Function})
+			w.Header().Set("Location", fmt.Sprintf("/routes/%v", added.ID))
+			writeJSON(w, http.StatusCreated, added)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			jsonError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/routes/"))
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		rt, ok := findRuntimeRoute(id)
+		if !ok {
+			jsonError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeJSON(w, http.StatusOK, rt)
+	case http.MethodPut:
+		rt, ok := readRoute(w, r)
+		if !ok {
+			return
+		}
+		if !replaceRuntimeRoute(id, rt) {
+			jsonError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		logInfo("Updated route", logFields{"id": id, "method": rt.Method, "path": rt.Path, "function": rt.Function})
+		writeJSON(w, http.StatusOK, adminRoute{id, rt})
+	case http.MethodDelete:
+		if !replaceRuntimeRoute(id, nil) {
+			jsonError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		logInfo("Removed route", logFields{"id": id})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		jsonError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,90 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func adminRequest(t *testing.T, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	newAdminMux(false, nil).ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rr
+}
+
+func TestRoutesAPI(t *testing.T) {
+	configured := &route{Path: "/orders/{id}", Function: "orders"}
+	if err := configured.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{configured})
+	defer setRoutes(nil)
+
+	rr := adminRequest(t, "POST", "/routes", `{"path":"/orders/{id}","function":"orders-dev"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %v: %s", rr.Code, rr.Body)
+	}
+	var added struct{ ID int }
+	if err := json.Unmarshal(rr.Body.Bytes(), &added); err != nil || added.ID == 0 {
+		t.Fatalf("expected an id, got %s", rr.Body)
+	}
+	location := rr.Header().Get("Location")
+	defer replaceRuntimeRoute(added.ID, nil)
+
+	if rt, params := currentRoutes().match("GET", "/orders/42"); rt == nil || rt.Function != "orders-dev" || params["id"] != "42" {
+		t.Errorf("expected the added route to override the configured one, got %+v", rt)
+	}
+	setRoutes(routeTable{configured})
+	if rt, _ := currentRoutes().match("GET", "/orders/42"); rt == nil || rt.Function != "orders-dev" {
+		t.Errorf("expected the added route to survive a reload, got %+v", rt)
+	}
+
+	var list []map[string]interface{}
+	json.Unmarshal(adminRequest(t, "GET", "/routes", "").Body.Bytes(), &list)
+	if len(list) != 2 || list[0]["function"] != "orders-dev" || list[1]["id"] != nil {
+		t.Errorf("unexpected routes %v", list)
+	}
+
+	if rr := adminRequest(t, "PUT", location, `{"method":"POST","path":"/orders/{id}","function":"orders-v2"}`); rr.Code != http.StatusOK {
+		t.Errorf("unexpected status %v: %s", rr.Code, rr.Body)
+	}
+	if rt, _ := currentRoutes().match("POST", "/orders/42"); rt == nil || rt.Function != "orders-v2" {
+		t.Errorf("expected the updated route, got %+v", rt)
+	}
+	if !strings.Contains(adminRequest(t, "GET", location, "").Body.String(), `"orders-v2"`) {
+		t.Error("expected to get the updated route")
+	}
+
+	if rr := adminRequest(t, "DELETE", location, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %v", rr.Code)
+	}
+	if rt, _ := currentRoutes().match("POST", "/orders/42"); rt != configured {
+		t.Errorf("expected the configured route once removed, got %+v", rt)
+	}
+	if rr := adminRequest(t, "DELETE", location, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected a 404 for a removed route, got %v", rr.Code)
+	}
+}
+
+func TestRoutesAPIRejects(t *testing.T) {
+	for _, c := range []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/routes", `{"path":"orders"}`, http.StatusBadRequest},
+		{"POST", "/routes", `{"path":"/orders","fucntion":"orders"}`, http.StatusBadRequest},
+		{"POST", "/routes", `not json`, http.StatusBadRequest},
+		{"PUT", "/routes/999", `{"path":"/orders"}`, http.StatusNotFound},
+		{"GET", "/routes/abc", "", http.StatusNotFound},
+		{"PATCH", "/routes", "", http.StatusMethodNotAllowed},
+	} {
+		if rr := adminRequest(t, c.method, c.path, c.body); rr.Code != c.status {
+			t.Errorf("%v %v %v: expected %v, got %v", c.method, c.path, c.body, c.status, rr.Code)
+		}
+	}
+}
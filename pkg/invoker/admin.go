@@ -0,0 +1,45 @@
+package invoker
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+)
+
+// Handlers for the admin port. They're kept off the proxy's listeners so they
+// can't shadow a function's routes or be reached by its clients. The health
+// check is served here too, for when the listeners require client
+// certificates. A nil dashboard leaves it out.
+func newAdminMux(withPprof bool, dashboard http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, healthHandler)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/routes", routesAPI)
+	mux.HandleFunc("/routes/", routesAPI)
+	mux.HandleFunc("/openapi.json", openAPIHandler)
+	if dashboard != nil {
+		mux.Handle("/dashboard/", dashboard)
+	}
+	if withPprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// Serve the admin handlers on ADMIN_ADDRESS in the background.
+func startAdminServer(address string, handler http.Handler) (*http.Server, error) {
+	ln, err := listenConfig.Listen(context.Background(), "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_ADDRESS: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	logInfo("Admin listening", logFields{"address": ln.Addr().String()})
+	go srv.Serve(ln)
+	return srv, nil
+}
@@ -0,0 +1,46 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminPprof(t *testing.T) {
+	for _, withPprof := range []bool{true, false} {
+		rr := httptest.NewRecorder()
+		newAdminMux(withPprof, nil).ServeHTTP(rr, httptest.NewRequest("GET", "/debug/pprof/", nil))
+		if ok := rr.Code == http.StatusOK; ok != withPprof {
+			t.Errorf("pprof %v: unexpected status %v", withPprof, rr.Code)
+		}
+	}
+
+	if _, err := startAdminServer("127.0.0.1:-1", newAdminMux(false, nil)); err == nil {
+		t.Error("expected an error for an invalid ADMIN_ADDRESS")
+	}
+}
+
+func TestAdminVars(t *testing.T) {
+	before := invocationCount.Value()
+	expvarObserver(&requestSummary{Function: "users", Status: 502})
+	expvarObserver(&requestSummary{Status: 429})
+	if invocationCount.Value() != before+1 {
+		t.Errorf("expected one more invocation, got %v", invocationCount.Value()-before)
+	}
+
+	rr := httptest.NewRecorder()
+	newAdminMux(false, nil).ServeHTTP(rr, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars struct {
+		Goroutines            int            `json:"goroutines"`
+		Errors                int64          `json:"errors"`
+		InvocationsByFunction map[string]int `json:"invocationsByFunction"`
+		Memstats              struct{ NumGC uint32 }
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars.Goroutines == 0 || vars.Errors == 0 || vars.InvocationsByFunction["users"] == 0 {
+		t.Errorf("unexpected vars %+v", vars)
+	}
+}
@@ -0,0 +1,167 @@
+package invoker
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// An API served on listeners of its own, with its own routes, CORS and auth,
+// as if by a separate proxy. The rest of the settings are shared.
+type api struct {
+	Name   string `yaml:"name"`
+	Listen string `yaml:"listen"`
+
+	// Where to send requests its routes don't name a function for, and
+	// requests that match none of them.
+	Function string `yaml:"function"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+
+	// The Access-Control-Allow-Origin of its responses instead of *, or off.
+	CORS string `yaml:"cors"`
+
+	// Credentials required to reach it instead of BASIC_AUTH and AUTH_TOKEN.
+	BasicAuth string `yaml:"basicAuth"`
+	AuthToken string `yaml:"authToken"`
+
+	Routes routeTable `yaml:"routes"`
+
+	fallback    *route
+	credentials *proxyCredentials
+}
+
+// Validate an API and prepare its routes.
+func (a *api) compile() error {
+	if a.Name == "" {
+		return fmt.Errorf("api is missing a name")
+	}
+	if a.Listen == "" {
+		return fmt.Errorf("api %v is missing listen", a.Name)
+	}
+	if _, err := parseListeners(a.Listen, "", "", false); err != nil {
+		return fmt.Errorf("api %v: %v", a.Name, err)
+	}
+	for _, rt := range a.Routes {
+		if rt.Function == "" {
+			rt.Function = a.Function
+		}
+		if rt.Region == "" {
+			rt.Region = a.Region
+		}
+		if rt.Endpoint == "" {
+			rt.Endpoint = a.Endpoint
+		}
+		if err := rt.compile(); err != nil {
+			return fmt.Errorf("api %v: %v", a.Name, err)
+		}
+	}
+	creds, err := newProxyCredentials(a.BasicAuth, a.AuthToken)
+	if err != nil {
+		return fmt.Errorf("api %v: %v", a.Name, err)
+	}
+	a.credentials = creds
+	a.fallback = nil
+	if a.Function != "" {
+		a.fallback = &route{Function: a.Function, Region: a.Region, Endpoint: a.Endpoint}
+	}
+	return nil
+}
+
+// The API called name in the current CONFIG_FILE, so reloading it changes
+// the routes, CORS and auth of APIs already being served.
+func currentAPI(name string) *api {
+	if cfg := currentConfigFile(); cfg != nil {
+		for _, a := range cfg.APIs {
+			if a.Name == name {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// Handle requests to the API called name as handler does for the proxy's
+// own routes.
+func apiHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := currentAPI(name)
+		if a == nil {
+			gatewayError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		rt, pathParameters := a.Routes.match(r.Method, r.URL.Path)
+		if rt == nil {
+			rt = a.fallback
+		}
+		if rt == nil {
+			gatewayError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		c, err := lambdaClientFor(rt)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		c.invokeRoute(w, r, rt, pathParameters)
+	})
+}
+
+// The handler for the API called name, wrapped in the middlewares with its
+// own CORS and auth.
+func newAPIHandler(name string, exporter *spanExporter) (http.Handler, error) {
+	sharedAuth, err := builtinMiddleware("proxy-auth", exporter)
+	if err != nil {
+		return nil, err
+	}
+	auth := func(next http.Handler) http.Handler {
+		shared := sharedAuth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a := currentAPI(name); a != nil && a.credentials != nil {
+				requireProxyAuth(a.credentials, next).ServeHTTP(w, r)
+				return
+			}
+			shared.ServeHTTP(w, r)
+		})
+	}
+	cors := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := "*"
+			if a := currentAPI(name); a != nil && a.CORS != "" {
+				origin = a.CORS
+			}
+			if origin != "off" {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return chainMiddleware(apiHandler(name), exporter, map[string]Middleware{"cors": cors, "proxy-auth": auth})
+}
+
+// Identifies a listener by its port, or its path for Unix sockets, which is
+// the same whether it's the address listened on or that a connection came in on.
+func listenerKey(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return strconv.Itoa(tcp.Port)
+	}
+	return addr.String()
+}
+
+// Send requests that came in on the listeners of an API to its handler, and
+// the rest to next.
+func dispatchAPIs(handlers map[string]http.Handler, next http.Handler) http.Handler {
+	if len(handlers) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			if h, ok := handlers[listenerKey(addr)]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
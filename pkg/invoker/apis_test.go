@@ -0,0 +1,136 @@
+package invoker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAPIs(t *testing.T) {
+	file := writeConfigFile(t, "config*.yaml", `
+apis:
+  - name: users
+    listen: :8081
+    function: users
+    cors: https://app.example.com
+    routes:
+      - path: /users/{id}
+      - path: /admin
+        function: admin
+  - name: orders
+    listen: :8082,unix:/tmp/orders.sock
+    cors: "off"
+    routes:
+      - path: /orders
+        function: orders
+`)
+	cfg, err := readConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setConfigFile(cfg)
+	defer setConfigFile(nil)
+
+	invoked := make(chan string, 1)
+	embeddedClient = &LambdaClient{namedLambdaClient{responses: map[string]string{"users": `{"statusCode":200}`, "admin": `{"statusCode":200}`, "orders": `{"statusCode":200}`}, invoked: invoked}}
+	defer func() { embeddedClient = nil }()
+	users, err := newAPIHandler("users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := newAPIHandler("orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := dispatchAPIs(map[string]http.Handler{"8081": users, "8082": orders}, http.NotFoundHandler())
+	send := func(port int, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, c := range []struct {
+		port     int
+		path     string
+		function string
+		cors     string
+	}{
+		{8081, "/users/1", "users", "https://app.example.com"},
+		{8081, "/admin", "admin", "https://app.example.com"},
+		{8081, "/anything", "users", "https://app.example.com"},
+		{8082, "/orders", "orders", ""},
+	} {
+		rr := send(c.port, c.path)
+		if rr.Code != 200 || rr.Header().Get("Access-Control-Allow-Origin") != c.cors {
+			t.Errorf("%v %v: unexpected response %v %v", c.port, c.path, rr.Code, rr.Header())
+		}
+		if name := <-invoked; name != c.function {
+			t.Errorf("%v %v: expected %v to be invoked, got %v", c.port, c.path, c.function, name)
+		}
+	}
+	if rr := send(8082, "/users/1"); rr.Code != 404 {
+		t.Errorf("expected a 404 without a fallback function, got %v", rr.Code)
+	}
+	if rr := send(8080, "/users/1"); rr.Code != 404 {
+		t.Errorf("expected other listeners to get the proxy's own handler, got %v", rr.Code)
+	}
+
+	for _, invalid := range []string{
+		"apis:\n  - name: users\n",
+		"apis:\n  - listen: :8081\n",
+		"apis:\n  - {name: a, listen: ':8081'}\n  - {name: a, listen: ':8082'}\n",
+	} {
+		if _, err := readConfigFile(writeConfigFile(t, "config*.yaml", invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestAPIAuth(t *testing.T) {
+	os.Setenv("AUTH_TOKEN", "shared")
+	defer os.Unsetenv("AUTH_TOKEN")
+	setConfigFile(&configFile{APIs: []*api{
+		{Name: "admin", Listen: ":8081", Function: "admin", BasicAuth: "ada:lovelace"},
+		{Name: "public", Listen: ":8082", Function: "public"},
+	}})
+	defer setConfigFile(nil)
+	for _, a := range currentConfigFile().APIs {
+		if err := a.compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	embeddedClient = &LambdaClient{namedLambdaClient{responses: map[string]string{"admin": `{"statusCode":200}`, "public": `{"statusCode":200}`}}}
+	defer func() { embeddedClient = nil }()
+
+	for _, c := range []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"admin", "Basic YWRhOmxvdmVsYWNl", 200},
+		{"admin", "Bearer shared", 401},
+		{"public", "Bearer shared", 200},
+		{"public", "Basic YWRhOmxvdmVsYWNl", 401},
+	} {
+		handler, err := newAPIHandler(c.name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/anything", nil)
+		req.Header.Set("Authorization", c.authorization)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != c.status {
+			t.Errorf("%+v: got %v", c, rr.Code)
+		}
+	}
+
+	if err := (&api{Name: "bad", Listen: ":8083", BasicAuth: "ada"}).compile(); err == nil {
+		t.Error("expected an error for basicAuth without a password")
+	}
+}
@@ -0,0 +1,482 @@
+package invoker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsrequest "github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// A Lambda resolver for a field of a GraphQL type, such as getPost on Query.
+// Without a schema, returns names the type of what the function returns so
+// resolvers for its fields can be found too.
+type resolver struct {
+	Type     string `yaml:"type"`
+	Field    string `yaml:"field"`
+	Function string `yaml:"function"`
+	Returns  string `yaml:"returns"`
+}
+
+func (res *resolver) compile() error {
+	if res.Type == "" || res.Field == "" {
+		return fmt.Errorf("resolver is missing a type or field")
+	}
+	return nil
+}
+
+// The event AppSync sends a direct Lambda resolver.
+type appSyncEvent struct {
+	Arguments map[string]interface{} `json:"arguments"`
+	Identity  interface{}            `json:"identity"`
+	Source    interface{}            `json:"source"`
+	Request   appSyncRequest         `json:"request"`
+	Prev      interface{}            `json:"prev"`
+	Info      appSyncInfo            `json:"info"`
+	Stash     map[string]interface{} `json:"stash"`
+}
+
+type appSyncRequest struct {
+	Headers    map[string]string `json:"headers"`
+	DomainName *string           `json:"domainName"`
+}
+
+type appSyncInfo struct {
+	SelectionSetList    []string               `json:"selectionSetList"`
+	SelectionSetGraphQL string                 `json:"selectionSetGraphQL"`
+	ParentTypeName      string                 `json:"parentTypeName"`
+	FieldName           string                 `json:"fieldName"`
+	Variables           map[string]interface{} `json:"variables"`
+}
+
+// An error in a GraphQL response, in AppSync's format.
+type graphqlError struct {
+	Path      []interface{}     `json:"path,omitempty"`
+	Data      interface{}       `json:"data"`
+	ErrorType string            `json:"errorType"`
+	ErrorInfo interface{}       `json:"errorInfo"`
+	Locations []graphqlLocation `json:"locations,omitempty"`
+	Message   string            `json:"message"`
+}
+
+type graphqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// A JSON object that keeps its fields in the order they were selected.
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Everything resolving one GraphQL request needs.
+type graphqlExecution struct {
+	ctx       context.Context
+	client    *LambdaClient
+	doc       *gqlDocument
+	variables map[string]interface{}
+	identity  interface{}
+	headers   map[string]string
+	resolvers map[string]*resolver
+	trace     string
+	errors    []graphqlError
+}
+
+// Answer a GraphQL POST at GRAPHQL_PATH as AppSync would, invoking the
+// function for each field CONFIG_FILE has a resolver for with an AppSync
+// resolver event. Other fields are taken from the object their parent
+// resolved to.
+func (c *LambdaClient) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		gatewayError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	var request struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	maxRequestSize, err := getConfigInt("MAX_REQUEST_SIZE")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	body, err := readBody(w, r, int64(maxRequestSize))
+	if err != nil {
+		if err == errRequestTooLarge {
+			gatewayError(w, http.StatusRequestEntityTooLarge, "Request Too Long")
+			return
+		}
+		writeGraphQL(w, http.StatusBadRequest, nil, []graphqlError{{ErrorType: "MalformedHttpRequestException", Message: "Request body could not be read"}})
+		return
+	}
+	defer putBuffer(body)
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		writeGraphQL(w, http.StatusBadRequest, nil, []graphqlError{{ErrorType: "MalformedHttpRequestException", Message: "Invalid JSON in request body"}})
+		return
+	}
+	doc, err := parseGraphQL(request.Query)
+	var op *gqlOperation
+	if err == nil {
+		op, err = doc.operation(request.OperationName)
+	}
+	if err != nil {
+		writeGraphQL(w, http.StatusBadRequest, nil, []graphqlError{{ErrorType: "GraphQLParserError", Message: err.Error()}})
+		return
+	}
+	if op.kind == "subscription" {
+		writeGraphQL(w, http.StatusBadRequest, nil, []graphqlError{{ErrorType: "UnsupportedOperation", Message: "Subscriptions are not supported"}})
+		return
+	}
+
+	// Every resolver has to finish within the timeout, as the whole request
+	// does in AppSync.
+	timeout, err := getConfigDuration("INTEGRATION_TIMEOUT")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	jwtIdentity, err := getConfigBool("GRAPHQL_JWT_IDENTITY")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	e := &graphqlExecution{
+		ctx:       ctx,
+		client:    c,
+		doc:       doc,
+		variables: request.Variables,
+		headers:   make(map[string]string),
+		resolvers: make(map[string]*resolver),
+		trace:     traceHeader(r),
+	}
+	if jwtIdentity {
+		e.identity = requestIdentity(r)
+	}
+	if e.variables == nil {
+		e.variables = make(map[string]interface{})
+	}
+	for name, value := range op.defaults {
+		if _, ok := e.variables[name]; !ok {
+			e.variables[name] = value
+		}
+	}
+	for key, values := range r.Header {
+		e.headers[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+	if cfg := currentConfigFile(); cfg != nil {
+		for _, res := range cfg.Resolvers {
+			e.resolvers[res.Type+"."+res.Field] = res
+		}
+	}
+	rootType := "Query"
+	if op.kind == "mutation" {
+		rootType = "Mutation"
+	}
+	data := e.resolveObject(rootType, nil, op.selections, nil)
+	writeGraphQL(w, http.StatusOK, data, e.errors)
+}
+
+func writeGraphQL(w http.ResponseWriter, status int, data interface{}, errors []graphqlError) {
+	response := map[string]interface{}{"data": data}
+	if errors != nil {
+		response["errors"] = errors
+	}
+	body, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// The operation to run: the one named, or the only one.
+func (doc *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %v", name)
+}
+
+// Resolve the fields selected on an object of typeName, whose own value is
+// source.
+func (e *graphqlExecution) resolveObject(typeName string, source map[string]interface{}, selections []*gqlSelection, path []interface{}) *orderedObject {
+	if typename, ok := source["__typename"].(string); ok {
+		typeName = typename
+	}
+	result := &orderedObject{values: make(map[string]interface{})}
+	for _, field := range e.collectFields(typeName, selections) {
+		key := field.name
+		if field.alias != "" {
+			key = field.alias
+		}
+		fieldPath := append(append([]interface{}{}, path...), key)
+		if field.name == "__typename" {
+			result.set(key, typeName)
+			continue
+		}
+		res := e.resolvers[typeName+"."+field.name]
+		var value interface{}
+		if res != nil {
+			var err *graphqlError
+			if value, err = e.invokeResolver(res, typeName, source, field); err != nil {
+				err.Path = fieldPath
+				err.Locations = []graphqlLocation{{field.line, field.column}}
+				e.errors = append(e.errors, *err)
+			}
+		} else if source != nil {
+			value = source[field.name]
+		} else {
+			e.errors = append(e.errors, graphqlError{
+				Path:      fieldPath,
+				ErrorType: "FieldUndefined",
+				Locations: []graphqlLocation{{field.line, field.column}},
+				Message:   fmt.Sprintf("Validation error of type FieldUndefined: Field '%v' in type '%v' is undefined", field.name, typeName),
+			})
+		}
+		var returns string
+		if res != nil {
+			returns = res.Returns
+		}
+		result.set(key, e.complete(value, returns, field.selections, fieldPath))
+	}
+	return result
+}
+
+// Select the fields asked for from a resolved value, or from each item of a
+// list.
+func (e *graphqlExecution) complete(value interface{}, typeName string, selections []*gqlSelection, path []interface{}) interface{} {
+	if len(selections) == 0 || value == nil {
+		return value
+	}
+	switch value := value.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = e.complete(item, typeName, selections, append(append([]interface{}{}, path...), i))
+		}
+		return items
+	case map[string]interface{}:
+		return e.resolveObject(typeName, value, selections, path)
+	}
+	return value
+}
+
+// The fields selected on typeName, with fragments expanded and fields left
+// out by @include or @skip dropped. Fragments on other types are left out
+// too, unless the type isn't known.
+func (e *graphqlExecution) collectFields(typeName string, selections []*gqlSelection) []*gqlSelection {
+	var fields []*gqlSelection
+	for _, selection := range selections {
+		if !e.included(selection.directives) {
+			continue
+		}
+		switch {
+		case selection.fragment != "":
+			fragment, ok := e.doc.fragments[selection.fragment]
+			if ok && e.included(fragment.directives) && (typeName == "" || fragment.typeCondition == typeName) {
+				fields = append(fields, e.collectFields(typeName, fragment.selections)...)
+			}
+		case selection.name == "":
+			if selection.typeCondition == "" || typeName == "" || selection.typeCondition == typeName {
+				fields = append(fields, e.collectFields(typeName, selection.selections)...)
+			}
+		default:
+			fields = append(fields, selection)
+		}
+	}
+	return fields
+}
+
+func (e *graphqlExecution) included(directives []gqlDirective) bool {
+	for _, directive := range directives {
+		condition, _ := e.value(directive.arguments["if"]).(bool)
+		if (directive.name == "include" && !condition) || (directive.name == "skip" && condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// An argument's value, with variables replaced.
+func (e *graphqlExecution) value(value interface{}) interface{} {
+	switch value := value.(type) {
+	case gqlVariable:
+		return e.variables[string(value)]
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = e.value(item)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			object[key] = e.value(item)
+		}
+		return object
+	}
+	return value
+}
+
+// Invoke a resolver's function for a field, returning what it resolved to or
+// the error AppSync would report.
+func (e *graphqlExecution) invokeResolver(res *resolver, typeName string, source map[string]interface{}, field *gqlSelection) (interface{}, *graphqlError) {
+	event := appSyncEvent{
+		Arguments: e.value(field.arguments).(map[string]interface{}),
+		Identity:  e.identity,
+		Request:   appSyncRequest{Headers: e.headers},
+		Info: appSyncInfo{
+			SelectionSetList:    e.selectionSetList(res.Returns, field.selections, ""),
+			SelectionSetGraphQL: field.selectionText,
+			ParentTypeName:      typeName,
+			FieldName:           field.name,
+			Variables:           e.variables,
+		},
+		Stash: map[string]interface{}{},
+	}
+	if source != nil {
+		event.Source = source
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, &graphqlError{ErrorType: "Lambda:Unhandled", Message: err.Error()}
+	}
+	function := res.Function
+	if function == "" {
+		function = getConfig("LAMBDA_NAME")
+	}
+	fields := logFields{"function": function, "type": typeName, "field": field.name}
+	debugPayload("Invoking resolver", payload, fields)
+	result, err := e.client.InvokeWithContext(e.ctx, &lambda.InvokeInput{FunctionName: aws.String(function), Payload: payload},
+		awsrequest.WithSetRequestHeaders(map[string]string{traceHeaderName: e.trace}))
+	if err != nil {
+		fields["error"] = err
+		logError("Invocation failed", fields)
+		return nil, &graphqlError{ErrorType: "Lambda:Unhandled", Message: err.Error()}
+	}
+	debugPayload("Resolver returned", result.Payload, fields)
+	if result.FunctionError != nil {
+		var functionError struct {
+			ErrorMessage string `json:"errorMessage"`
+			ErrorType    string `json:"errorType"`
+		}
+		json.Unmarshal(result.Payload, &functionError)
+		if functionError.ErrorType == "" {
+			functionError.ErrorType = "Lambda:Unhandled"
+		}
+		return nil, &graphqlError{ErrorType: functionError.ErrorType, Message: functionError.ErrorMessage}
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(result.Payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &graphqlError{ErrorType: "Lambda:Unhandled", Message: "Invalid JSON returned by the function"}
+	}
+	return value, nil
+}
+
+// The fields selected below a resolver's field, as paths such as
+// "author/name", for info.selectionSetList.
+func (e *graphqlExecution) selectionSetList(typeName string, selections []*gqlSelection, prefix string) []string {
+	list := []string{}
+	for _, field := range e.collectFields(typeName, selections) {
+		name := field.name
+		if field.alias != "" {
+			name = field.alias
+		}
+		list = append(list, prefix+name)
+		if len(field.selections) > 0 {
+			list = append(list, e.selectionSetList("", field.selections, prefix+name+"/")...)
+		}
+	}
+	return list
+}
+
+// The caller's identity for resolver events with GRAPHQL_JWT_IDENTITY. The
+// claims of a bearer JWT, as from a Cognito user pool or OIDC provider, are
+// passed on without being verified, so anyone can claim to be anyone.
+// Other requests, such as those with an API key, have none.
+func requestIdentity(r *http.Request) interface{} {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+	var claims map[string]interface{}
+	if json.Unmarshal(data, &claims) != nil {
+		return nil
+	}
+	sourceIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(sourceIP); err == nil {
+		sourceIP = host
+	}
+	username, _ := claims["cognito:username"].(string)
+	if username == "" {
+		username, _ = claims["username"].(string)
+	}
+	groups := claims["cognito:groups"]
+	return map[string]interface{}{
+		"sub":                 claims["sub"],
+		"issuer":              claims["iss"],
+		"username":            username,
+		"claims":              claims,
+		"sourceIp":            []string{sourceIP},
+		"defaultAuthStrategy": "ALLOW",
+		"groups":              groups,
+	}
+}
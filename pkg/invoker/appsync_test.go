@@ -0,0 +1,164 @@
+package invoker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// Answers each resolver's function with its response, keeping the events.
+type resolverLambdaClient struct {
+	lambdaiface.LambdaAPI
+	responses map[string]lambda.InvokeOutput
+	events    map[string]appSyncEvent
+}
+
+func (m resolverLambdaClient) InvokeWithContext(_ aws.Context, in *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	var event appSyncEvent
+	json.Unmarshal(in.Payload, &event)
+	m.events[name] = event
+	response := m.responses[name]
+	return &response, nil
+}
+
+func TestGraphQLResolvers(t *testing.T) {
+	os.Setenv("GRAPHQL_PATH", "/graphql")
+	defer os.Unsetenv("GRAPHQL_PATH")
+	os.Setenv("GRAPHQL_JWT_IDENTITY", "true")
+	defer os.Unsetenv("GRAPHQL_JWT_IDENTITY")
+	setConfigFile(&configFile{Resolvers: []*resolver{
+		{Type: "Query", Field: "getPost", Function: "getPost", Returns: "Post"},
+		{Type: "Post", Field: "author", Function: "getAuthor"},
+	}})
+	defer setConfigFile(nil)
+	if err := validateConfig(); err != nil {
+		t.Errorf("expected no need for LAMBDA_NAME when every resolver names its function: %v", err)
+	}
+
+	client := resolverLambdaClient{
+		responses: map[string]lambda.InvokeOutput{
+			"getPost":   {Payload: []byte(`{"id":"1","title":"Hello","authorId":"7","views":12}`)},
+			"getAuthor": {Payload: []byte(`{"name":"Ada"}`)},
+		},
+		events: make(map[string]appSyncEvent),
+	}
+	c := LambdaClient{client}
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"abc","cognito:username":"ada"}`))
+	query := `query Post($id: ID!) {
+		post: getPost(id: $id) { id ...details author { name } hidden @skip(if: true) }
+	}
+	fragment details on Post { title }`
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": map[string]string{"id": "1"}})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", "Bearer header."+claims+".signature")
+	rr := httptest.NewRecorder()
+	c.serveGraphQL(rr, req)
+
+	expected := `{"data":{"post":{"id":"1","title":"Hello","author":{"name":"Ada"}}}}`
+	if rr.Code != 200 || rr.Body.String() != expected {
+		t.Errorf("unexpected response %v %v", rr.Code, rr.Body.String())
+	}
+	event := client.events["getPost"]
+	if event.Arguments["id"] != "1" || event.Info.FieldName != "getPost" || event.Info.ParentTypeName != "Query" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if strings.Join(event.Info.SelectionSetList, ",") != "id,title,author,author/name" {
+		t.Errorf("unexpected selectionSetList %v", event.Info.SelectionSetList)
+	}
+	identity, _ := event.Identity.(map[string]interface{})
+	if identity["sub"] != "abc" || identity["username"] != "ada" {
+		t.Errorf("expected the bearer token's claims as the identity, got %v", event.Identity)
+	}
+	event = client.events["getAuthor"]
+	if source, _ := event.Source.(map[string]interface{}); source["authorId"] != "7" || event.Info.ParentTypeName != "Post" {
+		t.Errorf("expected the post as the author's source, got %+v", event)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	setConfigFile(&configFile{Resolvers: []*resolver{
+		{Type: "Query", Field: "getPost", Function: "getPost"},
+	}})
+	defer setConfigFile(nil)
+	client := resolverLambdaClient{
+		responses: map[string]lambda.InvokeOutput{
+			"getPost": {FunctionError: aws.String("Unhandled"), Payload: []byte(`{"errorType":"NotFound","errorMessage":"no such post"}`)},
+		},
+		events: make(map[string]appSyncEvent),
+	}
+	c := LambdaClient{client}
+
+	for _, e := range []struct {
+		body     string
+		status   int
+		expected string
+	}{
+		{`{"query":"{ getPost { id } }"}`, 200, `{"data":{"getPost":null},"errors":[{"path":["getPost"],"data":null,"errorType":"NotFound","errorInfo":null,"locations":[{"line":1,"column":3}],"message":"no such post"}]}`},
+		{`{"query":"{ getPost { id }"}`, 400, `"errorType":"GraphQLParserError"`},
+		{`{"query":"subscription { onPost { id } }"}`, 400, `"errorType":"UnsupportedOperation"`},
+		{`{"query":"{ listPosts { id } }"}`, 200, `"errorType":"FieldUndefined"`},
+	} {
+		rr := httptest.NewRecorder()
+		c.serveGraphQL(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(e.body)))
+		if rr.Code != e.status || !strings.Contains(rr.Body.String(), e.expected) {
+			t.Errorf("%v: unexpected response %v %v", e.body, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	c.serveGraphQL(rr, httptest.NewRequest("GET", "/graphql", nil))
+	if rr.Code != 405 {
+		t.Errorf("expected a 405 for GET, got %v", rr.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ getPost { id } }"}`))
+	req.Header.Set("Authorization", "Bearer header."+base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"abc"}`))+".signature")
+	c.serveGraphQL(httptest.NewRecorder(), req)
+	if identity := client.events["getPost"].Identity; identity != nil {
+		t.Errorf("expected no identity without GRAPHQL_JWT_IDENTITY, got %v", identity)
+	}
+
+	os.Setenv("INTEGRATION_TIMEOUT", "soon")
+	defer os.Unsetenv("INTEGRATION_TIMEOUT")
+	rr = httptest.NewRecorder()
+	c.serveGraphQL(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ getPost { id } }"}`)))
+	if rr.Code != 500 || rr.Body.String() != `{"message":"Internal server error"}` {
+		t.Errorf("expected a 500 for an invalid setting, got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`mutation Add($input: PostInput = {title: "Hi", tags: ["a"]}) {
+		addPost(input: $input, draft: false, note: """multi
+		line""") { id }
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.operations[0]
+	if op.kind != "mutation" || op.name != "Add" || len(op.selections) != 1 {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	field := op.selections[0]
+	if field.name != "addPost" || field.arguments["draft"] != false || field.arguments["input"] != gqlVariable("input") {
+		t.Errorf("unexpected field %+v", field)
+	}
+	if defaults, _ := op.defaults["input"].(map[string]interface{}); defaults["title"] != "Hi" {
+		t.Errorf("unexpected defaults %v", op.defaults)
+	}
+
+	for _, query := range []string{"{ a(b: ) }", "query { a", "fragment f on T { a }", `{ a(b: "unterminated) }`} {
+		if _, err := parseGraphQL(query); err == nil {
+			t.Errorf("expected an error for %q", query)
+		}
+	}
+}
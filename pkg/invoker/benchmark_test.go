@@ -0,0 +1,25 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func BenchmarkInvokeLambda(b *testing.B) {
+	body := strings.Repeat(`{"prop":"value"},`, 1000)
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{
+		Payload: []byte(`{"Body":"{\"hasPayload\":true}","Headers":{"content-type":"application/json"},"StatusCode":200}`),
+	}}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("POST", "/post?a=1&b=2", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "benchmark")
+		l.invokeLambda(httptest.NewRecorder(), req)
+	}
+}
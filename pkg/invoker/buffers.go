@@ -0,0 +1,44 @@
+package invoker
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Buffers bigger than this are left for the garbage collector rather than
+// pinning a large upload's worth of memory in the pool.
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+var proxyHeaderPool = sync.Pool{
+	New: func() interface{} { return make(proxyHeader) },
+}
+
+// Get an empty buffer for reading bodies or marshaling payloads.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// Return a buffer once nothing refers to its contents any more.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+func getProxyHeaders() proxyHeader {
+	return proxyHeaderPool.Get().(proxyHeader)
+}
+
+// Return a header map once the event using it has been marshaled.
+func putProxyHeaders(headers proxyHeader) {
+	for key := range headers {
+		delete(headers, key)
+	}
+	proxyHeaderPool.Put(headers)
+}
@@ -0,0 +1,44 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanaryRoutes(t *testing.T) {
+	rt := &route{Path: "/users", Function: "users:stable", CanaryFunction: "users:canary", CanaryWeight: 100}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+	invoked := make(chan string, 1)
+	c := LambdaClient{namedLambdaClient{responses: map[string]string{"users:canary": `{"statusCode":200}`}, invoked: invoked}}
+	c.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	if name := <-invoked; name != "users:canary" {
+		t.Errorf("expected the canary to be invoked, got %v", name)
+	}
+
+	rt.CanaryWeight = 30
+	canaries := 0
+	for i := 0; i < 2000; i++ {
+		if rt.pickCanary() {
+			canaries++
+		}
+	}
+	if canaries < 450 || canaries > 750 {
+		t.Errorf("expected about 30%% of requests to go to the canary, got %v of 2000", canaries)
+	}
+	if (&route{Path: "/users", CanaryFunction: "users:canary"}).pickCanary() {
+		t.Error("expected no requests to go to a canary without a weight")
+	}
+
+	for _, invalid := range []*route{
+		{Path: "/users", CanaryFunction: "users:canary", CanaryWeight: 101},
+		{Path: "/users", CanaryWeight: 10},
+	} {
+		if err := invalid.compile(); err == nil {
+			t.Errorf("expected an error for %+v", invalid)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type cfnResource struct {
+	Type       string                 `yaml:"Type"`
+	Properties map[string]interface{} `yaml:"Properties"`
+}
+
+// Read routes from the API Gateway resources in a CDK cloud assembly, either
+// a cdk.out directory, whose manifest lists the stacks, or one synthesized
+// template. Both REST APIs and HTTP APIs are read, and only Lambda proxy
+// integrations become routes.
+func loadCDKRoutes(path string) (routeTable, error) {
+	if path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	templates := []string{path}
+	if info.IsDir() {
+		if templates, err = cloudAssemblyTemplates(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var table routeTable
+	for _, file := range templates {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var template struct {
+			Resources map[string]cfnResource `yaml:"Resources"`
+		}
+		if err := yaml.Unmarshal(data, &template); err != nil {
+			return nil, fmt.Errorf("invalid CloudFormation template %v: %v", file, err)
+		}
+		routes, err := cfnRoutes(template.Resources)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CloudFormation template %v: %v", file, err)
+		}
+		table = append(table, routes...)
+	}
+	sortRoutes(table)
+	return table, nil
+}
+
+// The templates of the stacks in a cloud assembly's manifest.json.
+func cloudAssemblyTemplates(dir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud assembly %v: %v", dir, err)
+	}
+	var manifest struct {
+		Artifacts map[string]struct {
+			Type       string `json:"type"`
+			Properties struct {
+				TemplateFile string `json:"templateFile"`
+			} `json:"properties"`
+		} `json:"artifacts"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid cloud assembly %v: %v", dir, err)
+	}
+	var templates []string
+	for _, artifact := range manifest.Artifacts {
+		if artifact.Type == "aws:cloudformation:stack" && artifact.Properties.TemplateFile != "" {
+			templates = append(templates, filepath.Join(dir, artifact.Properties.TemplateFile))
+		}
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("invalid cloud assembly %v: no stacks", dir)
+	}
+	return templates, nil
+}
+
+// Routes for the API Gateway methods and HTTP API routes in a template.
+func cfnRoutes(resources map[string]cfnResource) (routeTable, error) {
+	var table routeTable
+	add := func(method string, path string, function string, timeout interface{}, version string) error {
+		if method == "ANY" {
+			method = ""
+		}
+		rt := &route{Method: method, Path: path, Function: function, PayloadFormatVersion: version}
+		if millis, ok := timeout.(int); ok {
+			rt.Timeout = (time.Duration(millis) * time.Millisecond).String()
+		}
+		if err := rt.compile(); err != nil {
+			return err
+		}
+		table = append(table, rt)
+		return nil
+	}
+
+	for _, resource := range resources {
+		switch resource.Type {
+		case "AWS::ApiGateway::Method":
+			integration, _ := resource.Properties["Integration"].(map[string]interface{})
+			if integration["Type"] != "AWS_PROXY" {
+				continue
+			}
+			function := cfnFunction(integration["Uri"], resources)
+			if function == "" {
+				continue
+			}
+			method, _ := resource.Properties["HttpMethod"].(string)
+			path := cfnResourcePath(resource.Properties["ResourceId"], resources, 0)
+			if err := add(method, path, function, integration["TimeoutInMillis"], ""); err != nil {
+				return nil, err
+			}
+
+		case "AWS::ApiGatewayV2::Route":
+			target, _ := cfnReferences(resource.Properties["Target"], resources, "AWS::ApiGatewayV2::Integration")
+			integration := resources[target]
+			if integration.Properties["IntegrationType"] != "AWS_PROXY" {
+				continue
+			}
+			function := cfnFunction(integration.Properties["IntegrationUri"], resources)
+			if function == "" {
+				continue
+			}
+			key, _ := resource.Properties["RouteKey"].(string)
+			method, path := "ANY", "/{proxy+}"
+			if key != "$default" {
+				parts := strings.Fields(key)
+				if len(parts) != 2 {
+					return nil, fmt.Errorf("invalid RouteKey %q", key)
+				}
+				method, path = parts[0], parts[1]
+			}
+			version, _ := integration.Properties["PayloadFormatVersion"].(string)
+			if err := add(method, path, function, integration.Properties["TimeoutInMillis"], version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return table, nil
+}
+
+// The path of an AWS::ApiGateway::Resource, built from its PathPart and
+// those of its parents up to the API's root resource.
+func cfnResourcePath(id interface{}, resources map[string]cfnResource, depth int) string {
+	name, ok := cfnReferences(id, resources, "AWS::ApiGateway::Resource")
+	if !ok || depth > 64 {
+		return "/"
+	}
+	resource := resources[name]
+	part, _ := resource.Properties["PathPart"].(string)
+	return strings.TrimSuffix(cfnResourcePath(resource.Properties["ParentId"], resources, depth+1), "/") + "/" + part
+}
+
+// The name of the Lambda function a value such as an integration URI refers
+// to: its FunctionName if it sets a literal one, or else its logical ID, which
+// is the name `sam local start-lambda` gives it. Aliases lead to their
+// function.
+func cfnFunction(value interface{}, resources map[string]cfnResource) string {
+	name, ok := cfnReferences(value, resources, "AWS::Lambda::Function", "AWS::Lambda::Alias")
+	if !ok {
+		return ""
+	}
+	if resources[name].Type == "AWS::Lambda::Alias" {
+		if name, ok = cfnReferences(resources[name].Properties["FunctionName"], resources, "AWS::Lambda::Function"); !ok {
+			return ""
+		}
+	}
+	if function, ok := resources[name].Properties["FunctionName"].(string); ok {
+		return function
+	}
+	return name
+}
+
+// Find the first resource of one of the types that a value refers to with
+// Ref or Fn::GetAtt, looking inside Fn::Join and other functions too.
+func cfnReferences(value interface{}, resources map[string]cfnResource, types ...string) (string, bool) {
+	isWanted := func(name string) bool {
+		for _, t := range types {
+			if resources[name].Type == t {
+				return true
+			}
+		}
+		return false
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := value["Ref"].(string); ok && isWanted(ref) {
+			return ref, true
+		}
+		if getAtt, ok := value["Fn::GetAtt"].([]interface{}); ok && len(getAtt) > 0 {
+			if name, ok := getAtt[0].(string); ok && isWanted(name) {
+				return name, true
+			}
+		}
+		for _, nested := range value {
+			if name, ok := cfnReferences(nested, resources, types...); ok {
+				return name, true
+			}
+		}
+	case []interface{}:
+		for _, nested := range value {
+			if name, ok := cfnReferences(nested, resources, types...); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,104 @@
+package invoker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCDKTemplate = `{
+  "Resources": {
+    "Api": {"Type": "AWS::ApiGateway::RestApi"},
+    "ApiUsers": {
+      "Type": "AWS::ApiGateway::Resource",
+      "Properties": {"ParentId": {"Fn::GetAtt": ["Api", "RootResourceId"]}, "PathPart": "users"}
+    },
+    "ApiUsersId": {
+      "Type": "AWS::ApiGateway::Resource",
+      "Properties": {"ParentId": {"Ref": "ApiUsers"}, "PathPart": "{id}"}
+    },
+    "ApiUsersIdGET": {
+      "Type": "AWS::ApiGateway::Method",
+      "Properties": {
+        "HttpMethod": "GET",
+        "ResourceId": {"Ref": "ApiUsersId"},
+        "Integration": {
+          "Type": "AWS_PROXY",
+          "TimeoutInMillis": 3000,
+          "Uri": {"Fn::Join": ["", ["arn:", {"Ref": "AWS::Partition"}, ":apigateway:", {"Ref": "AWS::Region"}, ":lambda:path/2015-03-31/functions/", {"Fn::GetAtt": ["UsersHandler1A2B3C4D", "Arn"]}, "/invocations"]]}
+        }
+      }
+    },
+    "ApiUsersIdOPTIONS": {
+      "Type": "AWS::ApiGateway::Method",
+      "Properties": {"HttpMethod": "OPTIONS", "ResourceId": {"Ref": "ApiUsersId"}, "Integration": {"Type": "MOCK"}}
+    },
+    "ApiRootANY": {
+      "Type": "AWS::ApiGateway::Method",
+      "Properties": {
+        "HttpMethod": "ANY",
+        "ResourceId": {"Fn::GetAtt": ["Api", "RootResourceId"]},
+        "Integration": {"Type": "AWS_PROXY", "Uri": {"Fn::Join": ["", ["arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/", {"Ref": "HomeAlias"}, "/invocations"]]}}
+      }
+    },
+    "UsersHandler1A2B3C4D": {"Type": "AWS::Lambda::Function", "Properties": {}},
+    "HomeHandler": {"Type": "AWS::Lambda::Function", "Properties": {"FunctionName": "home"}},
+    "HomeAlias": {"Type": "AWS::Lambda::Alias", "Properties": {"FunctionName": {"Ref": "HomeHandler"}, "Name": "live"}},
+    "HttpApiOrders": {
+      "Type": "AWS::ApiGatewayV2::Route",
+      "Properties": {"RouteKey": "POST /orders/{proxy+}", "Target": {"Fn::Join": ["", ["integrations/", {"Ref": "OrdersIntegration"}]]}}
+    },
+    "OrdersIntegration": {
+      "Type": "AWS::ApiGatewayV2::Integration",
+      "Properties": {"IntegrationType": "AWS_PROXY", "IntegrationUri": {"Fn::GetAtt": ["OrdersHandler", "Arn"]}, "PayloadFormatVersion": "2.0"}
+    },
+    "OrdersHandler": {"Type": "AWS::Lambda::Function"}
+  }
+}`
+
+func TestLoadCDKRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cdk.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "MyStack.template.json"), []byte(testCDKTemplate), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"version":"17.0.0","artifacts":{
+		"Tree": {"type": "cdk:tree", "properties": {"file": "tree.json"}},
+		"MyStack": {"type": "aws:cloudformation:stack", "properties": {"templateFile": "MyStack.template.json"}}
+	}}`), 0644)
+
+	for _, path := range []string{dir, filepath.Join(dir, "MyStack.template.json")} {
+		table, err := loadCDKRoutes(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(table) != 3 {
+			t.Fatalf("%v: expected 3 routes, got %v", path, len(table))
+		}
+		for _, c := range []struct {
+			method   string
+			path     string
+			function string
+			timeout  string
+			version  string
+		}{
+			{"GET", "/users/42", "UsersHandler1A2B3C4D", "3s", ""},
+			{"DELETE", "/", "home", "", ""},
+			{"POST", "/orders/1/items", "OrdersHandler", "", "2.0"},
+		} {
+			rt, _ := table.match(c.method, c.path)
+			if rt == nil || rt.Function != c.function || rt.Timeout != c.timeout || rt.PayloadFormatVersion != c.version {
+				t.Errorf("%v %v: unexpected route %+v", c.method, c.path, rt)
+			}
+		}
+		if rt, _ := table.match("OPTIONS", "/users/42"); rt != nil {
+			t.Errorf("expected no route for the mock integration, got %+v", rt)
+		}
+	}
+
+	if _, err := loadCDKRoutes(os.TempDir()); err == nil {
+		t.Error("expected an error for a directory without a manifest")
+	}
+}
@@ -0,0 +1,123 @@
+package invoker
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Faults to inject into a percentage of requests, for seeing how clients cope
+// with a slow or unreliable backend.
+type faults struct {
+	latencyPercent  int
+	latency         time.Duration
+	errorPercent    int
+	errorStatus     int
+	dropPercent     int
+	truncatePercent int
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Read the CHAOS_* settings. Returns nil when no faults are to be injected.
+func newFaults() (*faults, error) {
+	f := &faults{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	percents := []struct {
+		key   string
+		value *int
+	}{
+		{"CHAOS_LATENCY_PERCENT", &f.latencyPercent},
+		{"CHAOS_ERROR_PERCENT", &f.errorPercent},
+		{"CHAOS_DROP_PERCENT", &f.dropPercent},
+		{"CHAOS_TRUNCATE_PERCENT", &f.truncatePercent},
+	}
+	any := false
+	for _, p := range percents {
+		percent, err := getConfigInt(p.key)
+		if err != nil {
+			return nil, err
+		}
+		if percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid %v %v: must be between 0 and 100", p.key, percent)
+		}
+		*p.value = percent
+		any = any || percent > 0
+	}
+	if !any {
+		return nil, nil
+	}
+	var err error
+	if f.latency, err = getConfigDuration("CHAOS_LATENCY"); err != nil {
+		return nil, err
+	}
+	if f.errorStatus, err = getConfigInt("CHAOS_ERROR_STATUS"); err != nil {
+		return nil, err
+	}
+	if f.errorStatus < 400 || f.errorStatus > 599 {
+		return nil, fmt.Errorf("invalid CHAOS_ERROR_STATUS %v: must be between 400 and 599", f.errorStatus)
+	}
+	return f, nil
+}
+
+// Whether to inject a fault that happens percent of the time.
+func (f *faults) roll(percent int) bool {
+	if percent <= 0 || percent >= 100 {
+		return percent >= 100
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Intn(100) < percent
+}
+
+// injectFaults delays requests, answers them with an error or drops the
+// connection before the function is invoked, and cuts responses short after
+// it returns, each for its share of requests. Nil faults injects nothing.
+func injectFaults(f *faults, next http.Handler) http.Handler {
+	if f == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.roll(f.latencyPercent) {
+			logDebug("Injecting latency", logFields{"path": r.URL.Path, "latency": f.latency.String()})
+			select {
+			case <-time.After(f.latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if f.roll(f.dropPercent) {
+			logDebug("Injecting dropped connection", logFields{"path": r.URL.Path})
+			panic(http.ErrAbortHandler)
+		}
+		if f.roll(f.errorPercent) {
+			logDebug("Injecting error", logFields{"path": r.URL.Path, "status": f.errorStatus})
+			gatewayError(w, f.errorStatus, http.StatusText(f.errorStatus))
+			return
+		}
+		if f.roll(f.truncatePercent) {
+			logDebug("Injecting truncated response", logFields{"path": r.URL.Path})
+			w = &truncatingWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Sends only the first half of the body and then drops the connection, so
+// the client sees fewer bytes than Content-Length promised.
+type truncatingWriter struct {
+	http.ResponseWriter
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.ResponseWriter.Write(p[:len(p)/2])
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
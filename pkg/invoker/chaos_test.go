@@ -0,0 +1,140 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewFaults(t *testing.T) {
+	if f, err := newFaults(); f != nil || err != nil {
+		t.Errorf("expected no faults, got %+v %v", f, err)
+	}
+
+	os.Setenv("CHAOS_ERROR_PERCENT", "101")
+	defer os.Unsetenv("CHAOS_ERROR_PERCENT")
+	if _, err := newFaults(); err == nil {
+		t.Error("expected an error for a percentage over 100")
+	}
+
+	os.Setenv("CHAOS_ERROR_PERCENT", "10")
+	os.Setenv("CHAOS_ERROR_STATUS", "200")
+	defer os.Unsetenv("CHAOS_ERROR_STATUS")
+	if _, err := newFaults(); err == nil {
+		t.Error("expected an error for a successful status")
+	}
+
+	os.Setenv("CHAOS_ERROR_STATUS", "503")
+	f, err := newFaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.errorPercent != 10 || f.errorStatus != 503 || f.latency != time.Second {
+		t.Errorf("unexpected faults %+v", f)
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	invoked := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked = true
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("0123456789"))
+	})
+
+	rr := httptest.NewRecorder()
+	injectFaults(nil, next).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if !invoked || rr.Body.String() != "0123456789" {
+		t.Errorf("unexpected response without faults %q", rr.Body)
+	}
+
+	invoked = false
+	rr = httptest.NewRecorder()
+	injectFaults(&faults{errorPercent: 100, errorStatus: 503}, next).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if invoked || rr.Code != 503 || rr.Body.String() != `{"message":"Service Unavailable"}` {
+		t.Errorf("unexpected error response %v %q", rr.Code, rr.Body)
+	}
+
+	start := time.Now()
+	rr = httptest.NewRecorder()
+	injectFaults(&faults{latencyPercent: 100, latency: 20 * time.Millisecond}, next).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if !invoked || time.Since(start) < 20*time.Millisecond {
+		t.Error("expected a delayed invocation")
+	}
+
+	aborted := func(f *faults) (rr *httptest.ResponseRecorder, err interface{}) {
+		defer func() { err = recover() }()
+		rr = httptest.NewRecorder()
+		injectFaults(f, next).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		return rr, nil
+	}
+	invoked = false
+	if rr, err := aborted(&faults{dropPercent: 100}); err != http.ErrAbortHandler || invoked || rr.Body.Len() != 0 {
+		t.Errorf("expected a dropped connection, got %v", err)
+	}
+	if rr, err := aborted(&faults{truncatePercent: 100}); err != http.ErrAbortHandler || rr.Body.String() != "01234" || !rr.Flushed {
+		t.Errorf("expected a truncated body, got %q %v", rr.Body, err)
+	}
+}
+
+func TestObserveInjectedFaults(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	})
+	for _, e := range []struct {
+		faults *faults
+		status int
+		bytes  int
+	}{
+		{&faults{dropPercent: 100}, statusConnectionDropped, 0},
+		{&faults{truncatePercent: 100}, 200, 5},
+	} {
+		var observed *requestSummary
+		h := observeRequests(injectFaults(e.faults, next), func(s *requestSummary) { observed = s })
+		func() {
+			defer func() {
+				if err := recover(); err != http.ErrAbortHandler {
+					t.Errorf("expected the connection to still be dropped, got %v", err)
+				}
+			}()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		if observed == nil || observed.Status != e.status || observed.Bytes != e.bytes {
+			t.Errorf("expected a %v with %v bytes to be observed, got %+v", e.status, e.bytes, observed)
+		}
+	}
+}
+
+func TestTraceInjectedFaults(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	})
+	for _, e := range []struct {
+		faults *faults
+		status int
+	}{
+		{&faults{dropPercent: 100}, statusConnectionDropped},
+		{&faults{truncatePercent: 100}, 200},
+	} {
+		exporter := newSpanExporter("http://collector", "test")
+		h := traceRequests(exporter, injectFaults(e.faults, next))
+		func() {
+			defer func() {
+				if err := recover(); err != http.ErrAbortHandler {
+					t.Errorf("expected the connection to still be dropped, got %v", err)
+				}
+			}()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		select {
+		case s := <-exporter.spans:
+			if s.attributes["http.status_code"] != e.status || !s.failed {
+				t.Errorf("expected a failed span with status %v, got %v %v", e.status, s.attributes, s.failed)
+			}
+		default:
+			t.Errorf("expected a span to be exported for %+v", e.faults)
+		}
+	}
+}
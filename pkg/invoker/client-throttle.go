@@ -0,0 +1,78 @@
+package invoker
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A token bucket for one client.
+type clientBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Token buckets by client IP, which refill at rate tokens a second up to burst.
+type clientThrottle struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	buckets    map[string]*clientBucket
+	lastPruned time.Time
+}
+
+// Take a token for client, or say how long until one is free.
+func (t *clientThrottle) take(client string, now time.Time) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Forget clients whose buckets have filled up again, now and then.
+	if now.Sub(t.lastPruned) > time.Minute {
+		for ip, b := range t.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*t.rate >= t.burst {
+				delete(t.buckets, ip)
+			}
+		}
+		t.lastPruned = now
+	}
+	b, ok := t.buckets[client]
+	if !ok {
+		b = &clientBucket{tokens: t.burst, last: now}
+		t.buckets[client] = b
+	}
+	b.tokens = math.Min(t.burst, b.tokens+now.Sub(b.last).Seconds()*t.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / t.rate * float64(time.Second))
+}
+
+// throttleClients allows each client IP rate requests a second, with bursts
+// of up to burst, so one runaway client can't starve the others sharing the
+// proxy. Requests over the limit get a 429 with Retry-After. A rate of zero
+// disables it, and a burst of zero is the rate.
+func throttleClients(rate int, burst int, next http.Handler) http.Handler {
+	if rate <= 0 {
+		return next
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	throttle := &clientThrottle{rate: float64(rate), burst: float64(burst), buckets: make(map[string]*clientBucket)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, wait := throttle.take(client, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			gatewayError(w, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
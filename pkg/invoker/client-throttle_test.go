@@ -0,0 +1,50 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestThrottleClients(t *testing.T) {
+	handler := throttleClients(1, 2, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	for i := 0; i < 2; i++ {
+		if rr := send("10.0.0.1:1234"); rr.Code != http.StatusOK {
+			t.Fatalf("expected a burst of 2, got %v on request %v", rr.Code, i+1)
+		}
+	}
+	rr := send("10.0.0.1:5678")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected a 429 with Retry-After, got %v %v", rr.Code, rr.Header())
+	}
+	if rr := send("10.0.0.2:1234"); rr.Code != http.StatusOK {
+		t.Errorf("expected other clients to be unaffected, got %v", rr.Code)
+	}
+}
+
+func TestClientThrottleRefills(t *testing.T) {
+	throttle := &clientThrottle{rate: 2, burst: 1, buckets: make(map[string]*clientBucket)}
+	now := time.Now()
+	if ok, _ := throttle.take("a", now); !ok {
+		t.Fatal("expected the first request to be allowed")
+	}
+	if ok, wait := throttle.take("a", now.Add(100*time.Millisecond)); ok || wait.Round(time.Millisecond) != 400*time.Millisecond {
+		t.Errorf("expected to wait 400ms, got %v %v", ok, wait)
+	}
+	if ok, _ := throttle.take("a", now.Add(600*time.Millisecond)); !ok {
+		t.Error("expected a token after refilling")
+	}
+	throttle.take("b", now)
+	throttle.take("b", now.Add(2*time.Minute))
+	if _, ok := throttle.buckets["a"]; ok {
+		t.Error("expected idle clients to be forgotten")
+	}
+}
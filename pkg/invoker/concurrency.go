@@ -0,0 +1,75 @@
+package invoker
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// limitConcurrency emulates reserved concurrency by allowing at most max
+// invocations in flight at once. Anything beyond that is throttled with a 429
+// rather than queued, as Lambda would do. A max of zero disables the limit.
+func limitConcurrency(max int, next http.Handler) http.Handler {
+	if max <= 0 {
+		return next
+	}
+	slots := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			gatewayError(w, http.StatusTooManyRequests, "Too Many Requests")
+		}
+	})
+}
+
+// queueInvocations runs at most workers invocations at a time, holding up to
+// depth more requests for as long as timeout while they wait for a free worker.
+// This smooths out bursts that would otherwise hit the Lambda endpoint all at
+// once. Requests that don't fit in the queue, or wait too long, get a 503 with
+// Retry-After. Zero workers disables the queue; a zero timeout waits forever.
+func queueInvocations(workers int, depth int, timeout time.Duration, next http.Handler) http.Handler {
+	if workers <= 0 {
+		return next
+	}
+	running := make(chan struct{}, workers)
+	admitted := make(chan struct{}, workers+depth)
+
+	retryAfter := int(math.Ceil(timeout.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	unavailable := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		gatewayError(w, http.StatusServiceUnavailable, "Service Unavailable")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case admitted <- struct{}{}:
+			defer func() { <-admitted }()
+		default:
+			unavailable(w)
+			return
+		}
+
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		select {
+		case running <- struct{}{}:
+			defer func() { <-running }()
+			next.ServeHTTP(w, r)
+		case <-expired:
+			unavailable(w)
+		case <-r.Context().Done():
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := limitConcurrency(1, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	// Second request while the first is in flight is throttled.
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("throttled request returned wrong status code: got %v want %v", rr.Code, http.StatusTooManyRequests)
+	}
+	if b := rr.Body.String(); b != `{"message":"Too Many Requests"}` {
+		t.Errorf("throttled request returned unexpected body: got %v", b)
+	}
+
+	close(release)
+	wg.Wait()
+
+	// Slot is freed once the first request completes.
+	rr = httptest.NewRecorder()
+	go func() { <-started }()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("request after release returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestQueueInvocations(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := queueInvocations(1, 1, 50*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var running sync.WaitGroup
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	// The second request waits in the queue and times out.
+	queued := httptest.NewRecorder()
+	var waiting sync.WaitGroup
+	waiting.Add(1)
+	go func() {
+		defer waiting.Done()
+		h.ServeHTTP(queued, httptest.NewRequest("GET", "/", nil))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The third doesn't fit in the queue at all.
+	rejected := httptest.NewRecorder()
+	h.ServeHTTP(rejected, httptest.NewRequest("GET", "/", nil))
+
+	waiting.Wait()
+	close(release)
+	running.Wait()
+
+	for name, rr := range map[string]*httptest.ResponseRecorder{"queued": queued, "rejected": rejected} {
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%v request returned wrong status code: got %v want %v", name, rr.Code, http.StatusServiceUnavailable)
+		}
+		if retry := rr.Header().Get("Retry-After"); retry != "1" {
+			t.Errorf("%v request returned unexpected Retry-After: got %v want 1", name, retry)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package invoker
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings and routes read from CONFIG_FILE.
+type configFile struct {
+	Server map[string]interface{} `yaml:"server"`
+	AWS    map[string]interface{} `yaml:"aws"`
+	Lambda map[string]interface{} `yaml:"lambda"`
+	Routes routeTable             `yaml:"routes"`
+	// Functions to invoke on EventBridge schedule expressions.
+	Schedules []*schedule `yaml:"schedules"`
+	// APIs served on listeners of their own.
+	APIs []*api `yaml:"apis"`
+	// Lambda resolvers for GraphQL requests to GRAPHQL_PATH.
+	Resolvers []*resolver `yaml:"resolvers"`
+	// Replacements for the errors the proxy answers with, by response type.
+	GatewayResponses map[string]gatewayResponse `yaml:"gatewayResponses"`
+
+	settings map[string]string
+}
+
+// The configuration file currently in use. Swapped as a whole on reload.
+var loadedConfigFile atomic.Value
+
+func currentConfigFile() *configFile {
+	cfg, _ := loadedConfigFile.Load().(*configFile)
+	return cfg
+}
+
+func setConfigFile(cfg *configFile) {
+	loadedConfigFile.Store(cfg)
+}
+
+// Look a setting up in the configuration file by its environment variable name.
+func fileSetting(key string) string {
+	if cfg := currentConfigFile(); cfg != nil {
+		return cfg.settings[key]
+	}
+	return ""
+}
+
+var envReference = regexp.MustCompile(`\$\{(\w+)(?::-([^}]*))?\}`)
+
+// Replace ${VAR} and ${VAR:-default} with values from the environment.
+func interpolateEnv(data []byte) []byte {
+	return envReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		match := envReference.FindSubmatch(ref)
+		if value := os.Getenv(string(match[1])); value != "" {
+			return []byte(value)
+		}
+		return match[2]
+	})
+}
+
+// Read a YAML or JSON configuration file such as:
+//
+//	server:
+//	  port: 8080
+//	lambda:
+//	  name: MyFunction
+//	  endpoint: ${LAMBDA_HOST:-http://lambda:9001}
+//	routes:
+//	  - path: /reports
+//	    timeout: 2m
+//	schedules:
+//	  - function: nightly-report
+//	    expression: cron(0 2 * * ? *)
+//	gatewayResponses:
+//	  THROTTLED:
+//	    body: '{"error": $context.error.messageString}'
+func readConfigFile(file string) (*configFile, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg configFile
+	decoder := yaml.NewDecoder(bytes.NewReader(interpolateEnv(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+	}
+
+	cfg.settings = make(map[string]string)
+	sections := map[string]map[string]interface{}{"server": cfg.Server, "aws": cfg.AWS, "lambda": cfg.Lambda}
+	for section, values := range sections {
+		for name, value := range values {
+			key, ok := fileSettingKey(section, name)
+			if !ok {
+				return nil, fmt.Errorf("invalid config file %v: unknown setting %v.%v", file, section, name)
+			}
+			cfg.settings[key] = configValue(value)
+		}
+	}
+	for _, rt := range cfg.Routes {
+		if err := rt.compile(); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+		}
+	}
+	for _, res := range cfg.Resolvers {
+		if err := res.compile(); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+		}
+	}
+	for _, s := range cfg.Schedules {
+		if err := s.compile(); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+		}
+	}
+	names := make(map[string]bool)
+	for _, a := range cfg.APIs {
+		if err := a.compile(); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+		}
+		if names[a.Name] {
+			return nil, fmt.Errorf("invalid config file %v: more than one api is called %v", file, a.Name)
+		}
+		names[a.Name] = true
+	}
+	for responseType, gr := range cfg.GatewayResponses {
+		if err := gr.check(responseType); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// Settings are all strings once loaded, as if they had come from the environment.
+// Lists become comma separated.
+func configValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = configValue(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,107 @@
+package invoker
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Write contents to a temporary file named after pattern, as
+// ioutil.TempFile names it, removed once the test is over.
+func writeConfigFile(t *testing.T, pattern string, contents string) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestConfigFileYAML(t *testing.T) {
+	os.Setenv("TEST_LAMBDA_HOST", "http://lambda:9001")
+	defer os.Unsetenv("TEST_LAMBDA_HOST")
+
+	file := writeConfigFile(t, "config*.yaml", `
+server:
+  port: 9090
+  integrationTimeout: 5s
+aws:
+  region: ${TEST_REGION:-eu-west-2}
+lambda:
+  name: MyFunction
+  endpoint: ${TEST_LAMBDA_HOST}
+  warmFunctions: [users, orders]
+routes:
+  - method: GET
+    path: /reports/{id}
+    timeout: 2m
+`)
+
+	os.Setenv("CONFIG_FILE", file)
+	defer os.Unsetenv("CONFIG_FILE")
+	defer setConfigFile(nil)
+	defer setRoutes(nil)
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"PORT":                "9090",
+		"INTEGRATION_TIMEOUT": "5s",
+		"AWS_REGION":          "eu-west-2",
+		"LAMBDA_NAME":         "MyFunction",
+		"LAMBDA_ENDPOINT":     "http://lambda:9001",
+		"WARM_FUNCTIONS":      "users,orders",
+		// Not in the file so the default applies.
+		"MAX_REQUEST_SIZE": "10485760",
+	}
+	for key, value := range expected {
+		if got := getConfig(key); got != value {
+			t.Errorf("unexpected %v: got %v want %v", key, got, value)
+		}
+	}
+
+	if rt, params := currentRoutes().match("GET", "/reports/42"); rt == nil || params["id"] != "42" {
+		t.Errorf("route from config file did not match: %+v %v", rt, params)
+	}
+
+	// Environment variables win over the file.
+	os.Setenv("PORT", "7070")
+	defer os.Unsetenv("PORT")
+	if got := getConfig("PORT"); got != "7070" {
+		t.Errorf("environment did not override config file: got %v want 7070", got)
+	}
+}
+
+func TestConfigFileJSON(t *testing.T) {
+	file := writeConfigFile(t, "config*.json", `{"lambda": {"name": "MyFunction"}, "routes": [{"path": "/health", "timeout": "2s"}]}`)
+
+	cfg, err := readConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.settings["LAMBDA_NAME"] != "MyFunction" {
+		t.Errorf("unexpected LAMBDA_NAME: %v", cfg.settings["LAMBDA_NAME"])
+	}
+	if len(cfg.Routes) != 1 || cfg.Routes[0].Path != "/health" {
+		t.Errorf("unexpected routes: %+v", cfg.Routes)
+	}
+}
+
+func TestConfigFileInvalid(t *testing.T) {
+	for _, contents := range []string{
+		"server:\n  prot: 8080\n",
+		"servers:\n  port: 8080\n",
+		"routes:\n  - path: nope\n",
+	} {
+		file := writeConfigFile(t, "config*.yaml", contents)
+		if _, err := readConfigFile(file); err == nil {
+			t.Errorf("expected an error for config file %q", contents)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package invoker
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The Content-Type for a response whose function didn't send one:
+// DEFAULT_CONTENT_TYPE, or the first of its comma separated types that r
+// accepts most, or "" when it's off.
+func defaultContentType(r *http.Request) string {
+	setting := getConfig("DEFAULT_CONTENT_TYPE")
+	if setting == "off" {
+		return ""
+	}
+	var offers []string
+	for _, offer := range strings.Split(setting, ",") {
+		if offer = strings.TrimSpace(offer); offer != "" {
+			offers = append(offers, offer)
+		}
+	}
+	if len(offers) == 0 {
+		return ""
+	}
+	return negotiateContentType(r.Header.Get("Accept"), offers)
+}
+
+// Pick the offer accept gives the highest quality, preferring earlier offers
+// on a tie and the first when it accepts none of them.
+func negotiateContentType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQuality := offers[0], 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQuality {
+			best, bestQuality = offer, q
+		}
+	}
+	return best
+}
+
+// The quality accept gives offer, from the most specific range that matches
+// it, such as text/html before text/* before */*.
+func acceptQuality(accept string, offer string) float64 {
+	offerType, _, err := mime.ParseMediaType(offer)
+	if err != nil {
+		return 0
+	}
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case mediaRange == offerType:
+			s = 2
+		case mediaRange == "*/*":
+			s = 0
+		case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offerType, strings.TrimSuffix(mediaRange, "*")):
+			s = 1
+		}
+		if s <= specificity {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				q = 0
+			}
+		}
+		quality, specificity = q, s
+	}
+	return quality
+}
@@ -0,0 +1,59 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"application/json", "text/html", "text/plain"}
+	for _, c := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html"},
+		{"text/*", "text/html"},
+		{"text/*;q=0.5, text/plain", "text/plain"},
+		{"application/json;q=0, */*", "text/html"},
+		{"image/png", "application/json"},
+	} {
+		if got := negotiateContentType(c.accept, offers); got != c.want {
+			t.Errorf("%q: got %v, want %v", c.accept, got, c.want)
+		}
+	}
+}
+
+func TestDefaultContentType(t *testing.T) {
+	payload := []byte(`{"statusCode":200,"body":"<h1>Hi</h1>"}`)
+	rr := httptest.NewRecorder()
+	writeResponse(rr, httptest.NewRequest("GET", "/", nil), nil, payload, logFields{})
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected application/json by default, got %v", rr.Header().Get("Content-Type"))
+	}
+
+	os.Setenv("DEFAULT_CONTENT_TYPE", "application/json, text/html; charset=utf-8")
+	defer os.Unsetenv("DEFAULT_CONTENT_TYPE")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html")
+	rr = httptest.NewRecorder()
+	writeResponse(rr, req, nil, payload, logFields{})
+	if rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("expected the type the client accepts, got %v", rr.Header().Get("Content-Type"))
+	}
+
+	rr = httptest.NewRecorder()
+	writeResponse(rr, req, nil, []byte(`{"statusCode":200,"headers":{"content-type":"text/csv"}}`), logFields{})
+	if rr.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("expected the function's Content-Type, got %v", rr.Header().Get("Content-Type"))
+	}
+
+	os.Setenv("DEFAULT_CONTENT_TYPE", "off")
+	rr = httptest.NewRecorder()
+	writeResponse(rr, req, nil, payload, logFields{})
+	if _, ok := rr.Header()["Content-Type"]; ok {
+		t.Errorf("expected no Content-Type, got %v", rr.Header().Get("Content-Type"))
+	}
+}
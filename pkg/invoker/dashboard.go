@@ -0,0 +1,248 @@
+package invoker
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Lambda refuses synchronous events bigger than this.
+const maxEventSize = 6 * 1024 * 1024
+
+// An exchange shown on the dashboard, numbered so it can be picked out again.
+type recentExchange struct {
+	ID     int `json:"id"`
+	Status int `json:"status"`
+	recordedExchange
+}
+
+// The last few exchanges, for the dashboard on the admin port.
+type recentExchanges struct {
+	mu        sync.Mutex
+	size      int
+	nextID    int
+	exchanges []recentExchange
+}
+
+// Set in main when the dashboard is on, so is nil otherwise.
+var recentRequests *recentExchanges
+
+func newRecentExchanges(size int) *recentExchanges {
+	return &recentExchanges{size: size, nextID: 1}
+}
+
+// Keep an exchange, forgetting the oldest once there are size of them. Like
+// spans, recent is nil when the dashboard is off.
+func (recent *recentExchanges) add(exchange recordedExchange) {
+	if recent == nil {
+		return
+	}
+	var response restResponse
+	json.Unmarshal(exchange.Response, &response)
+
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	recent.exchanges = append(recent.exchanges, recentExchange{recent.nextID, response.StatusCode, exchange})
+	recent.nextID++
+	if len(recent.exchanges) > recent.size {
+		recent.exchanges = recent.exchanges[len(recent.exchanges)-recent.size:]
+	}
+}
+
+// The exchanges kept, newest first.
+func (recent *recentExchanges) list() []recentExchange {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	list := make([]recentExchange, len(recent.exchanges))
+	for i, exchange := range recent.exchanges {
+		list[len(list)-1-i] = exchange
+	}
+	return list
+}
+
+// Serve the dashboard page, the recent exchanges as JSON, and an endpoint that
+// sends an event, possibly edited, to the function that its path routes to.
+func newDashboard(recent *recentExchanges, clientFor func(*route) (*LambdaClient, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dashboard/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dashboard/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardPage))
+	})
+	mux.HandleFunc("/dashboard/requests", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recent.list())
+	})
+	mux.HandleFunc("/dashboard/send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			jsonError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		sendEvent(w, r, recent, clientFor)
+	})
+	return mux
+}
+
+// Invoke the function with the event in the request body, answering with the
+// payload it returns as is.
+func sendEvent(w http.ResponseWriter, r *http.Request, recent *recentExchanges, clientFor func(*route) (*LambdaClient, error)) {
+	event, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxEventSize))
+	if err != nil {
+		jsonError(w, http.StatusRequestEntityTooLarge, "Request Too Long")
+		return
+	}
+	var target struct {
+		HTTPMethod string `json:"httpMethod"`
+		Path       string `json:"path"`
+	}
+	if err := json.Unmarshal(event, &target); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid event: "+err.Error())
+		return
+	}
+
+	rt, _ := currentRoutes().match(target.HTTPMethod, target.Path)
+	function := getConfig("LAMBDA_NAME")
+	if rt != nil && rt.Function != "" {
+		function = rt.Function
+	}
+	c, err := clientFor(rt)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	timeout, err := getConfigDuration("INTEGRATION_TIMEOUT")
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	fields := logFields{"method": target.HTTPMethod, "path": target.Path, "function": function}
+	start := time.Now()
+	result, err := c.InvokeWithContext(ctx, &lambda.InvokeInput{FunctionName: aws.String(function), Payload: event})
+	if err != nil {
+		fields["error"] = err
+		logError("Dashboard invocation failed", fields)
+		jsonError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+	logInfo("Sent event from the dashboard", fields)
+	recent.add(recordedExchange{
+		Time:      start.UTC(),
+		Method:    target.HTTPMethod,
+		Path:      target.Path,
+		Function:  function,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Event:     recordedPayload(event),
+		Response:  recordedPayload(result.Payload),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result.Payload)
+}
+
+// Go 1.14 has no embed, so the page lives here. It needs nothing but the
+// endpoints above.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>http-lambda-invoker</title>
+<style>
+body { font: 14px sans-serif; margin: 1em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; }
+tbody tr { cursor: pointer; }
+tbody tr:hover, tr.selected { background: #eef; }
+.error { color: #b00; }
+#detail { display: none; gap: 1em; margin-top: 1em; }
+#detail > div { flex: 1; min-width: 0; }
+textarea, pre { width: 100%; height: 24em; box-sizing: border-box; font: 12px monospace; overflow: auto; background: #f7f7f7; border: 1px solid #ddd; margin: 0; }
+</style>
+</head>
+<body>
+<h1>Recent requests</h1>
+<p><button onclick="load()">Refresh</button></p>
+<table>
+<thead><tr><th>Time</th><th>Method</th><th>Path</th><th>Function</th><th>Status</th><th>Latency</th></tr></thead>
+<tbody id="requests"></tbody>
+</table>
+<div id="detail">
+<div>
+<h2>Event</h2>
+<textarea id="event" spellcheck="false"></textarea>
+<p><button onclick="send(false)">Re-send</button> <button onclick="send(true)">Send edited</button></p>
+</div>
+<div>
+<h2>Response</h2>
+<pre id="response"></pre>
+</div>
+</div>
+<script>
+var exchanges = [], selected = null;
+
+function pretty(value) {
+  return JSON.stringify(value, null, 2);
+}
+
+function load() {
+  fetch("requests").then(function (res) { return res.json(); }).then(function (list) {
+    exchanges = list;
+    var rows = document.getElementById("requests");
+    rows.innerHTML = "";
+    list.forEach(function (e) {
+      var tr = document.createElement("tr");
+      [new Date(e.time).toLocaleTimeString(), e.method, e.path, e.function, e.status, e.latencyMs.toFixed(1) + " ms"].forEach(function (text) {
+        var td = document.createElement("td");
+        td.textContent = text;
+        tr.appendChild(td);
+      });
+      if (e.status >= 500) tr.className = "error";
+      if (selected && selected.id === e.id) tr.classList.add("selected");
+      tr.onclick = function () { show(e); };
+      rows.appendChild(tr);
+    });
+  });
+}
+
+function show(e) {
+  selected = e;
+  document.getElementById("detail").style.display = "flex";
+  document.getElementById("event").value = pretty(e.event);
+  document.getElementById("response").textContent = pretty(e.response);
+  load();
+}
+
+function send(edited) {
+  var body = edited ? document.getElementById("event").value : JSON.stringify(selected.event);
+  var response = document.getElementById("response");
+  response.textContent = "Sending...";
+  fetch("send", {method: "POST", headers: {"Content-Type": "application/json"}, body: body})
+    .then(function (res) { return res.text(); })
+    .then(function (text) {
+      try { text = pretty(JSON.parse(text)); } catch (err) {}
+      response.textContent = text;
+      load();
+    });
+}
+
+load();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,120 @@
+package invoker
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestRecentExchanges(t *testing.T) {
+	var off *recentExchanges
+	off.add(recordedExchange{Path: "/ignored"})
+
+	recent := newRecentExchanges(2)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		recent.add(recordedExchange{Path: path, Response: []byte(`{"statusCode":201}`)})
+	}
+	list := recent.list()
+	if len(list) != 2 || list[0].Path != "/c" || list[0].ID != 3 || list[1].Path != "/b" {
+		t.Fatalf("expected the newest two exchanges first, got %+v", list)
+	}
+	if list[0].Status != 201 {
+		t.Errorf("expected status 201, got %v", list[0].Status)
+	}
+}
+
+func TestDashboardKeepsInvocations(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	recentRequests = newRecentExchanges(10)
+	defer func() { recentRequests = nil }()
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200,"body":"hi"}`)}}}
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+
+	list := recentRequests.list()
+	if len(list) != 1 || list[0].Path != "/users/42" || list[0].Function != "MyFunction" || list[0].Status != 200 {
+		t.Errorf("unexpected recent requests %+v", list)
+	}
+
+	rr := httptest.NewRecorder()
+	newAdminMux(false, newDashboard(recentRequests, nil)).ServeHTTP(rr, httptest.NewRequest("GET", "/dashboard/requests", nil))
+	if !strings.Contains(rr.Body.String(), `"path":"/users/42"`) {
+		t.Errorf("unexpected requests %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	newAdminMux(false, newDashboard(recentRequests, nil)).ServeHTTP(rr, httptest.NewRequest("GET", "/dashboard/", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Recent requests") {
+		t.Errorf("unexpected page %v", rr.Code)
+	}
+}
+
+func TestDashboardKeepsEvents(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	recentRequests = newRecentExchanges(10)
+	defer func() { recentRequests = nil }()
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200}`)}}}
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("POST", "/first", strings.NewReader("first body")))
+	first := string(recentRequests.list()[0].Event)
+	// Scribble over the pooled buffers the request used, as later ones would.
+	var taken []*bytes.Buffer
+	for i := 0; i < 4; i++ {
+		buf := getBuffer()
+		buf.Write(bytes.Repeat([]byte("x"), buf.Cap()))
+		taken = append(taken, buf)
+	}
+	for _, buf := range taken {
+		putBuffer(buf)
+	}
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/second", nil))
+
+	list := recentRequests.list()
+	if len(list) != 2 || string(list[1].Event) != first {
+		t.Fatalf("expected the first event to be kept as it was, got %.100s", list[1].Event)
+	}
+	if !strings.Contains(first, `"path":"/first"`) || !strings.Contains(string(list[0].Event), `"path":"/second"`) {
+		t.Errorf("unexpected events %s %s", first, list[0].Event)
+	}
+}
+
+func TestDashboardSend(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	m := &recordingLambdaClient{}
+	recent := newRecentExchanges(10)
+	dashboard := newDashboard(recent, func(*route) (*LambdaClient, error) { return &LambdaClient{m}, nil })
+
+	event := `{"httpMethod":"POST","path":"/users","body":"edited"}`
+	rr := httptest.NewRecorder()
+	dashboard.ServeHTTP(rr, httptest.NewRequest("POST", "/dashboard/send", strings.NewReader(event)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %s", rr.Code, rr.Body)
+	}
+	if len(m.Inputs) != 1 || string(m.Inputs[0].Payload) != event || *m.Inputs[0].FunctionName != "MyFunction" {
+		t.Errorf("expected the edited event to be sent, got %v", m.Inputs)
+	}
+	if list := recent.list(); len(list) != 1 || list[0].Method != "POST" || list[0].Path != "/users" {
+		t.Errorf("expected the sent event to be listed, got %+v", list)
+	}
+
+	for _, body := range []string{"not json", ""} {
+		rr = httptest.NewRecorder()
+		dashboard.ServeHTTP(rr, httptest.NewRequest("POST", "/dashboard/send", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected a 400, got %v", body, rr.Code)
+		}
+	}
+	rr = httptest.NewRecorder()
+	dashboard.ServeHTTP(rr, httptest.NewRequest("GET", "/dashboard/send", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected a 405, got %v", rr.Code)
+	}
+}
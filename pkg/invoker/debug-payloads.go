@@ -0,0 +1,57 @@
+package invoker
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Log the event sent to the function and the payload it returned, when
+// DEBUG_PAYLOADS is on.
+func debugPayload(msg string, payload []byte, fields logFields) {
+	debug, err := getConfigBool("DEBUG_PAYLOADS")
+	if err != nil || !debug {
+		return
+	}
+	entry := logFields{"payload": redactPayload(payload, debugRedactHeaders())}
+	for key, value := range fields {
+		entry[key] = value
+	}
+	logInfo(msg, entry)
+}
+
+// Header names from DEBUG_REDACT_HEADERS, in lower case.
+func debugRedactHeaders() []string {
+	var names []string
+	for _, name := range strings.Split(getConfig("DEBUG_REDACT_HEADERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	return names
+}
+
+// Replace the values of the named headers in an event or response payload.
+// Payloads that aren't JSON objects are logged as they are, in a string.
+func redactPayload(payload []byte, names []string) interface{} {
+	var object map[string]interface{}
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return string(payload)
+	}
+	if len(names) == 0 {
+		return json.RawMessage(payload)
+	}
+	for _, key := range []string{"headers", "multiValueHeaders"} {
+		headers, ok := object[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for header := range headers {
+			for _, name := range names {
+				if strings.ToLower(header) == name {
+					headers[header] = "[REDACTED]"
+				}
+			}
+		}
+	}
+	return object
+}
@@ -0,0 +1,41 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestDebugPayloads(t *testing.T) {
+	os.Setenv("DEBUG_PAYLOADS", "true")
+	os.Setenv("DEBUG_REDACT_HEADERS", "Authorization, set-cookie")
+	defer os.Unsetenv("DEBUG_PAYLOADS")
+	defer os.Unsetenv("DEBUG_REDACT_HEADERS")
+	out := captureLogs(t)
+
+	payload := `{"statusCode":200,"headers":{"Set-Cookie":"session=abc"},"body":"hi"}`
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(payload)}}}
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"empty?"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	l.invokeLambda(httptest.NewRecorder(), req)
+
+	logs := out.String()
+	if !strings.Contains(logs, `"msg":"Invoking function"`) || !strings.Contains(logs, `"msg":"Function returned"`) {
+		t.Fatalf("expected the event and response to be logged, got %q", logs)
+	}
+	if !strings.Contains(logs, `\"name\":\"empty?\"`) {
+		t.Errorf("expected the request body in the logged event, got %q", logs)
+	}
+	if strings.Contains(logs, "secret") || strings.Contains(logs, "session=abc") || strings.Count(logs, "[REDACTED]") != 2 {
+		t.Errorf("expected redacted headers, got %q", logs)
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	if got := redactPayload([]byte("not json"), nil); got != "not json" {
+		t.Errorf("expected payloads that aren't JSON to be logged as strings, got %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package invoker
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// A :name segment, which tag values can hold where AWS doesn't allow {name}.
+var colonParameter = regexp.MustCompile(`/:(\w+)(\+?)`)
+
+// Build routes for the functions at LAMBDA_ENDPOINT from their tags. Any tag
+// whose key starts with prefix, such as http-route or http-route-2, holds a
+// route like "GET /users/{id}", "ANY /users/:id" or just "/users".
+func (c *LambdaClient) discoverRoutes(ctx context.Context, prefix string) (routeTable, error) {
+	var functions []*lambda.FunctionConfiguration
+	err := c.ListFunctionsPagesWithContext(ctx, &lambda.ListFunctionsInput{}, func(page *lambda.ListFunctionsOutput, last bool) bool {
+		functions = append(functions, page.Functions...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var table routeTable
+	for _, function := range functions {
+		if function.FunctionArn == nil || function.FunctionName == nil {
+			continue
+		}
+		tags, err := c.ListTagsWithContext(ctx, &lambda.ListTagsInput{Resource: function.FunctionArn})
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range tags.Tags {
+			if !strings.HasPrefix(key, prefix) || value == nil {
+				continue
+			}
+			rt := &route{Function: *function.FunctionName}
+			parts := strings.Fields(*value)
+			switch len(parts) {
+			case 1:
+				rt.Path = parts[0]
+			case 2:
+				rt.Method, rt.Path = strings.ToUpper(parts[0]), parts[1]
+			}
+			if rt.Method == "ANY" {
+				rt.Method = ""
+			}
+			rt.Path = colonParameter.ReplaceAllString(rt.Path, "/{$1$2}")
+			if err := rt.compile(); err != nil {
+				logWarn("Ignoring invalid route tag", logFields{"function": rt.Function, "tag": key, "error": err})
+				continue
+			}
+			table = append(table, rt)
+		}
+	}
+	sortRoutes(table)
+	return table, nil
+}
+
+// Discover routes every interval until ctx is done, so functions deployed to
+// LocalStack become reachable without editing any configuration. If
+// discovery fails the routes found last time are kept.
+func runDiscovery(ctx context.Context, interval time.Duration, prefix string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var previous []string
+	for {
+		c, err := getLambdaClient()
+		if err == nil {
+			var table routeTable
+			if table, err = c.discoverRoutes(ctx, prefix); err == nil {
+				setDiscoveredRoutes(table)
+				if found := routeNames(table); strings.Join(found, ",") != strings.Join(previous, ",") {
+					logInfo("Discovered routes", logFields{"routes": found})
+					previous = found
+				}
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			logWarn("Failed to discover routes", logFields{"error": err})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Routes as "GET /users/{id} users" for logging.
+func routeNames(table routeTable) []string {
+	names := make([]string, len(table))
+	for i, rt := range table {
+		method := rt.Method
+		if method == "" {
+			method = "ANY"
+		}
+		names[i] = method + " " + rt.Path + " " + rt.Function
+	}
+	return names
+}
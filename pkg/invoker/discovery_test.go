@@ -0,0 +1,86 @@
+package invoker
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+type taggedLambdaClient struct {
+	lambdaiface.LambdaAPI
+	tags map[string]map[string]*string
+}
+
+func (m taggedLambdaClient) ListFunctionsPagesWithContext(_ aws.Context, _ *lambda.ListFunctionsInput, fn func(*lambda.ListFunctionsOutput, bool) bool, _ ...request.Option) error {
+	for name := range m.tags {
+		page := &lambda.ListFunctionsOutput{Functions: []*lambda.FunctionConfiguration{{
+			FunctionName: aws.String(name),
+			FunctionArn:  aws.String("arn:aws:lambda:us-east-1:000000000000:function:" + name),
+		}}}
+		if !fn(page, false) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m taggedLambdaClient) ListTagsWithContext(_ aws.Context, in *lambda.ListTagsInput, _ ...request.Option) (*lambda.ListTagsOutput, error) {
+	name := (*in.Resource)[len("arn:aws:lambda:us-east-1:000000000000:function:"):]
+	return &lambda.ListTagsOutput{Tags: m.tags[name]}, nil
+}
+
+func TestDiscoverRoutes(t *testing.T) {
+	c := LambdaClient{taggedLambdaClient{tags: map[string]map[string]*string{
+		"users":  {"http-route": aws.String("GET /users/:id"), "http-route-2": aws.String("ANY /users"), "team": aws.String("web")},
+		"files":  {"http-route": aws.String("/files/:path+")},
+		"broken": {"http-route": aws.String("GET users")},
+		"other":  {},
+	}}}
+	table, err := c.discoverRoutes(context.Background(), "http-route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 3 {
+		t.Fatalf("expected 3 routes, got %v", routeNames(table))
+	}
+	for _, m := range []struct {
+		method   string
+		path     string
+		function string
+	}{
+		{"GET", "/users/42", "users"},
+		{"POST", "/users", "users"},
+		{"PUT", "/files/a/b", "files"},
+	} {
+		if rt, _ := table.match(m.method, m.path); rt == nil || rt.Function != m.function {
+			t.Errorf("%v %v: unexpected route %+v", m.method, m.path, rt)
+		}
+	}
+	if rt, _ := table.match("POST", "/users/42"); rt != nil {
+		t.Errorf("expected GET only, got %+v", rt)
+	}
+
+	setDiscoveredRoutes(table)
+	defer setDiscoveredRoutes(nil)
+	setRoutes(nil)
+	if rt, _ := currentRoutes().match("GET", "/users/42"); rt == nil || rt.Function != "users" {
+		t.Errorf("expected discovered routes to be matched, got %+v", rt)
+	}
+}
+
+func TestDiscoveryWithoutLambdaName(t *testing.T) {
+	os.Unsetenv("LAMBDA_NAME")
+	if err := validateConfig(); err == nil {
+		t.Error("expected LAMBDA_NAME to be required without routes")
+	}
+	os.Setenv("DISCOVER_INTERVAL", "10s")
+	defer os.Unsetenv("DISCOVER_INTERVAL")
+	if err := validateConfig(); err != nil {
+		t.Errorf("expected LAMBDA_NAME to be optional when discovering, got %v", err)
+	}
+}
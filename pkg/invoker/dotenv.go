@@ -0,0 +1,80 @@
+package invoker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Load KEY=VALUE lines from a .env file into the environment before any
+// configuration is read. Variables that are already set win, so the same file
+// can be shared between docker-compose and a shell. A missing file is only an
+// error when required is set.
+func loadDotEnv(file string, required bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		key, value, err := parseDotEnvLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("invalid %v line %v: %v", file, line, err)
+		}
+		if key == "" {
+			continue
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// Parse one line of a .env file. Blank lines and comments give an empty key.
+func parseDotEnvLine(line string) (string, string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", nil
+	}
+	line = strings.TrimPrefix(line, "export ")
+
+	i := strings.Index(line, "=")
+	if i < 1 {
+		return "", "", fmt.Errorf("expected KEY=VALUE")
+	}
+	key := strings.TrimSpace(line[:i])
+	value := strings.TrimSpace(line[i+1:])
+
+	switch {
+	case strings.HasPrefix(value, `"`):
+		// Double quotes allow escapes such as \n.
+		end := strings.LastIndex(value, `"`)
+		if end == 0 {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		value = unquoted
+	case strings.HasPrefix(value, "'"):
+		end := strings.LastIndex(value, "'")
+		if end == 0 {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		value = value[1:end]
+	default:
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+	}
+	return key, value, nil
+}
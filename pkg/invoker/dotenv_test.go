@@ -0,0 +1,66 @@
+package invoker
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseDotEnvLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		key   string
+		value string
+	}{
+		{"LAMBDA_NAME=MyFunction", "LAMBDA_NAME", "MyFunction"},
+		{"export PORT=8080", "PORT", "8080"},
+		{"  ROUTE = /users/{id}  ", "ROUTE", "/users/{id}"},
+		{`GREETING="hello\nworld"`, "GREETING", "hello\nworld"},
+		{"RAW='no $interpolation here'", "RAW", "no $interpolation here"},
+		{"WITH_COMMENT=value # trailing comment", "WITH_COMMENT", "value"},
+		{"EMPTY=", "EMPTY", ""},
+		{"# a comment", "", ""},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		key, value, err := parseDotEnvLine(test.line)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.line, err)
+			continue
+		}
+		if key != test.key || value != test.value {
+			t.Errorf("%q: got %q=%q want %q=%q", test.line, key, value, test.key, test.value)
+		}
+	}
+
+	for _, line := range []string{"NO_EQUALS", "=value", `QUOTE="unterminated`} {
+		if _, _, err := parseDotEnvLine(line); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	file := writeConfigFile(t, "*.env", "TEST_DOTENV_NEW=from-file\nTEST_DOTENV_SET=from-file\n")
+
+	os.Setenv("TEST_DOTENV_SET", "from-env")
+	defer os.Unsetenv("TEST_DOTENV_SET")
+	defer os.Unsetenv("TEST_DOTENV_NEW")
+
+	if err := loadDotEnv(file, true); err != nil {
+		t.Fatal(err)
+	}
+	if v := os.Getenv("TEST_DOTENV_NEW"); v != "from-file" {
+		t.Errorf("unexpected TEST_DOTENV_NEW: got %v want from-file", v)
+	}
+	if v := os.Getenv("TEST_DOTENV_SET"); v != "from-env" {
+		t.Errorf(".env overrode the environment: got %v want from-env", v)
+	}
+
+	if err := loadDotEnv("does-not-exist.env", false); err != nil {
+		t.Errorf("missing optional .env should be ignored, got %v", err)
+	}
+	if err := loadDotEnv("does-not-exist.env", true); err == nil {
+		t.Error("expected an error for a missing required .env")
+	}
+}
@@ -0,0 +1,16 @@
+package invoker
+
+import (
+	"net/http"
+)
+
+// Whether to return the event instead of invoking the function, either for
+// every request with DRY_RUN or for a single one with an X-Dry-Run header.
+func isDryRun(r *http.Request) (bool, error) {
+	switch r.Header.Get("X-Dry-Run") {
+	case "", "0", "false":
+	default:
+		return true, nil
+	}
+	return getConfigBool("DRY_RUN")
+}
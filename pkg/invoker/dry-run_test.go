@@ -0,0 +1,53 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// Panics if the function is invoked at all.
+type unusedLambdaClient struct {
+	lambdaiface.LambdaAPI
+}
+
+func TestDryRunHeader(t *testing.T) {
+	req := httptest.NewRequest("POST", "/orders?expand=items", strings.NewReader(`{"sku":"abc"}`))
+	req.Header.Set("X-Dry-Run", "true")
+	rr := httptest.NewRecorder()
+
+	l := LambdaClient{unusedLambdaClient{}}
+	l.invokeLambda(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var event makeProxyRequest
+	if err := json.Unmarshal(rr.Body.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.HTTPMethod != "POST" || event.Path != "/orders" || event.Body != `{"sku":"abc"}` {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if expand := event.QueryStringParams["expand"]; len(expand) != 1 || expand[0] != "items" {
+		t.Errorf("unexpected query string parameters: %v", event.QueryStringParams)
+	}
+}
+
+func TestDryRunSetting(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if dryRun, _ := isDryRun(req); dryRun {
+		t.Error("dry run should be off by default")
+	}
+
+	os.Setenv("DRY_RUN", "true")
+	defer os.Unsetenv("DRY_RUN")
+	if dryRun, _ := isDryRun(req); !dryRun {
+		t.Error("expected DRY_RUN to turn on dry run")
+	}
+}
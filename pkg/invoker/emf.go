@@ -0,0 +1,64 @@
+package invoker
+
+import (
+	"encoding/json"
+)
+
+// Log a CloudWatch Embedded Metric Format line for each request that reached a
+// function, so running in ECS with the awslogs driver is enough to get
+// invocation, latency and error metrics per function into CloudWatch.
+func emfObserver(namespace string) func(*requestSummary) {
+	return func(s *requestSummary) {
+		if s.Function == "" {
+			return
+		}
+		errors := 0
+		if s.Status >= 500 {
+			errors = 1
+		}
+		metrics := []interface{}{
+			map[string]string{"Name": "Invocations", "Unit": "Count"},
+			map[string]string{"Name": "Latency", "Unit": "Milliseconds"},
+			map[string]string{"Name": "Errors", "Unit": "Count"},
+		}
+		values := map[string]interface{}{
+			"Function":    s.Function,
+			"Invocations": 1,
+			"Latency":     float64(s.Latency.Microseconds()) / 1000,
+			"Errors":      errors,
+			"Route":       s.Route,
+			"Status":      s.Status,
+			"RequestId":   s.RequestID,
+		}
+		// What the function itself reported, with LAMBDA_TAIL_LOGS.
+		if report := s.Report; report != nil {
+			coldStarts := 0
+			if report.ColdStart() {
+				coldStarts = 1
+				metrics = append(metrics, map[string]string{"Name": "InitDuration", "Unit": "Milliseconds"})
+				values["InitDuration"] = float64(report.InitDuration.Microseconds()) / 1000
+			}
+			metrics = append(metrics,
+				map[string]string{"Name": "Duration", "Unit": "Milliseconds"},
+				map[string]string{"Name": "MaxMemoryUsed", "Unit": "Megabytes"},
+				map[string]string{"Name": "ColdStarts", "Unit": "Count"},
+			)
+			values["Duration"] = float64(report.Duration.Microseconds()) / 1000
+			values["MaxMemoryUsed"] = report.MaxMemoryUsed
+			values["ColdStarts"] = coldStarts
+		}
+		values["_aws"] = map[string]interface{}{
+			"Timestamp": s.Time.UnixNano() / 1e6,
+			"CloudWatchMetrics": []interface{}{map[string]interface{}{
+				"Namespace":  namespace,
+				"Dimensions": [][]string{{"Function"}},
+				"Metrics":    metrics,
+			}},
+		}
+		line, err := json.Marshal(values)
+		if err != nil {
+			return
+		}
+		defaultLogger.write(append(line, '\n'))
+	}
+}
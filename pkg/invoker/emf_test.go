@@ -0,0 +1,37 @@
+package invoker
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEMFObserver(t *testing.T) {
+	out := captureLogs(t)
+	observe := emfObserver("Invoker")
+	observe(&requestSummary{Time: time.Unix(1600000000, 0), Function: "users", Status: 500, Latency: 12 * time.Millisecond})
+	observe(&requestSummary{Time: time.Unix(1600000000, 0), Status: 429})
+
+	var line struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+			}
+		} `json:"_aws"`
+		Function    string
+		Invocations int
+		Latency     float64
+		Errors      int
+	}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("expected a single EMF line, got %q: %v", out.String(), err)
+	}
+	if line.AWS.Timestamp != 1600000000000 || line.AWS.CloudWatchMetrics[0].Namespace != "Invoker" || line.AWS.CloudWatchMetrics[0].Dimensions[0][0] != "Function" {
+		t.Errorf("unexpected EMF metadata %+v", line.AWS)
+	}
+	if line.Function != "users" || line.Invocations != 1 || line.Latency != 12 || line.Errors != 1 {
+		t.Errorf("unexpected EMF values %+v", line)
+	}
+}
@@ -0,0 +1,36 @@
+package invoker
+
+import (
+	"expvar"
+	"runtime"
+)
+
+// Counters published at /debug/vars on the admin port, next to the memstats
+// (including GC stats) and cmdline that expvar always publishes.
+var (
+	invocationCount = expvar.NewInt("invocations")
+	errorCount      = expvar.NewInt("errors")
+	functionCounts  = expvar.NewMap("invocationsByFunction")
+	coldStartCount  = expvar.NewInt("coldStarts")
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// Count requests that reached a function, those answered with a 5xx and, with
+// LAMBDA_TAIL_LOGS, cold starts.
+func expvarObserver(s *requestSummary) {
+	if s.Function != "" {
+		invocationCount.Add(1)
+		functionCounts.Add(s.Function, 1)
+	}
+	if s.Status >= 500 {
+		errorCount.Add(1)
+	}
+	if s.Report != nil && s.Report.ColdStart() {
+		coldStartCount.Add(1)
+	}
+}
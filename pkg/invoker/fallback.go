@@ -0,0 +1,185 @@
+package invoker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// The region in an API Gateway hostname such as
+// abc123.execute-api.eu-west-1.amazonaws.com.
+var executeAPIHost = regexp.MustCompile(`\.execute-api\.([a-z0-9-]+)\.amazonaws\.com$`)
+
+// What a fallback transport depends on, so one is only built again when
+// those settings change.
+type fallbackConfig struct {
+	Sign   bool
+	Region string
+	Client clientConfig
+}
+
+var fallbackTransports = make(map[fallbackConfig]http.RoundTripper)
+
+// Check FALLBACK_URL, which must be an absolute http or https URL.
+func parseFallbackURL(fallback string) (*url.URL, error) {
+	target, err := url.Parse(fallback)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FALLBACK_URL %q: %v", fallback, err)
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid FALLBACK_URL %q: must be an http or https URL", fallback)
+	}
+	return target, nil
+}
+
+// Send a request that matched no route on to FALLBACK_URL, such as a deployed
+// stage of the API, so only the routes being worked on run locally. The path
+// is appended to the URL's own and the Host header is the upstream's, with
+// the client's in X-Forwarded-Host. With FALLBACK_SIGN the request is signed
+// for IAM authorization with the AWS credentials.
+func forwardToFallback(w http.ResponseWriter, r *http.Request, fallback string) {
+	fields := logFields{"request_id": requestID(r), "method": r.Method, "path": r.URL.Path, "fallback": fallback}
+	target, err := parseFallbackURL(fallback)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	transport, err := fallbackTransport(target)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	timeout, err := getConfigDuration("INTEGRATION_TIMEOUT")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(out *http.Request) {
+			out.URL.Scheme = target.Scheme
+			out.URL.Host = target.Host
+			out.URL.Path = strings.TrimSuffix(target.Path, "/") + out.URL.Path
+			out.URL.RawPath = ""
+			if target.RawQuery != "" && out.URL.RawQuery != "" {
+				out.URL.RawQuery = target.RawQuery + "&" + out.URL.RawQuery
+			} else if target.RawQuery != "" {
+				out.URL.RawQuery = target.RawQuery
+			}
+			out.Header.Set("X-Forwarded-Host", r.Host)
+			out.Host = target.Host
+		},
+		Transport: transport,
+		// Headers the upstream sends replace any the middlewares set, such
+		// as CORS headers, rather than being sent twice.
+		ModifyResponse: func(resp *http.Response) error {
+			for key := range resp.Header {
+				w.Header().Del(key)
+			}
+			fields["status"] = resp.StatusCode
+			logDebug("Forwarded to fallback", fields)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				logWarn("Endpoint request timed out", fields)
+				gatewayError(w, http.StatusGatewayTimeout, "Endpoint request timed out")
+				return
+			case context.Canceled:
+				logInfo("Client disconnected, cancelled request", fields)
+				return
+			}
+			fields["error"] = err
+			logError("Fallback request failed", fields)
+			gatewayError(w, http.StatusBadGateway, "Bad Gateway")
+		},
+	}
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// The transport for requests to target, signing them when FALLBACK_SIGN is
+// set. Transports are kept for reuse as the Lambda clients are.
+func fallbackTransport(target *url.URL) (http.RoundTripper, error) {
+	sign, err := getConfigBool("FALLBACK_SIGN")
+	if err != nil {
+		return nil, err
+	}
+	cfg := fallbackConfig{Sign: sign}
+	if sign {
+		if cfg.Client, err = currentClientConfig(); err != nil {
+			return nil, err
+		}
+		cfg.Region = cfg.Client.Region
+		if match := executeAPIHost.FindStringSubmatch(target.Hostname()); match != nil {
+			cfg.Region = match[1]
+		}
+	}
+
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if transport, ok := fallbackTransports[cfg]; ok {
+		return transport, nil
+	}
+	var transport http.RoundTripper = http.DefaultTransport.(*http.Transport).Clone()
+	if sign {
+		sess, err := newSession(cfg.Client)
+		if err != nil {
+			return nil, err
+		}
+		creds := sess.Config.Credentials
+		if cfg.Client.RoleARN != "" {
+			creds = assumeRoleCredentials(sess, cfg.Client)
+		}
+		transport = &signingTransport{transport, creds, cfg.Region}
+	}
+	fallbackTransports[cfg] = transport
+	return transport, nil
+}
+
+// Signs requests with Signature Version 4 for API Gateway's execute-api,
+// after the reverse proxy has finished changing their headers.
+type signingTransport struct {
+	base        http.RoundTripper
+	credentials *credentials.Credentials
+	region      string
+}
+
+func (t *signingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	// The signer attaches the body it signed, and none at all when it's empty.
+	var body io.ReadSeeker
+	if r.Body != nil {
+		data, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			body = bytes.NewReader(data)
+		}
+	}
+	// The client's own credentials are meant for this proxy, not the API.
+	r.Header.Del("Authorization")
+	if _, err := v4.NewSigner(t.credentials).Sign(r, body, "execute-api", t.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("signing request: %v", err)
+	}
+	return t.base.RoundTrip(r)
+}
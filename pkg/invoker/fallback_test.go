@@ -0,0 +1,90 @@
+package invoker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFallbackURL(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		requests <- r
+		w.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("upstream"))
+	}))
+	defer upstream.Close()
+	os.Setenv("FALLBACK_URL", upstream.URL+"/dev/")
+	defer os.Unsetenv("FALLBACK_URL")
+
+	rt := &route{Path: "/orders", Function: "orders"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+	if err := validateConfig(); err != nil {
+		t.Errorf("expected no need for LAMBDA_NAME with FALLBACK_URL: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://localhost:8080/users/42?page=2", strings.NewReader("hello"))
+	req.Header.Set("Authorization", "Bearer token")
+	allowAnyOrigin(http.HandlerFunc(handler)).ServeHTTP(rr, req)
+	if rr.Code != http.StatusTeapot || rr.Body.String() != "upstream" {
+		t.Errorf("expected the upstream's response, got %v %v", rr.Code, rr.Body.String())
+	}
+	if origins := rr.Header()["Access-Control-Allow-Origin"]; len(origins) != 1 || origins[0] != "https://app.example.com" {
+		t.Errorf("expected the upstream's CORS header alone, got %v", origins)
+	}
+	forwarded := <-requests
+	body, _ := ioutil.ReadAll(forwarded.Body)
+	if forwarded.URL.Path != "/dev/users/42" || forwarded.URL.RawQuery != "page=2" || string(body) != "hello" {
+		t.Errorf("unexpected request %v %v %q", forwarded.URL.Path, forwarded.URL.RawQuery, body)
+	}
+	if !strings.HasSuffix(upstream.URL, forwarded.Host) || forwarded.Header.Get("X-Forwarded-Host") != "localhost:8080" {
+		t.Errorf("unexpected host %v, forwarded for %v", forwarded.Host, forwarded.Header.Get("X-Forwarded-Host"))
+	}
+	if forwarded.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected the client's Authorization header unsigned, got %q", forwarded.Header.Get("Authorization"))
+	}
+
+	os.Setenv("FALLBACK_SIGN", "true")
+	defer os.Unsetenv("FALLBACK_SIGN")
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/users", nil))
+	forwarded = <-requests
+	authorization := forwarded.Header.Get("Authorization")
+	scope := "Credential=foo/" + time.Now().UTC().Format("20060102") + "/us-east-1/execute-api/aws4_request"
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 ") || !strings.Contains(authorization, scope) {
+		t.Errorf("expected a signature for execute-api, got %q", authorization)
+	}
+	if forwarded.ContentLength > 0 || len(forwarded.TransferEncoding) > 0 {
+		t.Errorf("expected no body, got %v bytes %v", forwarded.ContentLength, forwarded.TransferEncoding)
+	}
+}
+
+func TestFallbackErrors(t *testing.T) {
+	for _, fallback := range []string{"localhost:8080", "ftp://example.com", "/dev"} {
+		if _, err := parseFallbackURL(fallback); err == nil {
+			t.Errorf("expected an error for %v", fallback)
+		}
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	upstream.Close()
+	os.Setenv("FALLBACK_URL", upstream.URL)
+	defer os.Unsetenv("FALLBACK_URL")
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/users", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("expected a 502 when the upstream is down, got %v", rr.Code)
+	}
+}
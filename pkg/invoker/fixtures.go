@@ -0,0 +1,96 @@
+package invoker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// A file name such as 003-get-users-42.json for the nth exchange.
+func fixtureName(n int, exchange recordedExchange) string {
+	name := unsafeFileChars.ReplaceAllString(strings.ToLower(exchange.Method+" "+exchange.Path), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return fmt.Sprintf("%03d-%v.json", n, name)
+}
+
+// Write each exchange recorded in file to dir as an event file that works
+// with `sam local invoke -e`, returning the files written. With goTests a
+// fixtures_test.go holding a table of the events and the responses they got
+// is written too.
+func writeFixtures(file string, dir string, goTests bool) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	var table bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange recordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return written, fmt.Errorf("invalid recording %v line %v: %v", file, line, err)
+		}
+		if len(exchange.Event) == 0 {
+			return written, fmt.Errorf("invalid recording %v line %v: no event", file, line)
+		}
+
+		var event bytes.Buffer
+		if err := json.Indent(&event, exchange.Event, "", "  "); err != nil {
+			return written, fmt.Errorf("invalid recording %v line %v: %v", file, line, err)
+		}
+		event.WriteByte('\n')
+		name := fixtureName(len(written)+1, exchange)
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, event.Bytes(), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+
+		var response restResponse
+		json.Unmarshal(exchange.Response, &response)
+		fmt.Fprintf(&table, "\t{%q, %q, %v, %q},\n", exchange.Method+" "+exchange.Path, name, response.StatusCode, response.Body)
+	}
+	if err := scanner.Err(); err != nil {
+		return written, fmt.Errorf("invalid recording %v: %v", file, err)
+	}
+
+	if goTests {
+		path := filepath.Join(dir, "fixtures_test.go")
+		source := fmt.Sprintf(`package main
+
+// Generated by http-lambda-invoker from %v. Each event file holds the event
+// the function received, along with the status and body it answered with.
+var recordedFixtures = []struct {
+	name       string
+	eventFile  string
+	statusCode int
+	body       string
+}{
+%v}
+`, filepath.Base(file), table.String())
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
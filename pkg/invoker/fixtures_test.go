@@ -0,0 +1,53 @@
+package invoker
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteFixtures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	recording := filepath.Join(dir, "traffic.jsonl")
+	ioutil.WriteFile(recording, []byte(`{"method":"GET","path":"/users/42","event":{"httpMethod":"GET","path":"/users/42"},"response":{"statusCode":200,"body":"{\"id\":42}"}}
+{"method":"POST","path":"/users","event":{"httpMethod":"POST","path":"/users","body":"x"},"response":{"statusCode":201,"body":""}}
+`), 0644)
+
+	out := filepath.Join(dir, "out")
+	files, err := writeFixtures(recording, out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(out, "001-get-users-42.json"),
+		filepath.Join(out, "002-post-users.json"),
+		filepath.Join(out, "fixtures_test.go"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	var event map[string]interface{}
+	data, _ := ioutil.ReadFile(files[1])
+	if err := json.Unmarshal(data, &event); err != nil || event["body"] != "x" {
+		t.Errorf("expected the recorded event, got %s", data)
+	}
+
+	source, _ := ioutil.ReadFile(files[2])
+	if _, err := parser.ParseFile(token.NewFileSet(), files[2], source, 0); err != nil {
+		t.Errorf("generated Go doesn't parse: %v\n%s", err, source)
+	}
+	if !strings.Contains(string(source), `{"GET /users/42", "001-get-users-42.json", 200, "{\"id\":42}"},`) {
+		t.Errorf("unexpected generated table:\n%s", source)
+	}
+}
@@ -0,0 +1,54 @@
+package invoker
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Headers that only apply to one connection, which proxies don't pass on.
+var hopByHopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// Remove hop-by-hop headers, including any the Connection header names, and
+// add the X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Port headers
+// API Gateway always sends. The client's address is appended to any
+// X-Forwarded-For chain, and a proto or port set by a proxy in front of this
+// one is kept.
+func setForwardedHeaders(r *http.Request) {
+	for _, value := range r.Header["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				r.Header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		r.Header.Del(name)
+	}
+
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if client != "" {
+		if chain := strings.Join(r.Header.Values("X-Forwarded-For"), ", "); chain != "" {
+			client = chain + ", " + client
+		}
+		r.Header.Set("X-Forwarded-For", client)
+	}
+
+	if r.Header.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+		r.Header.Set("X-Forwarded-Proto", proto)
+	}
+	if r.Header.Get("X-Forwarded-Port") == "" {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			if _, port, err := net.SplitHostPort(addr.String()); err == nil {
+				r.Header.Set("X-Forwarded-Port", port)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package invoker
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetForwardedHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.RemoteAddr = "192.0.2.10:51234"
+	r.Header.Set("Connection", "keep-alive, X-Secret-Hop")
+	r.Header.Set("Keep-Alive", "timeout=5")
+	r.Header.Set("X-Secret-Hop", "1")
+	r.Header.Set("Upgrade", "h2c")
+	r.Header.Set("Accept", "application/json")
+	r.Header.Add("X-Forwarded-For", "203.0.113.1")
+	r.Header.Add("X-Forwarded-For", "198.51.100.2")
+	r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}))
+
+	setForwardedHeaders(r)
+	for _, name := range []string{"Connection", "Keep-Alive", "X-Secret-Hop", "Upgrade"} {
+		if value := r.Header.Get(name); value != "" {
+			t.Errorf("expected %v to be removed, got %q", name, value)
+		}
+	}
+	for name, expected := range map[string]string{
+		"Accept":            "application/json",
+		"X-Forwarded-For":   "203.0.113.1, 198.51.100.2, 192.0.2.10",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Port":  "8080",
+	} {
+		if value := r.Header.Get(name); value != expected {
+			t.Errorf("unexpected %v: got %q want %q", name, value, expected)
+		}
+	}
+
+	r = httptest.NewRequest("GET", "/users", nil)
+	r.RemoteAddr = "[2001:db8::1]:443"
+	r.TLS = &tls.ConnectionState{}
+	r.Header.Set("X-Forwarded-Port", "8443")
+	setForwardedHeaders(r)
+	if r.Header.Get("X-Forwarded-For") != "2001:db8::1" || r.Header.Get("X-Forwarded-Proto") != "https" || r.Header.Get("X-Forwarded-Port") != "8443" {
+		t.Errorf("unexpected headers %v", r.Header)
+	}
+}
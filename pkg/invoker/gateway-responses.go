@@ -0,0 +1,110 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A replacement for one type of error the proxy answers with itself, as
+// API Gateway's gateway responses are. An empty statusCode or body keeps
+// those of the error.
+type gatewayResponse struct {
+	StatusCode int               `yaml:"statusCode"`
+	Headers    map[string]string `yaml:"headers"`
+	Body       string            `yaml:"body"`
+}
+
+// The API Gateway response types of the errors the proxy answers with, by
+// status. The rest are DEFAULT_4XX or DEFAULT_5XX, which also stand in for
+// any of these without a response of their own.
+var gatewayResponseTypes = map[int]string{
+	http.StatusBadRequest:            "BAD_REQUEST_BODY",
+	http.StatusUnauthorized:          "UNAUTHORIZED",
+	http.StatusForbidden:             "ACCESS_DENIED",
+	http.StatusNotFound:              "RESOURCE_NOT_FOUND",
+	http.StatusRequestEntityTooLarge: "REQUEST_TOO_LARGE",
+	http.StatusTooManyRequests:       "THROTTLED",
+	http.StatusGatewayTimeout:        "INTEGRATION_TIMEOUT",
+}
+
+func defaultResponseType(status int) string {
+	if status < 500 {
+		return "DEFAULT_4XX"
+	}
+	return "DEFAULT_5XX"
+}
+
+func (gr *gatewayResponse) check(responseType string) error {
+	known := responseType == "DEFAULT_4XX" || responseType == "DEFAULT_5XX"
+	for _, t := range gatewayResponseTypes {
+		known = known || t == responseType
+	}
+	if !known {
+		return fmt.Errorf("unknown gateway response type %v", responseType)
+	}
+	if gr.StatusCode != 0 && (gr.StatusCode < 100 || gr.StatusCode > 599) {
+		return fmt.Errorf("invalid statusCode %v for gateway response %v", gr.StatusCode, responseType)
+	}
+	return nil
+}
+
+// Respond the way API Gateway does when it rejects a request itself, or with
+// the gateway response CONFIG_FILE has for this type of error.
+func gatewayError(w http.ResponseWriter, status int, message string) {
+	if cfg := currentConfigFile(); cfg != nil && len(cfg.GatewayResponses) > 0 {
+		responseType, ok := gatewayResponseTypes[status]
+		gr, found := cfg.GatewayResponses[responseType]
+		if !ok || !found {
+			responseType = defaultResponseType(status)
+			gr, found = cfg.GatewayResponses[responseType]
+		}
+		if found {
+			gr.write(w, responseType, status, message)
+			return
+		}
+	}
+	jsonError(w, status, message)
+}
+
+// Write the response, filling in the context variables API Gateway offers
+// its templates: $context.error.message, $context.error.messageString (the
+// message as a JSON string), $context.error.responseType and
+// $context.requestId.
+func (gr *gatewayResponse) write(w http.ResponseWriter, responseType string, status int, message string) {
+	quoted, _ := json.Marshal(message)
+	replacer := strings.NewReplacer(
+		"$context.error.messageString", string(quoted),
+		"$context.error.message", message,
+		"$context.error.responseType", responseType,
+		"$context.requestId", w.Header().Get("x-amzn-RequestId"),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	for name, value := range gr.Headers {
+		w.Header().Set(name, replacer.Replace(value))
+	}
+	if gr.StatusCode != 0 {
+		status = gr.StatusCode
+	}
+	body := messageBody(message)
+	if gr.Body != "" {
+		body = []byte(replacer.Replace(gr.Body))
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Respond with a JSON message, as API Gateway does for its own errors.
+func jsonError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(messageBody(message))
+}
+
+func messageBody(message string) []byte {
+	body, _ := json.Marshal(struct {
+		Message string `json:"message"`
+	}{message})
+	return body
+}
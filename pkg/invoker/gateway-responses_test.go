@@ -0,0 +1,80 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestGatewayResponses(t *testing.T) {
+	file := writeConfigFile(t, "config*.yaml", `
+gatewayResponses:
+  THROTTLED:
+    statusCode: 503
+    headers:
+      Retry-After: "1"
+      X-Request-Id: $context.requestId
+    body: '{"error": {"type": "$context.error.responseType", "message": $context.error.messageString}}'
+  DEFAULT_4XX:
+    headers:
+      Content-Type: application/problem+json
+`)
+	cfg, err := readConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setConfigFile(cfg)
+	defer setConfigFile(nil)
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set("x-amzn-RequestId", "abc")
+	gatewayError(rr, 429, "Too Many Requests")
+	if rr.Code != 503 || rr.Header().Get("Retry-After") != "1" || rr.Header().Get("X-Request-Id") != "abc" {
+		t.Errorf("unexpected response %v %v", rr.Code, rr.Header())
+	}
+	if rr.Body.String() != `{"error": {"type": "THROTTLED", "message": "Too Many Requests"}}` {
+		t.Errorf("unexpected body %v", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	gatewayError(rr, 404, "Not Found")
+	if rr.Code != 404 || rr.Header().Get("Content-Type") != "application/problem+json" || rr.Body.String() != `{"message":"Not Found"}` {
+		t.Errorf("expected DEFAULT_4XX for RESOURCE_NOT_FOUND, got %v %v %v", rr.Code, rr.Header(), rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	gatewayError(rr, 502, "Internal server error")
+	if rr.Code != 502 || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected the usual response without DEFAULT_5XX, got %v %v", rr.Code, rr.Header())
+	}
+
+	if _, err := readConfigFile(writeConfigFile(t, "config*.yaml", "gatewayResponses:\n  TOO_SLOW:\n    statusCode: 504\n")); err == nil {
+		t.Error("expected an error for an unknown response type")
+	}
+}
+
+func TestGatewayResponsesForProxyErrors(t *testing.T) {
+	setConfigFile(&configFile{GatewayResponses: map[string]gatewayResponse{
+		"DEFAULT_5XX": {Body: `{"error": "$context.error.responseType"}`},
+	}})
+	defer setConfigFile(nil)
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`not a proxy response`)}}}
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/users", nil))
+	if rr.Code != 502 || rr.Body.String() != `{"error": "DEFAULT_5XX"}` {
+		t.Errorf("expected DEFAULT_5XX for a malformed response, got %v %v", rr.Code, rr.Body.String())
+	}
+
+	os.Setenv("INTEGRATION_TIMEOUT", "soon")
+	defer os.Unsetenv("INTEGRATION_TIMEOUT")
+	rr = httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/users", nil))
+	if rr.Code != 500 || rr.Body.String() != `{"error": "DEFAULT_5XX"}` {
+		t.Errorf("expected DEFAULT_5XX for an invalid setting, got %v %v", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,521 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Just enough of GraphQL to run queries against Lambda resolvers: operations,
+// variables, aliases, arguments, fragments and the @include and @skip
+// directives. Without a schema nothing is type checked.
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlSelection
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	defaults   map[string]interface{}
+	selections []*gqlSelection
+}
+
+// A field, a fragment spread when fragment is set, or an inline fragment or
+// fragment definition when typeCondition or selections are set without a
+// name.
+type gqlSelection struct {
+	alias         string
+	name          string
+	arguments     map[string]interface{}
+	directives    []gqlDirective
+	selections    []*gqlSelection
+	fragment      string
+	typeCondition string
+	// The selection set as written, for info.selectionSetGraphQL.
+	selectionText string
+	line, column  int
+}
+
+type gqlDirective struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// A $name in an argument, replaced by its value when the field is resolved.
+type gqlVariable string
+
+type gqlToken struct {
+	kind         byte // 'n'ame, 'v'alue (number), 's'tring, 'p'unctuator or 'e'nd
+	value        string
+	start, end   int
+	line, column int
+}
+
+type gqlParser struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+	token     gqlToken
+}
+
+// Parse a GraphQL document such as "query($id: ID!) { post(id: $id) { title } }".
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{src: strings.TrimPrefix(src, "\ufeff"), line: 1}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	doc := &gqlDocument{fragments: make(map[string]*gqlSelection)}
+	for p.token.kind != 'e' {
+		switch {
+		case p.peek("{"):
+			selections, _, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: selections})
+		case p.token.kind == 'n' && p.token.value == "fragment":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.keyword("on"); err != nil {
+				return nil, err
+			}
+			fragment := &gqlSelection{}
+			if fragment.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+			if fragment.directives, err = p.directives(); err != nil {
+				return nil, err
+			}
+			if fragment.selections, fragment.selectionText, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = fragment
+		case p.token.kind == 'n' && (p.token.value == "query" || p.token.value == "mutation" || p.token.value == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("no operation in document")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: p.token.value, defaults: make(map[string]interface{})}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == 'n' {
+		op.name = p.token.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	// Variable types are skipped, but defaults are kept.
+	if p.peek("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if err := p.skipType(); err != nil {
+				return nil, err
+			}
+			if p.peek("=") {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				if op.defaults[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	var err error
+	op.selections, _, err = p.selectionSet()
+	return op, err
+}
+
+func (p *gqlParser) skipType() error {
+	if p.peek("[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peek("!") {
+		return p.next()
+	}
+	return nil
+}
+
+// A selection set in braces, along with its text.
+func (p *gqlParser) selectionSet() ([]*gqlSelection, string, error) {
+	start := p.token.start
+	if err := p.expect("{"); err != nil {
+		return nil, "", err
+	}
+	var selections []*gqlSelection
+	for !p.peek("}") {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, "", err
+		}
+		selections = append(selections, selection)
+	}
+	text := p.src[start:p.token.end]
+	if err := p.next(); err != nil {
+		return nil, "", err
+	}
+	return selections, text, nil
+}
+
+func (p *gqlParser) selection() (*gqlSelection, error) {
+	selection := &gqlSelection{line: p.token.line, column: p.token.column}
+	var err error
+	if p.peek("...") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.token.kind == 'n' && p.token.value != "on" {
+			selection.fragment = p.token.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			selection.directives, err = p.directives()
+			return selection, err
+		}
+		if p.token.kind == 'n' {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if selection.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if selection.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		selection.selections, selection.selectionText, err = p.selectionSet()
+		return selection, err
+	}
+
+	if selection.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.peek(":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		selection.alias = selection.name
+		if selection.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if selection.arguments, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if selection.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		selection.selections, selection.selectionText, err = p.selectionSet()
+	}
+	return selection, err
+}
+
+func (p *gqlParser) arguments() (map[string]interface{}, error) {
+	arguments := make(map[string]interface{})
+	if !p.peek("(") {
+		return arguments, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arguments[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	return arguments, p.next()
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var directives []gqlDirective
+	for p.peek("@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, gqlDirective{name, arguments})
+	}
+	return directives, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	token := p.token
+	switch {
+	case p.peek("$"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return gqlVariable(name), err
+	case p.peek("["):
+		list := []interface{}{}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek("]") {
+			item, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.next()
+	case p.peek("{"):
+		object := make(map[string]interface{})
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.next()
+	case token.kind == 'v':
+		return json.Number(token.value), p.next()
+	case token.kind == 's':
+		return token.value, p.next()
+	case token.kind == 'n':
+		var value interface{}
+		switch token.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			// Enum values are sent as their names.
+			value = token.value
+		}
+		return value, p.next()
+	}
+	return nil, p.unexpected()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.token.kind != 'n' {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+	return name, p.next()
+}
+
+func (p *gqlParser) keyword(keyword string) error {
+	if p.token.kind != 'n' || p.token.value != keyword {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *gqlParser) peek(punctuator string) bool {
+	return p.token.kind == 'p' && p.token.value == punctuator
+}
+
+func (p *gqlParser) expect(punctuator string) error {
+	if !p.peek(punctuator) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.token.kind == 'e' {
+		return fmt.Errorf("unexpected end of document at line %v, column %v", p.token.line, p.token.column)
+	}
+	return fmt.Errorf("unexpected %q at line %v, column %v", p.src[p.token.start:p.token.end], p.token.line, p.token.column)
+}
+
+// Read the next token, skipping whitespace, commas and comments.
+func (p *gqlParser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\n' {
+			p.pos++
+			p.line, p.lineStart = p.line+1, p.pos
+		} else if c == ' ' || c == '\t' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	start := p.pos
+	p.token = gqlToken{start: start, end: start, line: p.line, column: start - p.lineStart + 1}
+	if p.pos >= len(p.src) {
+		p.token.kind = 'e'
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.token.kind, p.token.value = 'p', "..."
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		p.pos++
+		p.token.kind, p.token.value = 'p', string(c)
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && isNameByte(p.src[p.pos]) {
+			p.pos++
+		}
+		p.token.kind, p.token.value = 'n', p.src[start:p.pos]
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		var number json.Number
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &number); err != nil {
+			return fmt.Errorf("invalid number %q at line %v, column %v", p.src[start:p.pos], p.token.line, p.token.column)
+		}
+		p.token.kind, p.token.value = 'v', string(number)
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		end := p.pos + 3
+		for end < len(p.src) && !strings.HasPrefix(p.src[end:], `"""`) {
+			if strings.HasPrefix(p.src[end:], `\"""`) {
+				end += 3
+			}
+			if p.src[end] == '\n' {
+				p.line, p.lineStart = p.line+1, end+1
+			}
+			end++
+		}
+		if end >= len(p.src) {
+			return fmt.Errorf("unterminated string at line %v, column %v", p.token.line, p.token.column)
+		}
+		raw := p.src[p.pos+3 : end]
+		p.pos = end + 3
+		p.token.kind, p.token.value = 's', blockString(strings.ReplaceAll(raw, `\"""`, `"""`))
+	case c == '"':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' && p.src[p.pos] != '\n' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+			return fmt.Errorf("unterminated string at line %v, column %v", p.token.line, p.token.column)
+		}
+		p.pos++
+		// GraphQL strings escape characters as JSON does.
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &p.token.value); err != nil {
+			return fmt.Errorf("invalid string at line %v, column %v: %v", p.token.line, p.token.column, err)
+		}
+		p.token.kind = 's'
+	default:
+		return fmt.Errorf("unexpected character %q at line %v, column %v", c, p.token.line, p.token.column)
+	}
+	p.token.end = p.pos
+	return nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// The value of a """block string""", without the indentation common to its
+// lines or blank first and last lines.
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,45 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestHeadRequestsUseGetRoute(t *testing.T) {
+	table, err := parseRoutes("GET /reports=reports;HEAD /status=status;GET /status=other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt, _ := table.match("HEAD", "/reports"); rt == nil || rt.Function != "reports" {
+		t.Errorf("HEAD /reports matched unexpected route %+v", rt)
+	}
+	if rt, _ := table.match("HEAD", "/status"); rt == nil || rt.Function != "status" {
+		t.Errorf("HEAD /status matched unexpected route %+v", rt)
+	}
+	if rt, _ := table.match("POST", "/reports"); rt != nil {
+		t.Errorf("POST /reports matched unexpected route %+v", rt)
+	}
+}
+
+func TestHeadResponseHasNoBody(t *testing.T) {
+	var event makeProxyRequest
+	l := LambdaClient{eventLambdaClient{event: &event}}
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("HEAD", "/reports", nil))
+	if event.HTTPMethod != "HEAD" {
+		t.Errorf("function got method %q", event.HTTPMethod)
+	}
+
+	payload := []byte(`{"statusCode":201,"headers":{"ETag":"\"v1\""},"body":"hello"}`)
+	l = LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: payload}}}
+	rr = httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("HEAD", "/reports", nil))
+	if rr.Code != 201 || rr.Header().Get("ETag") != `"v1"` || rr.Header().Get("Content-Length") != "5" {
+		t.Errorf("unexpected response %v %v", rr.Code, rr.Header())
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("unexpected body %q", rr.Body)
+	}
+}
@@ -0,0 +1,147 @@
+package invoker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Changes to make to a set of headers, like API Gateway's parameter
+// mappings. They're applied in the order remove, rename, set and add.
+type headerRules struct {
+	Remove []string          `json:"remove,omitempty" yaml:"remove"`
+	Rename map[string]string `json:"rename,omitempty" yaml:"rename"`
+	Set    map[string]string `json:"set,omitempty" yaml:"set"`
+	Add    map[string]string `json:"add,omitempty" yaml:"add"`
+}
+
+// Parse rules written as a setting, such as
+// "set X-Tenant=acme; remove Cookie; rename X-Old=X-New; add X-Via=proxy".
+// Returns nil for an empty setting.
+func parseHeaderRules(key string, spec string) (*headerRules, error) {
+	rules := &headerRules{}
+	empty := true
+	for _, entry := range strings.Split(spec, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		empty = false
+		parts := strings.SplitN(entry, " ", 2)
+		op, arg := strings.ToLower(parts[0]), ""
+		if len(parts) == 2 {
+			arg = strings.TrimSpace(parts[1])
+		}
+		if op == "remove" {
+			rules.Remove = append(rules.Remove, arg)
+			continue
+		}
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid %v entry %q: must be set, add or rename NAME=VALUE, or remove NAME", key, entry)
+		}
+		name, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var target *map[string]string
+		switch op {
+		case "set":
+			target = &rules.Set
+		case "add":
+			target = &rules.Add
+		case "rename":
+			target = &rules.Rename
+		default:
+			return nil, fmt.Errorf("invalid %v entry %q: unknown operation %v", key, entry, op)
+		}
+		if *target == nil {
+			*target = make(map[string]string)
+		}
+		(*target)[name] = value
+	}
+	if empty {
+		return nil, nil
+	}
+	if err := rules.check(); err != nil {
+		return nil, fmt.Errorf("invalid %v: %v", key, err)
+	}
+	return rules, nil
+}
+
+// Make sure every rule names a header.
+func (rules *headerRules) check() error {
+	if rules == nil {
+		return nil
+	}
+	for _, name := range rules.Remove {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("remove needs a header name")
+		}
+	}
+	for _, names := range []map[string]string{rules.Rename, rules.Set, rules.Add} {
+		for name := range names {
+			if strings.TrimSpace(name) == "" {
+				return fmt.Errorf("header rules need a header name")
+			}
+		}
+	}
+	for from, to := range rules.Rename {
+		if strings.TrimSpace(to) == "" {
+			return fmt.Errorf("rename of %v needs a new name", from)
+		}
+	}
+	return nil
+}
+
+// Apply the rules to h. Rules are nil when there are none.
+func (rules *headerRules) apply(h http.Header) {
+	if rules == nil {
+		return
+	}
+	for _, name := range rules.Remove {
+		h.Del(name)
+	}
+	for from, to := range rules.Rename {
+		if values := h.Values(from); len(values) > 0 {
+			values = append([]string(nil), values...)
+			h.Del(from)
+			h.Del(to)
+			for _, value := range values {
+				h.Add(to, value)
+			}
+		}
+	}
+	for name, value := range rules.Set {
+		h.Set(name, value)
+	}
+	for name, value := range rules.Add {
+		h.Add(name, value)
+	}
+}
+
+// REQUEST_HEADERS and RESPONSE_HEADERS, parsed when the configuration is
+// loaded rather than on every request.
+type configuredHeaderRules struct {
+	request  *headerRules
+	response *headerRules
+}
+
+// Swapped as a whole on reload, like the routes.
+var headerRulesValue atomic.Value
+
+func loadHeaderRules() (configuredHeaderRules, error) {
+	var rules configuredHeaderRules
+	var err error
+	if rules.request, err = parseHeaderRules("REQUEST_HEADERS", getConfig("REQUEST_HEADERS")); err != nil {
+		return rules, err
+	}
+	rules.response, err = parseHeaderRules("RESPONSE_HEADERS", getConfig("RESPONSE_HEADERS"))
+	return rules, err
+}
+
+func currentHeaderRules() configuredHeaderRules {
+	rules, _ := headerRulesValue.Load().(configuredHeaderRules)
+	return rules
+}
+
+func setHeaderRules(rules configuredHeaderRules) {
+	headerRulesValue.Store(rules)
+}
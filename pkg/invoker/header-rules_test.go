@@ -0,0 +1,151 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+func TestParseHeaderRules(t *testing.T) {
+	rules, err := parseHeaderRules("REQUEST_HEADERS", "set X-Tenant=acme; remove Cookie; rename X-Old=X-New; add X-Via=a=b;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &headerRules{
+		Remove: []string{"Cookie"},
+		Rename: map[string]string{"X-Old": "X-New"},
+		Set:    map[string]string{"X-Tenant": "acme"},
+		Add:    map[string]string{"X-Via": "a=b"},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("unexpected rules %+v", rules)
+	}
+
+	if rules, err := parseHeaderRules("REQUEST_HEADERS", " ; "); rules != nil || err != nil {
+		t.Errorf("expected no rules, got %+v %v", rules, err)
+	}
+	for _, spec := range []string{"set X-Tenant", "move A=B", "remove", "rename A="} {
+		if _, err := parseHeaderRules("REQUEST_HEADERS", spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestHeaderRulesApply(t *testing.T) {
+	h := http.Header{}
+	h.Set("Cookie", "session=1")
+	h.Add("X-Old", "1")
+	h.Add("X-Old", "2")
+	h.Set("X-Tenant", "other")
+	h.Set("X-Via", "client")
+	(&headerRules{
+		Remove: []string{"cookie"},
+		Rename: map[string]string{"x-old": "X-New"},
+		Set:    map[string]string{"X-Tenant": "acme"},
+		Add:    map[string]string{"X-Via": "proxy"},
+	}).apply(h)
+	expected := http.Header{
+		"X-New":    {"1", "2"},
+		"X-Tenant": {"acme"},
+		"X-Via":    {"client", "proxy"},
+	}
+	if !reflect.DeepEqual(h, expected) {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	var none *headerRules
+	none.apply(h)
+}
+
+type eventLambdaClient struct {
+	lambdaiface.LambdaAPI
+	event *makeProxyRequest
+}
+
+func (m eventLambdaClient) InvokeWithContext(_ aws.Context, in *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	if err := json.Unmarshal(in.Payload, m.event); err != nil {
+		return nil, err
+	}
+	return &lambda.InvokeOutput{Payload: []byte(`{"statusCode":200}`)}, nil
+}
+
+func TestRequestHeaderRules(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	os.Setenv("REQUEST_HEADERS", "set X-Tenant=acme; add X-Via=global")
+	defer os.Unsetenv("REQUEST_HEADERS")
+	rules, err := loadHeaderRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setHeaderRules(rules)
+	defer setHeaderRules(configuredHeaderRules{})
+	table, err := parseRoutes("/reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table[0].RequestHeaders = &headerRules{Remove: []string{"Cookie"}, Add: map[string]string{"X-Via": "route"}}
+
+	var event makeProxyRequest
+	l := LambdaClient{eventLambdaClient{event: &event}}
+	req := httptest.NewRequest("GET", "/reports", nil)
+	req.Header.Set("Cookie", "session=1")
+	l.invokeRoute(httptest.NewRecorder(), req, table[0], nil)
+
+	if _, ok := event.Headers["Cookie"]; ok {
+		t.Error("expected the route to remove Cookie")
+	}
+	if event.Headers["X-Tenant"] != "acme" || !strings.Contains(event.Headers["X-Via"], "global") || !strings.Contains(event.Headers["X-Via"], "route") {
+		t.Errorf("unexpected headers %v", event.Headers)
+	}
+
+	rt := &route{Path: "/reports", RequestHeaders: &headerRules{Rename: map[string]string{"X-Old": ""}}}
+	if err := rt.compile(); err == nil {
+		t.Error("expected an error for a rename without a new name")
+	}
+}
+
+func TestResponseHeaderRules(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	os.Setenv("RESPONSE_HEADERS", "set X-Frame-Options=DENY; remove X-Powered-By")
+	defer os.Unsetenv("RESPONSE_HEADERS")
+	rules, err := loadHeaderRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setHeaderRules(rules)
+	defer setHeaderRules(configuredHeaderRules{})
+	rt := &route{Path: "/assets/{proxy+}", ResponseHeaders: &headerRules{Set: map[string]string{"Cache-Control": "public, max-age=86400"}}}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"statusCode":200,"headers":{"X-Powered-By":"Express","Cache-Control":"no-store","X-Kept":"1"},"body":"ok"}`)
+	rr := httptest.NewRecorder()
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: payload}}}
+	l.invokeRoute(rr, httptest.NewRequest("GET", "/assets/app.js", nil), rt, nil)
+
+	for name, expected := range map[string]string{
+		"X-Frame-Options": "DENY",
+		"X-Powered-By":    "",
+		"Cache-Control":   "public, max-age=86400",
+		"X-Kept":          "1",
+	} {
+		if value := rr.Header().Get(name); value != expected {
+			t.Errorf("unexpected %v: got %q want %q", name, value, expected)
+		}
+	}
+	if rr.Body.String() != "ok" {
+		t.Errorf("unexpected body %q", rr.Body)
+	}
+}
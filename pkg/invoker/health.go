@@ -0,0 +1,36 @@
+package invoker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Served by the proxy itself rather than passed to the function.
+const healthPath = "/__invoker/health"
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"status":"ok"}`)
+}
+
+// Ask a running proxy at baseURL whether it is healthy. Used by the
+// healthcheck subcommand since the image has no curl or wget.
+func healthcheck(baseURL string) error {
+	client := http.Client{
+		Timeout: 5 * time.Second,
+		// We're checking our own listener, which may well use a self-signed certificate.
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Get(baseURL + healthPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %v", resp.Status)
+	}
+	return nil
+}
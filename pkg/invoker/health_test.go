@@ -0,0 +1,35 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthcheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, healthHandler)
+	server := httptest.NewServer(mux)
+
+	if err := healthcheck(server.URL); err != nil {
+		t.Errorf("expected a healthy proxy, got %v", err)
+	}
+
+	server.Close()
+	if err := healthcheck(server.URL); err == nil {
+		t.Error("expected the health check to fail once the proxy is gone")
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	versionHandler(rr, httptest.NewRequest("GET", versionPath, nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %v", ct)
+	}
+	expected := `{"version":"dev","commit":"unknown","date":"unknown"}` + "\n"
+	if b := rr.Body.String(); b != expected {
+		t.Errorf("unexpected body: got %v want %v", b, expected)
+	}
+}
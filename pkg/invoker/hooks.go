@@ -0,0 +1,124 @@
+package invoker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// What a hook is sent and what it answers with. A pre-invoke hook is sent the
+// event and may return a changed event, or a response to send instead of
+// invoking the function. A post-invoke hook is also sent the function's
+// response and may return a changed one. Leaving a field out, or not writing
+// anything at all, keeps it as it was.
+type hookMessage struct {
+	Event    json.RawMessage `json:"event,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// Run the hook a setting such as PRE_INVOKE_HOOK names: an http or https URL
+// to POST the message to, or else a command for sh to run with the message
+// on stdin. Returns nil when the setting is empty.
+func runHook(ctx context.Context, key string, message hookMessage) (*hookMessage, error) {
+	spec := getConfig(key)
+	if spec == "" {
+		return nil, nil
+	}
+	timeout, err := getConfigDuration("HOOK_TIMEOUT")
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	input, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var output []byte
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		output, err = postHook(ctx, spec, input)
+	} else {
+		output, err = execHook(ctx, spec, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v failed: %v", key, err)
+	}
+	result := &hookMessage{}
+	if len(bytes.TrimSpace(output)) > 0 {
+		if err := json.Unmarshal(output, result); err != nil {
+			return nil, fmt.Errorf("%v returned invalid JSON: %v", key, err)
+		}
+	}
+	return result, nil
+}
+
+func postHook(ctx context.Context, url string, input []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	output, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(output))
+	}
+	return output, nil
+}
+
+func execHook(ctx context.Context, command string, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%v: %v", err, message)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// Give PRE_INVOKE_HOOK the event. Returns the event to send, which the hook
+// may have changed, or the response it answered with instead.
+func preInvokeHook(ctx context.Context, event []byte) ([]byte, []byte, error) {
+	result, err := runHook(ctx, "PRE_INVOKE_HOOK", hookMessage{Event: event})
+	if err != nil || result == nil {
+		return event, nil, err
+	}
+	if result.Response != nil {
+		return event, result.Response, nil
+	}
+	if result.Event != nil {
+		event = result.Event
+	}
+	return event, nil, nil
+}
+
+// Give POST_INVOKE_HOOK the event and the function's response. Returns the
+// response to send, which the hook may have changed.
+func postInvokeHook(ctx context.Context, event []byte, response []byte) ([]byte, error) {
+	result, err := runHook(ctx, "POST_INVOKE_HOOK", hookMessage{Event: event, Response: response})
+	if err != nil || result == nil || result.Response == nil {
+		return response, err
+	}
+	return result.Response, nil
+}
@@ -0,0 +1,71 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestPreInvokeWebhook(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct{ Event makeProxyRequest }
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+		message.Event.Headers["X-User"] = "alice"
+		json.NewEncoder(w).Encode(message)
+	}))
+	defer webhook.Close()
+	os.Setenv("PRE_INVOKE_HOOK", webhook.URL)
+	defer os.Unsetenv("PRE_INVOKE_HOOK")
+
+	var event makeProxyRequest
+	l := LambdaClient{eventLambdaClient{event: &event}}
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/reports", nil))
+	if event.Headers["X-User"] != "alice" || event.Path != "/reports" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestPreInvokeHookRejects(t *testing.T) {
+	os.Setenv("PRE_INVOKE_HOOK", `cat >/dev/null; echo '{"response":{"statusCode":403,"body":"denied"}}'`)
+	defer os.Unsetenv("PRE_INVOKE_HOOK")
+
+	var event makeProxyRequest
+	l := LambdaClient{eventLambdaClient{event: &event}}
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/reports", nil))
+	if rr.Code != 403 || rr.Body.String() != "denied" || event.Path != "" {
+		t.Errorf("unexpected response %v %q, function got %+v", rr.Code, rr.Body, event)
+	}
+
+	os.Setenv("PRE_INVOKE_HOOK", "echo nope >&2; exit 3")
+	rr = httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/reports", nil))
+	if rr.Code != 500 || event.Path != "" {
+		t.Errorf("unexpected response to a failed hook %v %q", rr.Code, rr.Body)
+	}
+}
+
+func TestPostInvokeHook(t *testing.T) {
+	os.Setenv("POST_INVOKE_HOOK", `grep -q '"path":"/reports"' && echo '{"response":{"statusCode":200,"body":"changed"}}'`)
+	defer os.Unsetenv("POST_INVOKE_HOOK")
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200,"body":"original"}`)}}}
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/reports", nil))
+	if rr.Body.String() != "changed" {
+		t.Errorf("unexpected body %q", rr.Body)
+	}
+
+	os.Setenv("POST_INVOKE_HOOK", "cat >/dev/null")
+	rr = httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/reports", nil))
+	if rr.Body.String() != "original" {
+		t.Errorf("expected the response unchanged, got %q", rr.Body)
+	}
+}
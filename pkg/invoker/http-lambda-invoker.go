@@ -0,0 +1,863 @@
+package invoker
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	awsrequest "github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LambdaClient enables mocking of the client for test purposes
+type LambdaClient struct {
+	lambdaiface.LambdaAPI
+}
+
+type proxyHeader map[string]string
+
+// Parts of the request to send to Lambda.
+type makeProxyRequest struct {
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+	Headers           proxyHeader         `json:"headers"`
+	HTTPMethod        string              `json:"httpMethod"`
+	Path              string              `json:"path"`
+	PathParameters    map[string]string   `json:"pathParameters"`
+	QueryStringParams map[string][]string `json:"queryStringParameters"`
+	RequestContext    proxyRequestContext `json:"requestContext"`
+}
+
+// Parts of the response to send back to the caller.
+type restResponse struct {
+	Body       string
+	Headers    map[string]string
+	StatusCode int
+	Cookies    []string
+}
+
+// Set some defaults for envvars. Command line flags, or settings given to New,
+// take precedence over envvars, which take precedence over CONFIG_FILE.
+// Access key and secret should normally be ignored as we're calling a local function.
+func getConfig(key string) string {
+	if c := flagSettings[key]; c != "" {
+		return c
+	}
+	if c := embeddedSettings[key]; c != "" {
+		return c
+	}
+	c := os.Getenv(key)
+	if c != "" {
+		return c
+	}
+	if c = fileSetting(key); c != "" {
+		return c
+	}
+	switch key {
+	case "AWS_CREDENTIALS":
+		return "static"
+	case "AWS_ACCESS_KEY_ID":
+		return "foo"
+	case "AWS_SECRET_ACCESS_KEY":
+		return "bar"
+	case "ASSUME_ROLE_SESSION_NAME":
+		return "http-lambda-invoker"
+	case "AWS_REGION":
+		return endpoints.UsEast1RegionID
+	case "PORT":
+		return "8080"
+	case "INTEGRATION_TIMEOUT":
+		return "29s"
+	case "MAX_REQUEST_SIZE":
+		return "10485760"
+	case "MAX_RESPONSE_SIZE":
+		return "6291556"
+	case "SHUTDOWN_TIMEOUT":
+		return "10s"
+	case "LOG_LEVEL":
+		return "info"
+	case "LOG_FORMAT", "ACCESS_LOG":
+		return "json"
+	case "CORRELATION_ID_HEADER":
+		return "X-Correlation-Id"
+	case "OTEL_SERVICE_NAME":
+		return "http-lambda-invoker"
+	case "STATSD_PORT":
+		return "8125"
+	case "STATSD_PREFIX":
+		return "http_lambda_invoker."
+	case "IDEMPOTENCY_HEADER":
+		return "Idempotency-Key"
+	case "DEFAULT_CONTENT_TYPE":
+		return "application/json"
+	case "PAYLOAD_FORMAT_VERSION":
+		return "1.0"
+	case "REPLAY_FALLBACK":
+		return "error"
+	case "DASHBOARD_SIZE":
+		return "50"
+	case "DISCOVER_TAG":
+		return "http-route"
+	case "MIDDLEWARE":
+		return strings.Join(builtinMiddlewares, ",")
+	case "HOOK_TIMEOUT":
+		return "5s"
+	case "CHAOS_LATENCY":
+		return "1s"
+	case "CHAOS_ERROR_STATUS":
+		return "502"
+	case "TLS_SANS":
+		return "localhost,127.0.0.1,::1"
+	default:
+		return ""
+	}
+}
+
+// Read a numeric setting. Unset means zero.
+func getConfigInt(key string) (int, error) {
+	c := getConfig(key)
+	if c == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(c)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v %q: %v", key, c, err)
+	}
+	return n, nil
+}
+
+// Read a duration setting such as "29s". Unset or "0" means zero.
+func getConfigDuration(key string) (time.Duration, error) {
+	c := getConfig(key)
+	if c == "" || c == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v %q: %v", key, c, err)
+	}
+	return d, nil
+}
+
+// Read an on/off setting such as "true" or "1". Unset means false.
+func getConfigBool(key string) (bool, error) {
+	c := getConfig(key)
+	if c == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(c)
+	if err != nil {
+		return false, fmt.Errorf("invalid %v %q: %v", key, c, err)
+	}
+	return b, nil
+}
+
+func makeProxyHeaders(originalHeaders map[string][]string) proxyHeader {
+	var newHeaders = getProxyHeaders()
+
+	for header := range originalHeaders {
+		newHeaders[header] = strings.Join(originalHeaders[header], "")
+	}
+
+	return newHeaders
+}
+
+// Answer a request the proxy couldn't handle because of its own settings,
+// such as an invalid timeout or a client it couldn't make, with a 500 as API
+// Gateway answers a misconfigured integration. The error is only logged.
+func handleError(w http.ResponseWriter, err error) {
+	logError("Request failed", logFields{"error": err})
+	gatewayError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	// GraphQL requests go to their resolvers, as AppSync would send them.
+	if path := getConfig("GRAPHQL_PATH"); path != "" && r.URL.Path == path {
+		c, err := getLambdaClient()
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		c.serveGraphQL(w, r)
+		return
+	}
+	// Find any route settings and path parameters.
+	rt, pathParameters := currentRoutes().match(r.Method, r.URL.Path)
+	// In hybrid mode anything the local routes don't handle goes upstream.
+	if fallback := getConfig("FALLBACK_URL"); rt == nil && fallback != "" {
+		forwardToFallback(w, r, fallback)
+		return
+	}
+	// Without LAMBDA_NAME only the routes lead anywhere, as in API Gateway.
+	if rt == nil && getConfig("LAMBDA_NAME") == "" && currentReplay() == nil {
+		gatewayError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	c, err := lambdaClientFor(rt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	c.invokeRoute(w, r, rt, pathParameters)
+}
+
+func (c *LambdaClient) invokeLambda(w http.ResponseWriter, r *http.Request) {
+	rt, pathParameters := currentRoutes().match(r.Method, r.URL.Path)
+	c.invokeRoute(w, r, rt, pathParameters)
+}
+
+func (c *LambdaClient) invokeRoute(w http.ResponseWriter, r *http.Request, rt *route, pathParameters map[string]string) {
+	// Error handling seems really verbose. Is there a better way?
+
+	// Read request body, refusing anything bigger than API Gateway would accept.
+	maxRequestSize, err := getConfigInt("MAX_REQUEST_SIZE")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	body, err := readBody(w, r, int64(maxRequestSize))
+	if err != nil {
+		if err == errRequestTooLarge {
+			gatewayError(w, http.StatusRequestEntityTooLarge, "Request Too Long")
+			return
+		}
+		logWarn("Request body could not be read", logFields{"path": r.URL.Path, "error": err})
+		gatewayError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer putBuffer(body)
+
+	// Decompress the body if the route asks for it, before anything looks
+	// inside.
+	encoding := requestEncoding(rt)
+	if encoding == requestEncodingDecompress {
+		decoded, err := decompressBody(r, body, int64(maxRequestSize))
+		switch {
+		case err == errRequestTooLarge:
+			gatewayError(w, http.StatusRequestEntityTooLarge, "Request Too Long")
+			return
+		case err == errUnsupportedEncoding:
+			gatewayError(w, http.StatusUnsupportedMediaType, "Unsupported Media Type")
+			return
+		case err != nil:
+			logWarn("Request body could not be decompressed", logFields{"path": r.URL.Path, "encoding": r.Header.Get("Content-Encoding"), "error": err})
+			gatewayError(w, http.StatusBadRequest, "Invalid compressed request body")
+			return
+		}
+		if decoded != body {
+			defer putBuffer(decoded)
+			body = decoded
+		}
+	}
+
+	// Reject the request as an API Gateway request validator would.
+	if rt != nil && rt.validation != nil {
+		if message, err := rt.validation.check(r, body.Bytes()); message != "" {
+			logWarn("Request failed validation", logFields{"path": r.URL.Path, "route": rt.Path, "message": message, "error": err})
+			gatewayError(w, http.StatusBadRequest, message)
+			return
+		}
+	}
+
+	// Pass the X-Ray trace on, as API Gateway does.
+	trace := traceHeader(r)
+	r.Header.Set(traceHeaderName, trace)
+	setForwardedHeaders(r)
+
+	// Change headers as configured, as API Gateway parameter mappings would.
+	currentHeaderRules().request.apply(r.Header)
+	if rt != nil {
+		rt.RequestHeaders.apply(r.Header)
+	}
+
+	// Convert headers to appropriate ApiGateway format
+	proxyHeaders := makeProxyHeaders(r.Header)
+	defer putProxyHeaders(proxyHeaders)
+
+	// Get struct.
+	encodedBody, isBase64Encoded := eventBody(r.Header.Get("Content-Type"), r.Header.Get("Content-Encoding"), encoding, body.Bytes())
+	request := makeProxyRequest{
+		Body:              encodedBody,
+		IsBase64Encoded:   isBase64Encoded,
+		Headers:           proxyHeaders,
+		HTTPMethod:        r.Method,
+		Path:              r.URL.Path,
+		PathParameters:    pathParameters,
+		QueryStringParams: r.URL.Query(),
+		RequestContext:    makeRequestContext(r),
+	}
+
+	// Let plugins change the event.
+	if err := transformRequest(&request); err != nil {
+		logError("Plugin failed", logFields{"request_id": request.RequestContext.RequestID, "path": r.URL.Path, "error": err})
+		gatewayError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Marshal request, in the payload format of the route.
+	var event interface{} = request
+	version := payloadFormatVersion(rt)
+	if version == "2.0" {
+		event = makeHTTPAPIRequest(r, rt, &request)
+	}
+	payloadBuffer := getBuffer()
+	defer putBuffer(payloadBuffer)
+	if err := json.NewEncoder(payloadBuffer).Encode(event); err != nil {
+		handleError(w, err)
+		return
+	}
+	payload := payloadBuffer.Bytes()
+
+	// Let PRE_INVOKE_HOOK change the event, or answer in the function's place.
+	payload, rejection, err := preInvokeHook(r.Context(), payload)
+	if err != nil {
+		logError("Hook failed", logFields{"request_id": request.RequestContext.RequestID, "path": r.URL.Path, "error": err})
+		gatewayError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if rejection != nil {
+		writeResponse(w, r, rt, rejection, logFields{"request_id": request.RequestContext.RequestID, "method": r.Method, "path": r.URL.Path, "hook": "PRE_INVOKE_HOOK"})
+		return
+	}
+
+	// Show the event instead of invoking the function.
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if dryRun {
+		logInfo("Dry run", logFields{"request_id": request.RequestContext.RequestID, "path": r.URL.Path, "event": json.RawMessage(payload)})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(payload)
+		return
+	}
+
+	// Give up on the integration after the timeout, as API Gateway would, or as
+	// soon as the client goes away.
+	timeout, err := getConfigDuration("INTEGRATION_TIMEOUT")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if rt != nil && rt.timeout > 0 {
+		timeout = rt.timeout
+	}
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Or start the route's state machine, as a Step Functions integration
+	// would.
+	if rt != nil && rt.StateMachineArn != "" {
+		setRequestFunction(r, rt, rt.StateMachineArn)
+		input := body.Bytes()
+		if rt.StateMachineInput == "event" {
+			input = payload
+		}
+		fields := logFields{"request_id": request.RequestContext.RequestID, "method": r.Method, "path": r.URL.Path, "route": rt.Path, "trace_id": trace}
+		startStateMachine(ctx, w, r, rt, input, trace, fields)
+		return
+	}
+
+	// Invoke Lambda.
+	function := getConfig("LAMBDA_NAME")
+	if rt != nil && rt.Function != "" {
+		function = rt.Function
+	}
+	canary := rt.pickCanary()
+	if canary {
+		function = rt.CanaryFunction
+	}
+	setRequestFunction(r, rt, function)
+	traceRoute(r, rt, function)
+	fields := logFields{"request_id": request.RequestContext.RequestID, "method": r.Method, "path": r.URL.Path, "function": function}
+	if id := correlationID(r); id != "" {
+		fields["correlation_id"] = id
+	}
+	fields["trace_id"] = trace
+	if rt != nil && rt.Path != "" {
+		fields["route"] = rt.Path
+	}
+	if canary {
+		fields["canary"] = true
+	}
+	// Answer from REPLAY_FILE instead, when replaying.
+	if table := currentReplay(); table != nil {
+		if replayed, ok := table.lookup(r.Method, r.URL.Path); ok {
+			fields["replayed"] = true
+			if version == "2.0" {
+				replayed = httpAPIResponse(replayed)
+			}
+			writeResponse(w, r, rt, replayed, fields)
+			return
+		}
+		if getConfig("REPLAY_FALLBACK") != "invoke" {
+			logWarn("No recorded exchange", fields)
+			gatewayError(w, http.StatusNotFound, fmt.Sprintf("No recorded exchange for %v %v", r.Method, r.URL.Path))
+			return
+		}
+	}
+
+	// Send the output on as it arrives from functions that stream it.
+	streaming, err := isStreaming(rt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if streaming {
+		c.streamRoute(w, r, rt, function, payload, trace, timeout, fields)
+		return
+	}
+
+	debugPayload("Invoking function", payload, fields)
+	invokeSpan := startSpan(ctx, "Lambda.Invoke", spanKindClient)
+	invokeSpan.set("rpc.system", "aws-api")
+	invokeSpan.set("rpc.service", "Lambda")
+	invokeSpan.set("rpc.method", "Invoke")
+	invokeSpan.set("faas.invoked_name", function)
+	input := &lambda.InvokeInput{FunctionName: aws.String(function), Payload: payload}
+	tailLogs, err := getConfigBool("LAMBDA_TAIL_LOGS")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if tailLogs {
+		input.LogType = aws.String(lambda.LogTypeTail)
+	}
+	start := time.Now()
+	result, err := c.InvokeWithContext(ctx, input,
+		awsrequest.WithSetRequestHeaders(map[string]string{traceHeaderName: trace}))
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil || result.FunctionError != nil {
+		invokeSpan.fail()
+	}
+	if err == nil {
+		if report := parseReport(result.LogResult); report != nil {
+			invokeSpan.set("faas.coldstart", report.ColdStart())
+			setReport(w, r, report, fields)
+		}
+	}
+	invokeSpan.finish()
+	if err != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			logWarn("Endpoint request timed out", fields)
+			gatewayError(w, http.StatusGatewayTimeout, "Endpoint request timed out")
+			return
+		case context.Canceled:
+			// Nobody is left to respond to.
+			logInfo("Client disconnected, cancelled invocation", fields)
+			return
+		}
+		fields["error"] = err
+		logError("Invocation failed", fields)
+		gatewayError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	debugPayload("Function returned", result.Payload, fields)
+	// Mirror the event to any shadow function, with its own copies of what
+	// the pools will reuse.
+	if shadow := shadowFunction(rt); shadow != "" {
+		shadowFields := make(logFields, len(fields))
+		for k, v := range fields {
+			shadowFields[k] = v
+		}
+		go c.mirrorInvocation(shadow, append([]byte(nil), payload...), result.Payload, trace, timeout, shadowFields)
+	}
+	// The dashboard keeps the event, so it gets a copy of the pooled buffer.
+	exchange := recordedExchange{
+		Time:      start.UTC(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Function:  function,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Event:     recordedPayload(append([]byte(nil), payload...)),
+		Response:  recordedPayload(result.Payload),
+	}
+	recentRequests.add(exchange)
+	if file := getConfig("RECORD_FILE"); file != "" {
+		if err := recordExchange(file, exchange); err != nil {
+			logError("Failed to record exchange", logFields{"file": file, "error": err})
+		}
+	}
+
+	// Let POST_INVOKE_HOOK change the response.
+	response := result.Payload
+	if version == "2.0" {
+		response = httpAPIResponse(response)
+	}
+	response, err = postInvokeHook(r.Context(), payload, response)
+	if err != nil {
+		fields["error"] = err
+		logError("Hook failed", fields)
+		gatewayError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	// And then plugins.
+	if response, err = transformResponse(&request, response); err != nil {
+		fields["error"] = err
+		logError("Plugin failed", fields)
+		gatewayError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	writeResponse(w, r, rt, response, fields)
+}
+
+// Turn the function's payload into the HTTP response, as API Gateway's proxy
+// integration does, with any header changes for rt.
+func writeResponse(w http.ResponseWriter, r *http.Request, rt *route, payload []byte, fields logFields) {
+	// Lambda refuses to return oversized payloads, which API Gateway reports as a 502.
+	maxResponseSize, err := getConfigInt("MAX_RESPONSE_SIZE")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if maxResponseSize > 0 && len(payload) > maxResponseSize {
+		fields["size"] = len(payload)
+		logError("Response payload size exceeded maximum allowed payload size", fields)
+		gatewayError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	var response restResponse
+
+	// Unmarshal response into `response`, which API Gateway reports as a 502
+	// when the function returned something else.
+	if err := json.Unmarshal(payload, &response); err != nil {
+		fields["error"] = err
+		logError("Malformed Lambda proxy response", fields)
+		gatewayError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	// Add headers to ResponseWriter omitting content-length, which can come
+	// back with the wrong length. It's worked out again from the body below.
+	for key, value := range response.Headers {
+		if !strings.EqualFold(key, "Content-Length") {
+			w.Header().Set(key, value)
+		}
+	}
+	// Browsers guess at responses without a Content-Type, so send one anyway,
+	// as API Gateway does.
+	if bodyAllowed(response.StatusCode) && w.Header().Get("Content-Type") == "" {
+		if contentType := defaultContentType(r); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
+	// Cookies from functions using payload format 2.0.
+	for _, cookie := range response.Cookies {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+	setResponseHeaders(w, rt)
+	// Write status code and body with its actual length rather than falling
+	// back to chunked encoding. HEAD responses keep the headers, including the
+	// length the body would have had, but not the body itself.
+	if bodyAllowed(response.StatusCode) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+	}
+	w.WriteHeader(response.StatusCode)
+	if r.Method != http.MethodHead {
+		io.WriteString(w, response.Body)
+	}
+	fields["status"] = response.StatusCode
+	logDebug("Invoked function", fields)
+}
+
+// Change the response headers as configured, as API Gateway response
+// mappings would.
+func setResponseHeaders(w http.ResponseWriter, rt *route) {
+	currentHeaderRules().response.apply(w.Header())
+	if rt != nil {
+		rt.ResponseHeaders.apply(w.Header())
+	}
+}
+
+// Whether a response with this status can have a body, and so a
+// Content-Length.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Main runs the http-lambda-invoker command: it reads the flags, environment
+// and configuration files, then starts a web server on the configured port,
+// sending all traffic to handler.
+func Main() {
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	waitForEndpoint := flag.Duration("wait-for-endpoint", 0, "keep retrying the startup check of LAMBDA_ENDPOINT for this long")
+	goTests := flag.Bool("go-tests", false, "with fixtures, also write a Go table of the recorded events")
+	registerSettingFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %v [flags] [healthcheck|validate|openapi|fixtures RECORDING DIR]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	if flag.Arg(0) == "fixtures" {
+		if flag.NArg() != 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		files, err := writeFixtures(flag.Arg(1), flag.Arg(2), *goTests)
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// DOTENV_FILE can't come from the .env file itself.
+	dotEnvFile := getConfig("DOTENV_FILE")
+	required := dotEnvFile != ""
+	if !required {
+		dotEnvFile = ".env"
+	}
+	if err := loadDotEnv(dotEnvFile, required); err != nil {
+		log.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if err := configureLogging(); err != nil {
+		log.Fatal(err)
+	}
+
+	if flag.Arg(0) == "validate" {
+		if err := validateConfig(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid")
+		return
+	}
+	if flag.Arg(0) == "openapi" {
+		doc, _ := json.MarshalIndent(openAPIFromRoutes(currentRoutes()), "", "  ")
+		fmt.Println(string(doc))
+		return
+	}
+
+	var Host = getConfig("HOST")
+	var Port = getConfig("PORT")
+	useTLS, err := tlsEnabled()
+	if err != nil {
+		log.Fatal(err)
+	}
+	listenerSpecs, err := parseListeners(getConfig("LISTEN"), Host, Port, useTLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	redirectPort := getConfig("HTTPS_REDIRECT_PORT")
+	var toPort string
+	if redirectPort != "" {
+		if toPort, err = httpsPort(listenerSpecs); err != nil {
+			log.Fatal(err)
+		}
+		listenerSpecs = append(listenerSpecs, listenerSpec{"tcp", listenAddress(Host, redirectPort), false})
+	}
+	if flag.Arg(0) == "healthcheck" {
+		url, err := healthcheckURL(listenerSpecs, getConfig("ADMIN_ADDRESS"), clientAuthMode() == "require")
+		if err == nil {
+			err = healthcheck(url)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := validateConfig(); err != nil {
+		log.Fatal(err)
+	}
+	c, err := getLambdaClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if name := getConfig("LAMBDA_NAME"); name != "" && !replayOnly() {
+		if err := c.waitForFunction(name, *waitForEndpoint); err != nil {
+			log.Fatal(err)
+		}
+	}
+	warmInterval, err := getConfigDuration("WARM_INTERVAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if warmInterval > 0 {
+		go runWarmer(ctx, warmInterval, warmFunctions())
+	}
+	if getConfig("CONFIG_FILE") != "" {
+		go runScheduler(ctx)
+	}
+	discoverInterval, err := getConfigDuration("DISCOVER_INTERVAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if discoverInterval > 0 {
+		go runDiscovery(ctx, discoverInterval, getConfig("DISCOVER_TAG"))
+	}
+	watch, err := getConfigBool("WATCH_CONFIG")
+	if err != nil {
+		log.Fatal(err)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var poll time.Duration
+	if watch {
+		poll = 2 * time.Second
+	}
+	go watchConfig(ctx, hup, poll)
+	var exporter *spanExporter
+	if endpoint := getConfig("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		exporter = newSpanExporter(endpoint, getConfig("OTEL_SERVICE_NAME"))
+		go exporter.run(5 * time.Second)
+		defer exporter.stop()
+	}
+	if plugins, err = loadPlugins(getConfig("PLUGINS")); err != nil {
+		log.Fatal(err)
+	}
+	invoke, err := newHandler(exporter, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, healthHandler)
+	mux.HandleFunc(versionPath, versionHandler)
+	mux.Handle("/", invoke)
+	drainTimeout, err := getConfigDuration("SHUTDOWN_TIMEOUT")
+	if err != nil {
+		log.Fatal(err)
+	}
+	withReusePort, err := getConfigBool("REUSE_PORT")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if withReusePort {
+		listenConfig.Control = reusePort
+	}
+	withPprof, err := getConfigBool("PPROF")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if adminAddress := getConfig("ADMIN_ADDRESS"); adminAddress != "" {
+		dashboardSize, err := getConfigInt("DASHBOARD_SIZE")
+		if err != nil {
+			log.Fatal(err)
+		}
+		var dashboard http.Handler
+		if dashboardSize > 0 {
+			recentRequests = newRecentExchanges(dashboardSize)
+			dashboard = newDashboard(recentRequests, lambdaClientFor)
+		}
+		admin, err := startAdminServer(adminAddress, newAdminMux(withPprof, dashboard))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer admin.Close()
+	} else if withPprof {
+		log.Fatal("PPROF needs ADMIN_ADDRESS")
+	}
+
+	// APIs in CONFIG_FILE get listeners of their own.
+	var apis []*api
+	if cfg := currentConfigFile(); cfg != nil {
+		apis = cfg.APIs
+	}
+	allSpecs := listenerSpecs
+	apiSpecs := make(map[string][]listenerSpec)
+	for _, a := range apis {
+		specs, err := parseListeners(a.Listen, Host, Port, useTLS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiSpecs[a.Name] = specs
+		allSpecs = append(allSpecs, specs...)
+	}
+	tlsConfig, err := loadTLSConfig(needsTLS(allSpecs))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Sockets passed by systemd replace the proxy's own listeners.
+	var activationTLS *tls.Config
+	if useTLS {
+		activationTLS = tlsConfig
+	}
+	listeners, err := activatedListeners(activationTLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(listeners) == 0 {
+		if listeners, err = openListeners(listenerSpecs, tlsConfig); err != nil {
+			log.Fatal(err)
+		}
+	}
+	apiHandlers := make(map[string]http.Handler)
+	for _, a := range apis {
+		handler, err := newAPIHandler(a.Name, exporter)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opened, err := openListeners(apiSpecs[a.Name], tlsConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, ln := range opened {
+			apiHandlers[listenerKey(ln.Addr())] = handler
+		}
+		listeners = append(listeners, opened...)
+	}
+	srv := &http.Server{Handler: dispatchAPIs(apiHandlers, mux)}
+	useH2C, err := getConfigBool("H2C")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if useH2C {
+		srv.Handler = allowH2C(srv.Handler)
+	}
+	if redirectPort != "" {
+		srv.Handler = redirectToHTTPS(srv.Handler, redirectPort, toPort)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	if err := sdNotify("READY=1"); err != nil {
+		logWarn("Failed to notify systemd", logFields{"error": err})
+	}
+	if err := serve(srv, listeners, drainTimeout, stop); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// The handler for requests to functions, wrapped in the middlewares
+// MIDDLEWARE lists, such as throttling, metrics and tracing with exporter,
+// and any custom ones.
+func newHandler(exporter *spanExporter, custom map[string]Middleware) (http.Handler, error) {
+	return chainMiddleware(http.HandlerFunc(handler), exporter, custom)
+}
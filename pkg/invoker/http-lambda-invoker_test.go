@@ -1,13 +1,16 @@
-package main
+package invoker
 
 import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 )
@@ -32,6 +35,10 @@ func (m mockLambdaClient) Invoke(*lambda.InvokeInput) (*lambda.InvokeOutput, err
 	return &m.Resp, nil
 }
 
+func (m mockLambdaClient) InvokeWithContext(aws.Context, *lambda.InvokeInput, ...request.Option) (*lambda.InvokeOutput, error) {
+	return &m.Resp, nil
+}
+
 func runTest(t *testing.T, e exchange) {
 	request, response := e.Request, e.Response
 	req, err := http.NewRequest(request.Method, request.Path, ioutil.NopCloser(strings.NewReader(request.Body)))
@@ -58,7 +65,7 @@ func runTest(t *testing.T, e exchange) {
 		mockLambdaClient{Resp: resp.Resp},
 	}
 
-	l.invokeLambda(rr, req)
+	allowAnyOrigin(http.HandlerFunc(l.invokeLambda)).ServeHTTP(rr, req)
 
 	// Body equals mocked response
 	if b := rr.Body.String(); b != response.Body {
@@ -77,14 +84,18 @@ func runTest(t *testing.T, e exchange) {
 		t.Errorf("handler returned unexpected cors header: got %v want *", cors)
 	}
 
-	// Check content-type header
-	if contentType := rr.Header().Get(("Content-Type")); contentType != response.Headers["content-type"] {
-		t.Errorf("handler returned unexpected content-type header: got %v want %v", contentType, response.Headers["content-type"])
+	// Check content-type header, which defaults to DEFAULT_CONTENT_TYPE
+	wantContentType := response.Headers["content-type"]
+	if wantContentType == "" {
+		wantContentType = "application/json"
+	}
+	if contentType := rr.Header().Get(("Content-Type")); contentType != wantContentType {
+		t.Errorf("handler returned unexpected content-type header: got %v want %v", contentType, wantContentType)
 	}
 
-	// No content-length header
-	if l := rr.Header().Get(("content-length")); l != "" {
-		t.Errorf("handler returned unexpected cors header: got %v want ''", l)
+	// Content-Length worked out from the body
+	if l := rr.Header().Get(("content-length")); l != strconv.Itoa(len(response.Body)) {
+		t.Errorf("handler returned unexpected content-length header: got %v want %v", l, len(response.Body))
 	}
 }
 
@@ -142,3 +153,21 @@ func TestLambdaInvoke(t *testing.T) {
 		runTest(t, response)
 	}
 }
+
+func TestContentLength(t *testing.T) {
+	for _, c := range []struct {
+		payload  string
+		expected string
+	}{
+		{`{"statusCode":200,"headers":{"Content-Length":"999"},"body":"héllo"}`, "6"},
+		{`{"statusCode":200,"headers":{"content-length":"3"}}`, "0"},
+		{`{"statusCode":204,"headers":{"Content-Length":"3"}}`, ""},
+	} {
+		l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(c.payload)}}}
+		rr := httptest.NewRecorder()
+		l.invokeLambda(rr, httptest.NewRequest("GET", "/", nil))
+		if length := rr.Header().Get("Content-Length"); length != c.expected {
+			t.Errorf("%v: got Content-Length %q want %q", c.payload, length, c.expected)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package invoker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The first response to a request with an idempotency key, done once it has
+// been answered.
+type idempotentResponse struct {
+	fingerprint string
+	done        bool
+	expires     time.Time
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// idempotentRequests answers retries of a request carrying header, such as
+// Idempotency-Key, with the response to the first one for as long as ttl
+// instead of invoking the function again. Reusing a key for a different
+// request gets a 422, and retrying while the first is still running a 409.
+// Throttled and failed requests aren't kept, so they can be retried. A ttl
+// of zero disables it.
+func idempotentRequests(header string, ttl time.Duration, maxBody int64, next http.Handler) http.Handler {
+	if ttl <= 0 || header == "" {
+		return next
+	}
+	var mu sync.Mutex
+	responses := make(map[string]*idempotentResponse)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(header)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fingerprint, ok := requestFingerprint(r, maxBody)
+		if !ok {
+			// Too big to invoke anyway.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mu.Lock()
+		now := time.Now()
+		for k, response := range responses {
+			if response.done && now.After(response.expires) {
+				delete(responses, k)
+			}
+		}
+		first, found := responses[key]
+		if !found {
+			first = &idempotentResponse{fingerprint: fingerprint}
+			responses[key] = first
+		}
+		var replay idempotentResponse
+		if found {
+			replay = *first
+		}
+		mu.Unlock()
+
+		switch {
+		case found && replay.fingerprint != fingerprint:
+			gatewayError(w, http.StatusUnprocessableEntity, header+" was already used for a different request")
+			return
+		case found && !replay.done:
+			gatewayError(w, http.StatusConflict, "A request with this "+header+" is still in progress")
+			return
+		case found:
+			// Keep the headers outer middlewares set for this request, such
+			// as its own request ID.
+			for name, values := range replay.header {
+				if _, ok := w.Header()[name]; !ok {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(replay.status)
+			w.Write(replay.body)
+			return
+		}
+
+		// Forget the request unless it's answered in a way worth repeating,
+		// including when the connection is dropped by a panic.
+		cw := &capturingWriter{ResponseWriter: w}
+		kept := false
+		defer func() {
+			if !kept {
+				mu.Lock()
+				delete(responses, key)
+				mu.Unlock()
+			}
+		}()
+		next.ServeHTTP(cw, r)
+		if cw.status == 0 || cw.status == http.StatusTooManyRequests || cw.status >= 500 {
+			return
+		}
+		mu.Lock()
+		first.done = true
+		first.expires = time.Now().Add(ttl)
+		first.status, first.header, first.body = cw.status, cw.header, cw.body.Bytes()
+		mu.Unlock()
+		kept = true
+	})
+}
+
+// A hash of the method, URL and body, restoring the body for the handler.
+// Bodies over maxBody aren't hashed. A maxBody of zero reads the whole body.
+func requestFingerprint(r *http.Request, maxBody int64) (string, bool) {
+	var reader io.Reader = r.Body
+	if maxBody > 0 {
+		reader = io.LimitReader(r.Body, maxBody+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil || (maxBody > 0 && int64(len(body)) > maxBody) {
+		return "", false
+	}
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// Keeps a copy of what the handler writes.
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *capturingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capturingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *capturingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
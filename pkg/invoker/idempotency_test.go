@@ -0,0 +1,89 @@
+package invoker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotentRequests(t *testing.T) {
+	invocations := 0
+	status := http.StatusCreated
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := idempotentRequests("Idempotency-Key", time.Minute, 1024, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invocations++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.Header().Set("X-Order", "1")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	send := func(path string, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := send("/orders", "a", `{"qty":1}`)
+	retry := send("/orders", "a", `{"qty":1}`)
+	if invocations != 1 || retry.Code != http.StatusCreated || retry.Body.String() != `{"qty":1}` || retry.Header().Get("X-Order") != "1" {
+		t.Errorf("expected the first response to be replayed, got %v %v after %v invocations", retry.Code, retry.Body.String(), invocations)
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected only the replay to be marked")
+	}
+	if rr := send("/orders", "a", `{"qty":2}`); rr.Code != http.StatusUnprocessableEntity || invocations != 1 {
+		t.Errorf("expected a 422 for a reused key, got %v", rr.Code)
+	}
+	send("/orders", "", `{"qty":1}`)
+	send("/orders", "", `{"qty":1}`)
+	if invocations != 3 {
+		t.Errorf("expected requests without a key to be invoked, got %v invocations", invocations)
+	}
+
+	status = http.StatusBadGateway
+	send("/orders", "b", "")
+	send("/orders", "b", "")
+	if invocations != 5 {
+		t.Errorf("expected failed requests to be retried, got %v invocations", invocations)
+	}
+
+	status = http.StatusOK
+	done := make(chan struct{})
+	go func() {
+		send("/slow", "c", "")
+		close(done)
+	}()
+	<-started
+	if rr := send("/slow", "c", ""); rr.Code != http.StatusConflict {
+		t.Errorf("expected a 409 while the first request runs, got %v", rr.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestIdempotencyExpires(t *testing.T) {
+	invocations := 0
+	handler := idempotentRequests("Idempotency-Key", 10*time.Millisecond, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invocations++
+	}))
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("PUT", "/users/1", nil)
+		req.Header.Set("Idempotency-Key", "x")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if invocations != 2 {
+		t.Errorf("expected the key to expire, got %v invocations", invocations)
+	}
+}
@@ -0,0 +1,156 @@
+// Package invoker turns HTTP requests into API Gateway proxy events, invokes
+// Lambda functions with them and turns what they return back into HTTP
+// responses. The http-lambda-invoker command is a thin wrapper around Main,
+// and New gives other servers and tools the same handler to embed.
+//
+// Settings, the Lambda client, plugins, routes and the rest of the
+// configuration are kept for the whole package rather than per handler, as
+// the command only ever needs one. So only one handler made with New can be
+// open at a time, and not alongside Main in the same process. Tests that
+// embed it can't run in parallel, and a second handler with other settings
+// needs the first closed, or a process of its own.
+package invoker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// Config for a handler made with New.
+type Config struct {
+	// Settings by the names of their environment variables, such as
+	// LAMBDA_NAME, LAMBDA_ENDPOINT, ROUTES or CONFIG_FILE. They take
+	// precedence over the environment, which is still read for the rest.
+	Settings map[string]string
+
+	// Invoke functions with this client instead of one for LAMBDA_ENDPOINT,
+	// such as a mock in tests.
+	Client lambdaiface.LambdaAPI
+
+	// Middlewares to run around the handler by name, such as one called auth.
+	// Those MIDDLEWARE doesn't list run innermost, in name order, and one with
+	// the name of a built-in one, such as cors or access-log, replaces it.
+	Middlewares map[string]Middleware
+
+	// Plugins to change events and responses, which run before any PLUGINS
+	// loads.
+	Plugins []Plugin
+}
+
+// Settings and client given to New.
+var (
+	embeddedSettings map[string]string
+	embeddedClient   *LambdaClient
+)
+
+// The handler New made that hasn't been closed yet. Settings, the client and
+// plugins are kept for the whole package, so there can only be one.
+var (
+	embeddedMu sync.Mutex
+	embedded   *Handler
+)
+
+// A handler made with New. Close it to stop its background work and free
+// its configuration for another.
+type Handler struct {
+	http.Handler
+	exporter *spanExporter
+}
+
+// New returns a handler that invokes functions as the command does, with the
+// routes, header changes, throttling and everything else the settings ask
+// for. Unlike the command it doesn't read flags or a .env file, serve
+// health checks or start any background work such as warming, discovery or
+// schedules. Settings are kept for the whole package, so New refuses to make
+// another handler until the last one is closed.
+func New(cfg Config) (*Handler, error) {
+	for key := range cfg.Settings {
+		if !knownSetting(key) {
+			return nil, fmt.Errorf("unknown setting %v", key)
+		}
+	}
+	embeddedMu.Lock()
+	defer embeddedMu.Unlock()
+	if embedded != nil {
+		return nil, errors.New("a handler made with New is still open: close it first")
+	}
+	embeddedSettings = cfg.Settings
+	embeddedClient = nil
+	if cfg.Client != nil {
+		embeddedClient = &LambdaClient{cfg.Client}
+	}
+
+	h, err := newEmbeddedHandler(cfg)
+	if err != nil {
+		resetEmbedded()
+		return nil, err
+	}
+	embedded = h
+	return h, nil
+}
+
+func newEmbeddedHandler(cfg Config) (*Handler, error) {
+	if err := reloadConfig(); err != nil {
+		return nil, err
+	}
+	if err := configureLogging(); err != nil {
+		return nil, err
+	}
+	if err := validateConfig(); err != nil {
+		return nil, err
+	}
+	loaded, err := loadPlugins(getConfig("PLUGINS"))
+	if err != nil {
+		return nil, err
+	}
+	plugins = append(append([]Plugin(nil), cfg.Plugins...), loaded...)
+	h := &Handler{}
+	if endpoint := getConfig("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		h.exporter = newSpanExporter(endpoint, getConfig("OTEL_SERVICE_NAME"))
+		go h.exporter.run(5 * time.Second)
+	}
+	if h.Handler, err = newHandler(h.exporter, cfg.Middlewares); err != nil {
+		if h.exporter != nil {
+			h.exporter.stop()
+		}
+		return nil, err
+	}
+	return h, nil
+}
+
+// Close stops the handler's span exporter, sending any spans it holds, and
+// forgets its settings, client and plugins so New can make another. Requests
+// still being served should be finished first. Closing twice does nothing.
+func (h *Handler) Close() error {
+	embeddedMu.Lock()
+	defer embeddedMu.Unlock()
+	if embedded != h {
+		return nil
+	}
+	if h.exporter != nil {
+		h.exporter.stop()
+	}
+	resetEmbedded()
+	return nil
+}
+
+func resetEmbedded() {
+	embeddedSettings = nil
+	embeddedClient = nil
+	plugins = nil
+	embedded = nil
+}
+
+func knownSetting(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
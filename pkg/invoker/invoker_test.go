@@ -0,0 +1,75 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestNew(t *testing.T) {
+	defer setRoutes(nil)
+
+	if _, err := New(Config{Settings: map[string]string{"LAMBDA_NAMES": "users"}}); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+	if _, err := New(Config{Settings: map[string]string{"LAMBDA_NAME": "users", "MAX_CONCURRENCY": "lots"}}); err == nil {
+		t.Error("expected an error for an invalid setting")
+	}
+
+	var event makeProxyRequest
+	handler, err := New(Config{
+		Settings: map[string]string{"ROUTES": "GET /users/:id=users", "ACCESS_LOG": "off"},
+		Client:   eventLambdaClient{event: &event},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users/42", nil))
+	if rr.Code != 200 || event.PathParameters["id"] != "42" || event.RequestContext.RequestID == "" {
+		t.Errorf("unexpected response %v, function got %+v", rr.Code, event)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	if rr.Code != 404 {
+		t.Errorf("expected a 404 without LAMBDA_NAME, got %v", rr.Code)
+	}
+}
+
+func TestNewOnlyOnce(t *testing.T) {
+	defer setRoutes(nil)
+	var exported int32
+	collector := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { atomic.AddInt32(&exported, 1) }))
+	defer collector.Close()
+
+	cfg := Config{
+		Settings: map[string]string{"LAMBDA_NAME": "users", "ACCESS_LOG": "off", "OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL},
+		Client:   mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200}`)}},
+	}
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cfg); err == nil {
+		t.Error("expected an error for a second handler while the first is open")
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	handler.Close()
+	if atomic.LoadInt32(&exported) == 0 {
+		t.Error("expected Close to send the spans held")
+	}
+	if embeddedSettings != nil || embeddedClient != nil {
+		t.Error("expected Close to forget the settings and client")
+	}
+	handler.Close()
+
+	handler, err = New(cfg)
+	if err != nil {
+		t.Fatalf("expected a new handler once the first was closed: %v", err)
+	}
+	handler.Close()
+}
@@ -0,0 +1,234 @@
+package invoker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Settings the Lambda client is built from.
+type clientConfig struct {
+	// "static" for the keys below or "default" for the SDK's credential chain.
+	Credentials     string
+	Profile         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Endpoint        string
+
+	// Role to assume before invoking, for functions in another account.
+	RoleARN         string
+	ExternalID      string
+	RoleSessionName string
+
+	// Connection handling for LAMBDA_ENDPOINT. Zero values keep Go's defaults.
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	DisableKeepAlives   bool
+
+	// Verifying LAMBDA_ENDPOINT's certificate.
+	CAFile             string
+	InsecureSkipVerify bool
+
+	// HTTP proxy for LAMBDA_ENDPOINT, overriding HTTP_PROXY and friends.
+	Proxy   string
+	NoProxy string
+}
+
+var (
+	clientMu sync.Mutex
+	clients  = make(map[clientConfig]*LambdaClient)
+)
+
+func currentClientConfig() (clientConfig, error) {
+	cfg := clientConfig{
+		Credentials:     getConfig("AWS_CREDENTIALS"),
+		Profile:         getConfig("AWS_PROFILE"),
+		AccessKeyID:     getConfig("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: getConfig("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    getConfig("AWS_SESSION_TOKEN"),
+		Region:          getConfig("AWS_REGION"),
+		Endpoint:        getConfig("LAMBDA_ENDPOINT"),
+		RoleARN:         getConfig("ASSUME_ROLE_ARN"),
+		ExternalID:      getConfig("ASSUME_ROLE_EXTERNAL_ID"),
+		RoleSessionName: getConfig("ASSUME_ROLE_SESSION_NAME"),
+	}
+	if cfg.Credentials != "static" && cfg.Credentials != "default" {
+		return cfg, fmt.Errorf("invalid AWS_CREDENTIALS %q: must be static or default", cfg.Credentials)
+	}
+	var err error
+	if cfg.MaxIdleConnsPerHost, err = getConfigInt("LAMBDA_MAX_IDLE_CONNS_PER_HOST"); err != nil {
+		return cfg, err
+	}
+	if cfg.IdleConnTimeout, err = getConfigDuration("LAMBDA_IDLE_CONN_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	if cfg.TLSHandshakeTimeout, err = getConfigDuration("LAMBDA_TLS_HANDSHAKE_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	if cfg.DisableKeepAlives, err = getConfigBool("LAMBDA_DISABLE_KEEP_ALIVES"); err != nil {
+		return cfg, err
+	}
+	cfg.CAFile = getConfig("LAMBDA_CA_FILE")
+	if cfg.InsecureSkipVerify, err = getConfigBool("LAMBDA_INSECURE_SKIP_VERIFY"); err != nil {
+		return cfg, err
+	}
+	cfg.Proxy = getConfig("LAMBDA_PROXY")
+	cfg.NoProxy = getConfig("LAMBDA_NO_PROXY")
+	return cfg, nil
+}
+
+// HTTP client for talking to LAMBDA_ENDPOINT. Like Go's default transport it
+// goes through HTTP_PROXY, HTTPS_PROXY and NO_PROXY unless LAMBDA_PROXY is set.
+func newHTTPClient(cfg clientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < cfg.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = cfg.MaxIdleConnsPerHost
+		}
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+	if cfg.Proxy != "" {
+		if _, err := url.Parse(cfg.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid LAMBDA_PROXY %q: %v", cfg.Proxy, err)
+		}
+		proxy := (&httpproxy.Config{HTTPProxy: cfg.Proxy, HTTPSProxy: cfg.Proxy, NoProxy: cfg.NoProxy}).ProxyFunc()
+		transport.Proxy = func(r *http.Request) (*url.URL, error) {
+			return proxy(r.URL)
+		}
+	}
+	if cfg.CAFile != "" || cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading LAMBDA_CA_FILE: %v", err)
+		}
+		// Trust the bundle on top of the system roots, so real AWS still works.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("LAMBDA_CA_FILE %v has no PEM certificates", cfg.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func newLambdaClient(cfg clientConfig) (*LambdaClient, error) {
+	sess, err := newSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize lambda client. Only Lambda calls go to LAMBDA_ENDPOINT, STS
+	// is always the real one.
+	lambdaConfig := &aws.Config{Endpoint: aws.String(cfg.Endpoint)}
+	if cfg.RoleARN != "" {
+		lambdaConfig.Credentials = assumeRoleCredentials(sess, cfg)
+	}
+	return &LambdaClient{
+		lambda.New(sess, lambdaConfig),
+	}, nil
+}
+
+// AWS session for the region, credentials and connection settings of cfg.
+func newSession(cfg clientConfig) (*session.Session, error) {
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create AWS session. The fake static credentials are all a local endpoint
+	// needs, while real AWS needs the environment, shared config or a role.
+	opts := session.Options{
+		Config: aws.Config{
+			Region:     aws.String(cfg.Region),
+			HTTPClient: httpClient,
+		},
+	}
+	if cfg.Credentials == "default" {
+		opts.Profile = cfg.Profile
+		opts.SharedConfigState = session.SharedConfigEnable
+	} else {
+		opts.Config.Credentials = credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+	}
+	return session.NewSessionWithOptions(opts)
+}
+
+// Credentials for ASSUME_ROLE_ARN, obtained with the session's own credentials
+// and refreshed before they expire.
+func assumeRoleCredentials(sess *session.Session, cfg clientConfig) *credentials.Credentials {
+	return stscreds.NewCredentials(sess, cfg.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		if cfg.ExternalID != "" {
+			p.ExternalID = aws.String(cfg.ExternalID)
+		}
+		if cfg.RoleSessionName != "" {
+			p.RoleSessionName = cfg.RoleSessionName
+		}
+	})
+}
+
+// Share one Lambda client across requests, only building a new one when the
+// settings it depends on change.
+func getLambdaClient() (*LambdaClient, error) {
+	return lambdaClientFor(nil)
+}
+
+// The Lambda client for a route, which may invoke its function in another
+// region or at another endpoint. Clients are kept per target. A client given
+// to New is used for every route.
+func lambdaClientFor(rt *route) (*LambdaClient, error) {
+	if embeddedClient != nil {
+		return embeddedClient, nil
+	}
+	cfg, err := currentClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if rt != nil && rt.Region != "" {
+		cfg.Region = rt.Region
+	}
+	if rt != nil && rt.Endpoint != "" {
+		cfg.Endpoint = rt.Endpoint
+	}
+
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if c, ok := clients[cfg]; ok {
+		return c, nil
+	}
+	c, err := newLambdaClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.InsecureSkipVerify {
+		logWarn("LAMBDA_INSECURE_SKIP_VERIFY is set, the endpoint certificate is not checked", logFields{"endpoint": cfg.Endpoint})
+	}
+	clients[cfg] = c
+	return c, nil
+}
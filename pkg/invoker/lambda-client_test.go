@@ -0,0 +1,208 @@
+package invoker
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestLambdaClientIsReused(t *testing.T) {
+	first, err := getLambdaClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := getLambdaClient(); second != first {
+		t.Error("expected the Lambda client to be reused between requests")
+	}
+
+	os.Setenv("AWS_REGION", "eu-west-1")
+	defer os.Unsetenv("AWS_REGION")
+	if refreshed, _ := getLambdaClient(); refreshed == first {
+		t.Error("expected a new Lambda client after the region changed")
+	}
+}
+
+func TestHTTPClientTransport(t *testing.T) {
+	os.Setenv("LAMBDA_MAX_IDLE_CONNS_PER_HOST", "64")
+	os.Setenv("LAMBDA_IDLE_CONN_TIMEOUT", "5m")
+	os.Setenv("LAMBDA_DISABLE_KEEP_ALIVES", "true")
+	defer os.Unsetenv("LAMBDA_MAX_IDLE_CONNS_PER_HOST")
+	defer os.Unsetenv("LAMBDA_IDLE_CONN_TIMEOUT")
+	defer os.Unsetenv("LAMBDA_DISABLE_KEEP_ALIVES")
+
+	cfg, err := currentClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := httpClient.Transport.(*http.Transport)
+
+	if transport.MaxIdleConnsPerHost != 64 {
+		t.Errorf("unexpected MaxIdleConnsPerHost: got %v want 64", transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 5*time.Minute {
+		t.Errorf("unexpected IdleConnTimeout: got %v want 5m", transport.IdleConnTimeout)
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("expected keep-alives to be disabled")
+	}
+}
+
+func TestHTTPClientTransportInvalid(t *testing.T) {
+	os.Setenv("LAMBDA_IDLE_CONN_TIMEOUT", "forever")
+	defer os.Unsetenv("LAMBDA_IDLE_CONN_TIMEOUT")
+
+	if _, err := getLambdaClient(); err == nil {
+		t.Error("expected an error for an invalid LAMBDA_IDLE_CONN_TIMEOUT")
+	}
+}
+
+func TestHTTPClientTrust(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "lambda-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cfg clientConfig
+		ok  bool
+	}{
+		{clientConfig{}, false},
+		{clientConfig{CAFile: caFile}, true},
+		{clientConfig{InsecureSkipVerify: true}, true},
+	}
+	for _, test := range tests {
+		httpClient, err := newHTTPClient(test.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := httpClient.Get(srv.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		if (err == nil) != test.ok {
+			t.Errorf("%+v: expected ok %v, got %v", test.cfg, test.ok, err)
+		}
+	}
+
+	if _, err := newHTTPClient(clientConfig{CAFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("expected an error for a missing LAMBDA_CA_FILE")
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	httpClient, err := newHTTPClient(clientConfig{Proxy: proxy.URL, NoProxy: "direct.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := httpClient.Get("http://lambda.example:9001/2015-03-31/functions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if proxied != "http://lambda.example:9001/2015-03-31/functions" {
+		t.Errorf("expected the request to go through LAMBDA_PROXY, got %q", proxied)
+	}
+
+	direct, _ := http.NewRequest("GET", "http://direct.example:9001/", nil)
+	if u, err := httpClient.Transport.(*http.Transport).Proxy(direct); err != nil || u != nil {
+		t.Errorf("expected LAMBDA_NO_PROXY hosts to be reached directly, got %v (%v)", u, err)
+	}
+}
+
+func TestDefaultCredentials(t *testing.T) {
+	os.Setenv("AWS_CREDENTIALS", "default")
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_CREDENTIALS")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	cfg, err := currentClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newLambdaClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.LambdaAPI.(*lambda.Lambda)
+	creds, err := client.Config.Credentials.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "AKIDEXAMPLE" || creds.ProviderName == credentials.StaticProviderName {
+		t.Errorf("expected credentials from the environment, got %v from %v", creds.AccessKeyID, creds.ProviderName)
+	}
+	if client.Endpoint != "https://lambda.us-east-1.amazonaws.com" {
+		t.Errorf("expected the regional endpoint without LAMBDA_ENDPOINT, got %v", client.Endpoint)
+	}
+
+	os.Setenv("AWS_CREDENTIALS", "magic")
+	if _, err := currentClientConfig(); err == nil {
+		t.Error("expected an error for an invalid AWS_CREDENTIALS")
+	}
+}
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	var form url.Values
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIAEXAMPLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+<SessionToken>token</SessionToken><Expiration>%v</Expiration>
+</Credentials></AssumeRoleResult></AssumeRoleResponse>`, expiration)
+	}))
+	defer sts.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("foo", "bar", ""),
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(sts.URL),
+	}))
+	creds, err := assumeRoleCredentials(sess, clientConfig{
+		RoleARN:         "arn:aws:iam::123456789012:role/dev",
+		ExternalID:      "shared-dev",
+		RoleSessionName: "laptop",
+	}).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "ASIAEXAMPLE" || creds.SessionToken != "token" {
+		t.Errorf("expected the assumed role's credentials, got %+v", creds)
+	}
+	if form.Get("RoleArn") != "arn:aws:iam::123456789012:role/dev" || form.Get("ExternalId") != "shared-dev" || form.Get("RoleSessionName") != "laptop" {
+		t.Errorf("unexpected AssumeRole request %v", form)
+	}
+}
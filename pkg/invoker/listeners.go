@@ -0,0 +1,124 @@
+package invoker
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// How the proxy's own sockets are opened. With REUSE_PORT its Control sets
+// SO_REUSEPORT on them.
+var listenConfig net.ListenConfig
+
+// Where to accept connections, and whether they use TLS.
+type listenerSpec struct {
+	Network string
+	Address string
+	TLS     bool
+}
+
+// Parse LISTEN, a comma separated list of addresses such as
+// ":8080,https://:8443,unix:/tmp/invoker.sock". TCP addresses may be written
+// host:port, tcp://host:port or http://host:port, HTTPS ones https://host:port
+// and Unix sockets unix:/path or unix:///path. With no LISTEN the proxy
+// listens on HOST and PORT, using TLS when useTLS is set.
+func parseListeners(listen string, host string, port string, useTLS bool) ([]listenerSpec, error) {
+	if strings.TrimSpace(listen) == "" {
+		return []listenerSpec{{"tcp", listenAddress(host, port), useTLS}}, nil
+	}
+
+	var specs []listenerSpec
+	for _, item := range strings.Split(listen, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case strings.HasPrefix(item, "unix:"):
+			path := strings.TrimPrefix(strings.TrimPrefix(item, "unix:"), "//")
+			if path == "" {
+				return nil, fmt.Errorf("invalid LISTEN address %q: missing socket path", item)
+			}
+			specs = append(specs, listenerSpec{"unix", path, false})
+		default:
+			secure := strings.HasPrefix(item, "https://")
+			addr := item
+			for _, scheme := range []string{"tcp://", "http://", "https://"} {
+				addr = strings.TrimPrefix(addr, scheme)
+			}
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return nil, fmt.Errorf("invalid LISTEN address %q: %v", item, err)
+			}
+			specs = append(specs, listenerSpec{"tcp", addr, secure})
+		}
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("invalid LISTEN %q: no addresses", listen)
+	}
+	return specs, nil
+}
+
+// Open every listener, closing any already opened if one fails. TLS listeners
+// need tlsConfig.
+func openListeners(specs []listenerSpec, tlsConfig *tls.Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, spec := range specs {
+		if spec.TLS && tlsConfig == nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("listening on https://%v needs a certificate", spec.Address)
+		}
+		if spec.Network == "unix" {
+			// Clear out a socket left behind by a previous run.
+			if info, err := os.Stat(spec.Address); err == nil && info.Mode()&os.ModeSocket != 0 {
+				os.Remove(spec.Address)
+			}
+		}
+		ln, err := listenConfig.Listen(context.Background(), spec.Network, spec.Address)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		if spec.TLS {
+			ln = tls.NewListener(ln, tlsConfig)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// The URL the healthcheck subcommand should use, which is the first TCP
+// listener it can connect to. It has no client certificate to present, so
+// when clientCerts says they're required it skips HTTPS listeners and falls
+// back to adminAddress.
+func healthcheckURL(specs []listenerSpec, adminAddress string, clientCerts bool) (string, error) {
+	for _, spec := range specs {
+		if spec.Network == "tcp" && !(spec.TLS && clientCerts) {
+			host, port, err := net.SplitHostPort(spec.Address)
+			if err != nil {
+				return "", err
+			}
+			scheme := "http"
+			if spec.TLS {
+				scheme = "https"
+			}
+			return fmt.Sprintf("%v://%v", scheme, localAddress(host, port)), nil
+		}
+	}
+	if adminAddress != "" {
+		host, port, err := net.SplitHostPort(adminAddress)
+		if err != nil {
+			return "", fmt.Errorf("invalid ADMIN_ADDRESS: %v", err)
+		}
+		return fmt.Sprintf("http://%v", localAddress(host, port)), nil
+	}
+	if clientCerts {
+		return "", fmt.Errorf("healthcheck can't present a client certificate: set ADMIN_ADDRESS or add a plain HTTP listener to LISTEN")
+	}
+	return "", fmt.Errorf("healthcheck needs a TCP listener")
+}
@@ -0,0 +1,157 @@
+package invoker
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseListeners(t *testing.T) {
+	tests := []struct {
+		listen string
+		specs  []listenerSpec
+	}{
+		{"", []listenerSpec{{"tcp", "127.0.0.1:8080", false}}},
+		{":9000", []listenerSpec{{"tcp", ":9000", false}}},
+		{"tcp://[::1]:9000, unix:/tmp/a.sock,unix:///tmp/b.sock", []listenerSpec{
+			{"tcp", "[::1]:9000", false},
+			{"unix", "/tmp/a.sock", false},
+			{"unix", "/tmp/b.sock", false},
+		}},
+		{"http://:8080,https://:8443", []listenerSpec{
+			{"tcp", ":8080", false},
+			{"tcp", ":8443", true},
+		}},
+	}
+
+	for _, test := range tests {
+		specs, err := parseListeners(test.listen, "127.0.0.1", "8080", false)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.listen, err)
+			continue
+		}
+		if !reflect.DeepEqual(specs, test.specs) {
+			t.Errorf("%q: got %v want %v", test.listen, specs, test.specs)
+		}
+	}
+
+	for _, listen := range []string{"8080", "unix:", " , "} {
+		if _, err := parseListeners(listen, "", "8080", false); err == nil {
+			t.Errorf("%q: expected an error", listen)
+		}
+	}
+}
+
+func TestMultipleListeners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listeners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "invoker.sock")
+
+	listeners, err := openListeners([]listenerSpec{{"tcp", "127.0.0.1:0", false}, {"unix", socket, false}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(healthHandler)}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(srv, listeners, time.Second, stop)
+	}()
+
+	if err := healthcheck("http://" + listeners[0].Addr().String()); err != nil {
+		t.Errorf("TCP listener: %v", err)
+	}
+
+	unixClient := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	resp, err := unixClient.Get("http://invoker" + healthPath)
+	if err != nil {
+		t.Errorf("Unix socket listener: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	stop <- syscall.SIGTERM
+	if err := <-served; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestDefaultListenerUsesTLS(t *testing.T) {
+	specs, err := parseListeners("", "", "8443", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := healthcheckURL(specs, "", false); url != "https://127.0.0.1:8443" {
+		t.Errorf("unexpected healthcheck URL: %v", url)
+	}
+
+	if _, err := openListeners([]listenerSpec{{"tcp", "127.0.0.1:0", true}}, nil); err == nil {
+		t.Error("expected an error opening an HTTPS listener without a certificate")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	port, err := httpsPort([]listenerSpec{{"unix", "/tmp/a.sock", false}, {"tcp", ":8080", false}, {"tcp", ":8443", true}})
+	if err != nil || port != "8443" {
+		t.Fatalf("expected HTTPS port 8443, got %q (%v)", port, err)
+	}
+	if _, err := httpsPort([]listenerSpec{{"tcp", ":8080", false}}); err == nil {
+		t.Error("expected an error without an HTTPS listener")
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, redirectPort, _ := net.SplitHostPort(plain.Addr().String())
+	srv := &http.Server{Handler: redirectToHTTPS(next, redirectPort, "8443")}
+	go srv.Serve(plain)
+	go srv.Serve(other)
+	defer srv.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get("http://" + plain.Addr().String() + "/pets/1?x=y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("expected 301, got %v", res.StatusCode)
+	}
+	if location := res.Header.Get("Location"); location != "https://127.0.0.1:8443/pets/1?x=y" {
+		t.Errorf("unexpected Location %q", location)
+	}
+
+	res, err = client.Get("http://" + other.Addr().String() + "/pets/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTeapot {
+		t.Errorf("expected other listeners to pass through, got %v", res.StatusCode)
+	}
+}
@@ -0,0 +1,153 @@
+package invoker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logLevel int
+
+const (
+	debugLevel logLevel = iota
+	infoLevel
+	warnLevel
+	errorLevel
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	return infoLevel, fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn or error", s)
+}
+
+// Extra values to log with a message, such as the route and function.
+type logFields map[string]interface{}
+
+// Writes one line per message, either as JSON for log aggregators or as text
+// for people.
+type logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level logLevel
+	json  bool
+}
+
+var defaultLogger = &logger{out: os.Stderr, level: infoLevel, json: true}
+
+// Apply LOG_LEVEL and LOG_FORMAT, and send the standard library's log output,
+// including startup errors, through the same logger.
+func configureLogging() error {
+	level, err := parseLogLevel(getConfig("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+	format := getConfig("LOG_FORMAT")
+	if format != "json" && format != "text" {
+		return fmt.Errorf("invalid LOG_FORMAT %q: must be json or text", format)
+	}
+
+	defaultLogger.mu.Lock()
+	defaultLogger.level = level
+	defaultLogger.json = format == "json"
+	defaultLogger.mu.Unlock()
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{defaultLogger})
+	return nil
+}
+
+func (l *logger) log(level logLevel, msg string, fields logFields) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+	if l.json {
+		l.out.Write(jsonLogLine(level, msg, fields))
+	} else {
+		l.out.Write(textLogLine(level, msg, fields))
+	}
+}
+
+// Write a line whatever the level, for access logs which are turned off with
+// their own setting.
+func (l *logger) write(line []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
+
+func jsonLogLine(level logLevel, msg string, fields logFields) []byte {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for key, value := range fields {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		entry[key] = value
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+	// Encoding sorts the keys, so lines are stable.
+	var line bytes.Buffer
+	json.NewEncoder(&line).Encode(entry)
+	return line.Bytes()
+}
+
+func textLogLine(level logLevel, msg string, fields logFields) []byte {
+	var line bytes.Buffer
+	fmt.Fprintf(&line, "%v %-5v %v", time.Now().UTC().Format("2006/01/02 15:04:05"), strings.ToUpper(level.String()), msg)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&line, " %v=%v", key, fields[key])
+	}
+	line.WriteByte('\n')
+	return line.Bytes()
+}
+
+func logDebug(msg string, fields logFields) {
+	defaultLogger.log(debugLevel, msg, fields)
+}
+
+func logInfo(msg string, fields logFields) {
+	defaultLogger.log(infoLevel, msg, fields)
+}
+
+func logWarn(msg string, fields logFields) {
+	defaultLogger.log(warnLevel, msg, fields)
+}
+
+func logError(msg string, fields logFields) {
+	defaultLogger.log(errorLevel, msg, fields)
+}
+
+// Adapts the standard library's log package, whose messages are mostly
+// errors such as failed startup checks or http.Server connection problems.
+type stdLogWriter struct {
+	l *logger
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	w.l.log(errorLevel, strings.TrimSuffix(string(p), "\n"), nil)
+	return len(p), nil
+}
@@ -0,0 +1,57 @@
+package invoker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogging(t *testing.T) {
+	var out bytes.Buffer
+	l := &logger{out: &out, level: infoLevel, json: true}
+	l.log(debugLevel, "Invoked function", logFields{"function": "users"})
+	l.log(errorLevel, "Invocation failed", logFields{"function": "users", "status": 502, "error": errors.New("boom")})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected debug messages to be dropped at info, got %q", out.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "error" || entry["msg"] != "Invocation failed" || entry["function"] != "users" || entry["status"] != 502.0 || entry["error"] != "boom" {
+		t.Errorf("unexpected log entry %v", entry)
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("expected a time field")
+	}
+}
+
+func TestTextLogging(t *testing.T) {
+	var out bytes.Buffer
+	l := &logger{out: &out, level: debugLevel}
+	l.log(debugLevel, "Invoked function", logFields{"status": 200, "function": "users"})
+
+	if line := out.String(); !strings.HasSuffix(line, " DEBUG Invoked function function=users status=200\n") {
+		t.Errorf("unexpected log line %q", line)
+	}
+}
+
+func TestConfigureLogging(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "loud")
+	defer os.Unsetenv("LOG_LEVEL")
+	if err := configureLogging(); err == nil {
+		t.Error("expected an error for an invalid LOG_LEVEL")
+	}
+
+	os.Setenv("LOG_LEVEL", "WARN")
+	os.Setenv("LOG_FORMAT", "xml")
+	defer os.Unsetenv("LOG_FORMAT")
+	if err := configureLogging(); err == nil {
+		t.Error("expected an error for an invalid LOG_FORMAT")
+	}
+}
@@ -0,0 +1,31 @@
+package invoker
+
+import (
+	"net/http"
+	"strings"
+)
+
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// Methods a POST can be tunnelled as.
+var overridableMethods = map[string]bool{"GET": true, "HEAD": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true}
+
+// Treat a POST with an X-HTTP-Method-Override header as the method it names,
+// for clients that can only send GET and POST. Routes are matched with, and
+// the event's httpMethod is, the overridden method. When off next is
+// returned as is.
+func overrideMethod(enabled bool, next http.Handler) http.Handler {
+	if !enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if override := strings.ToUpper(strings.TrimSpace(r.Header.Get(methodOverrideHeader))); r.Method == http.MethodPost && override != "" {
+			if !overridableMethods[override] {
+				gatewayError(w, http.StatusBadRequest, "Invalid "+methodOverrideHeader)
+				return
+			}
+			r.Method = override
+		}
+		next.ServeHTTP(w, r)
+	})
+}
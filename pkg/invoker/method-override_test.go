@@ -0,0 +1,37 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOverrideMethod(t *testing.T) {
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r.Method })
+
+	for _, c := range []struct {
+		enabled  bool
+		method   string
+		override string
+		expected string
+		status   int
+	}{
+		{true, "POST", "delete", "DELETE", http.StatusOK},
+		{true, "POST", "", "POST", http.StatusOK},
+		{true, "GET", "DELETE", "GET", http.StatusOK},
+		{false, "POST", "DELETE", "POST", http.StatusOK},
+		{true, "POST", "CONNECT", "", http.StatusBadRequest},
+	} {
+		seen = ""
+		req := httptest.NewRequest(c.method, "/users/42", nil)
+		if c.override != "" {
+			req.Header.Set("X-HTTP-Method-Override", c.override)
+		}
+		rr := httptest.NewRecorder()
+		overrideMethod(c.enabled, next).ServeHTTP(rr, req)
+		if seen != c.expected || rr.Code != c.status {
+			t.Errorf("%+v: got method %q status %v", c, seen, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package invoker
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Middleware wraps the handler that invokes functions, to do something
+// before or after it, or instead of it.
+type Middleware func(http.Handler) http.Handler
+
+// The built-in middlewares, in the order they run by default, outermost
+// first. MIDDLEWARE can reorder or leave out any of them.
+var builtinMiddlewares = []string{"request-id", "tracing", "access-log", "metrics", "cors", "client-throttle", "proxy-auth", "idempotency", "concurrency", "queue", "method-override", "chaos"}
+
+// Build a built-in middleware from the settings. Some, such as chaos, do
+// nothing unless configured.
+func builtinMiddleware(name string, exporter *spanExporter) (Middleware, error) {
+	switch name {
+	case "request-id":
+		header := getConfig("CORRELATION_ID_HEADER")
+		return func(next http.Handler) http.Handler { return withRequestID(header, next) }, nil
+	case "tracing":
+		return func(next http.Handler) http.Handler { return traceRequests(exporter, next) }, nil
+	case "access-log":
+		format, err := newAccessLog(getConfig("ACCESS_LOG"))
+		if err != nil || format == nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return observeRequests(next, accessLogObserver(format)) }, nil
+	case "metrics":
+		var observers []func(*requestSummary)
+		if host := getConfig("STATSD_HOST"); host != "" {
+			statsd, err := newStatsdClient(listenAddress(host, getConfig("STATSD_PORT")), getConfig("STATSD_PREFIX"), getConfig("STATSD_TAGS"))
+			if err != nil {
+				return nil, err
+			}
+			observers = append(observers, statsd.observe)
+		}
+		if namespace := getConfig("EMF_NAMESPACE"); namespace != "" {
+			observers = append(observers, emfObserver(namespace))
+		}
+		if getConfig("ADMIN_ADDRESS") != "" {
+			observers = append(observers, expvarObserver)
+		}
+		return func(next http.Handler) http.Handler { return observeRequests(next, observers...) }, nil
+	case "cors":
+		return allowAnyOrigin, nil
+	case "client-throttle":
+		rate, err := getConfigInt("CLIENT_RATE_LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		burst, err := getConfigInt("CLIENT_BURST_LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return throttleClients(rate, burst, next) }, nil
+	case "proxy-auth":
+		creds, err := newProxyCredentials(getConfig("BASIC_AUTH"), getConfig("AUTH_TOKEN"))
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return requireProxyAuth(creds, next) }, nil
+	case "idempotency":
+		ttl, err := getConfigDuration("IDEMPOTENCY_TTL")
+		if err != nil {
+			return nil, err
+		}
+		maxBody, err := getConfigInt("MAX_REQUEST_SIZE")
+		if err != nil {
+			return nil, err
+		}
+		header := getConfig("IDEMPOTENCY_HEADER")
+		return func(next http.Handler) http.Handler { return idempotentRequests(header, ttl, int64(maxBody), next) }, nil
+	case "concurrency":
+		max, err := getConfigInt("MAX_CONCURRENCY")
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return limitConcurrency(max, next) }, nil
+	case "queue":
+		workers, err := getConfigInt("INVOKE_CONCURRENCY")
+		if err != nil {
+			return nil, err
+		}
+		depth, err := getConfigInt("INVOKE_QUEUE_DEPTH")
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := getConfigDuration("INVOKE_QUEUE_TIMEOUT")
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return queueInvocations(workers, depth, timeout, next) }, nil
+	case "method-override":
+		enabled, err := getConfigBool("METHOD_OVERRIDE")
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return overrideMethod(enabled, next) }, nil
+	case "chaos":
+		chaos, err := newFaults()
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return injectFaults(chaos, next) }, nil
+	}
+	return nil, fmt.Errorf("invalid MIDDLEWARE: unknown middleware %v", name)
+}
+
+// Wrap next in the middlewares MIDDLEWARE lists, outermost first. Those in
+// custom replace the built-in ones of the same name, and any that MIDDLEWARE
+// doesn't list run innermost, in name order.
+func chainMiddleware(next http.Handler, exporter *spanExporter, custom map[string]Middleware) (http.Handler, error) {
+	var names []string
+	listed := make(map[string]bool)
+	for _, name := range strings.Split(getConfig("MIDDLEWARE"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+			listed[name] = true
+		}
+	}
+	var extra []string
+	for name := range custom {
+		if !listed[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	names = append(names, extra...)
+
+	handler := next
+	for i := len(names) - 1; i >= 0; i-- {
+		m, ok := custom[names[i]]
+		if !ok {
+			var err error
+			if m, err = builtinMiddleware(names[i], exporter); err != nil {
+				return nil, err
+			}
+		}
+		if m != nil {
+			handler = m(handler)
+		}
+	}
+	return handler, nil
+}
+
+// Let browsers call the function from any origin, as CORS errors aren't fun
+// in development. A function can still send its own Access-Control-Allow-Origin.
+func allowAnyOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChainMiddleware(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { order = append(order, "handler") })
+	serve := func(custom map[string]Middleware) *httptest.ResponseRecorder {
+		order = nil
+		handler, err := chainMiddleware(final, nil, custom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		return rr
+	}
+
+	rr := serve(map[string]Middleware{"b-audit": record("b-audit"), "a-auth": record("a-auth")})
+	if strings.Join(order, ",") != "a-auth,b-audit,handler" {
+		t.Errorf("unexpected order %v", order)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "*" || rr.Header().Get("x-amzn-RequestId") == "" {
+		t.Errorf("expected the built-in middlewares, got %v", rr.Header())
+	}
+
+	os.Setenv("MIDDLEWARE", "auth, request-id")
+	defer os.Unsetenv("MIDDLEWARE")
+	rr = serve(map[string]Middleware{"auth": record("auth"), "cors": record("cors")})
+	if strings.Join(order, ",") != "auth,cors,handler" {
+		t.Errorf("unexpected order %v", order)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("expected the custom cors middleware to replace the built-in one")
+	}
+
+	os.Setenv("MIDDLEWARE", "request-id,gzip")
+	if _, err := chainMiddleware(final, nil, nil); err == nil {
+		t.Error("expected an error for an unknown middleware")
+	}
+}
+
+func TestNewWithMiddleware(t *testing.T) {
+	defer setRoutes(nil)
+
+	var event makeProxyRequest
+	handler, err := New(Config{
+		Settings: map[string]string{"LAMBDA_NAME": "users", "ACCESS_LOG": "off"},
+		Client:   eventLambdaClient{event: &event},
+		Middlewares: map[string]Middleware{"auth": func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "" {
+					gatewayError(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+	if rr.Code != http.StatusUnauthorized || event.Path != "" {
+		t.Errorf("expected the request to be rejected, got %v", rr.Code)
+	}
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || event.Path != "/users" {
+		t.Errorf("expected the function to be invoked, got %v", rr.Code)
+	}
+}
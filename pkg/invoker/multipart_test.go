@@ -0,0 +1,45 @@
+package invoker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultipartBody(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("upload", "image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00})
+	form.Close()
+	raw := body.Bytes()
+
+	var event makeProxyRequest
+	l := LambdaClient{eventLambdaClient{event: &event}}
+	req := httptest.NewRequest("POST", "/uploads", bytes.NewReader(raw))
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	l.invokeLambda(httptest.NewRecorder(), req)
+
+	if !event.IsBase64Encoded {
+		t.Error("expected the body to be base64 encoded")
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(event.Body); err != nil || !bytes.Equal(decoded, raw) {
+		t.Errorf("body didn't survive: %v", err)
+	}
+	if event.Headers["Content-Type"] != form.FormDataContentType() {
+		t.Errorf("unexpected Content-Type %q", event.Headers["Content-Type"])
+	}
+
+	req = httptest.NewRequest("POST", "/uploads", strings.NewReader(`{"name":"image.png"}`))
+	req.Header.Set("Content-Type", "application/json")
+	l.invokeLambda(httptest.NewRecorder(), req)
+	if event.IsBase64Encoded || event.Body != `{"name":"image.png"}` {
+		t.Errorf("unexpected JSON body %q", event.Body)
+	}
+}
@@ -0,0 +1,139 @@
+package invoker
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// What is known about a request once it has been answered, for access logs
+// and metrics. Fields are exported so ACCESS_LOG templates can use them, as
+// in {{.Method}} {{.Path}} {{.Status}}.
+type requestSummary struct {
+	Time          time.Time
+	RequestID     string
+	CorrelationID string
+	RemoteAddr    string
+	Method        string
+	Path          string
+	Query         string
+	Proto         string
+	Status        int
+	Bytes         int
+	Latency       time.Duration
+	Function      string
+	Route         string
+	Referer       string
+	UserAgent     string
+	// What Lambda reported about the invocation, with LAMBDA_TAIL_LOGS.
+	Report *invocationReport
+}
+
+type requestSummaryKey struct{}
+
+// Record which route and function served the request, once it has been
+// matched.
+func setRequestFunction(r *http.Request, rt *route, function string) {
+	if summary, ok := r.Context().Value(requestSummaryKey{}).(*requestSummary); ok {
+		summary.Function = function
+		if rt != nil {
+			summary.Route = rt.Path
+		}
+	}
+}
+
+// Counts what the handler writes.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Summarize every request to next once it has been answered, passing the
+// summary to each observer in turn. With no observers next is returned as is.
+func observeRequests(next http.Handler, observers ...func(*requestSummary)) http.Handler {
+	if len(observers) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Share the summary with any other observing middleware, so the
+		// function and route recorded further in reach all of them.
+		summary, ok := r.Context().Value(requestSummaryKey{}).(*requestSummary)
+		if !ok {
+			summary = &requestSummary{
+				Time:          time.Now(),
+				RequestID:     requestID(r),
+				CorrelationID: correlationID(r),
+				RemoteAddr:    r.RemoteAddr,
+				Method:        r.Method,
+				Path:          r.URL.Path,
+				Query:         r.URL.RawQuery,
+				Proto:         r.Proto,
+				Referer:       r.Referer(),
+				UserAgent:     r.UserAgent(),
+			}
+			r = r.WithContext(context.WithValue(r.Context(), requestSummaryKey{}, summary))
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			// A handler that panics, as chaos does to drop a connection,
+			// has the connection closed on it. Observe it anyway before
+			// letting the panic go on to the server.
+			if err := recover(); err != nil {
+				summarize(summary, sw, r)
+				if sw.status == 0 {
+					summary.Status = statusConnectionDropped
+				}
+				for _, observe := range observers {
+					observe(summary)
+				}
+				panic(err)
+			}
+		}()
+		next.ServeHTTP(sw, r)
+		summarize(summary, sw, r)
+		for _, observe := range observers {
+			observe(summary)
+		}
+	})
+}
+
+// Recorded for requests whose connection was closed without a response, as
+// nginx does.
+const statusConnectionDropped = 444
+
+// Fill in how the request was answered.
+func summarize(summary *requestSummary, sw *statusWriter, r *http.Request) {
+	summary.Status = sw.status
+	if summary.Status == 0 {
+		// Nothing was written, as when the client went away mid-invocation.
+		summary.Status = http.StatusOK
+		if r.Context().Err() != nil {
+			summary.Status = 499
+		}
+	}
+	summary.Bytes = sw.bytes
+	summary.Latency = time.Since(summary.Time)
+}
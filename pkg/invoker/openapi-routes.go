@@ -0,0 +1,139 @@
+package invoker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace", "x-amazon-apigateway-any-method"}
+
+// Read routes from an OpenAPI 3 file in YAML or JSON. Each operation becomes
+// a route, sent to the function in its x-amazon-apigateway-integration. Where
+// the spec sets up API Gateway request validators, requests are checked the
+// same way before the function is invoked.
+func loadOpenAPIRoutes(file string) (routeTable, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI file %v: %v", file, err)
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("invalid OpenAPI file %v: no paths", file)
+	}
+	validators, _ := spec["x-amazon-apigateway-request-validators"].(map[string]interface{})
+	defaultValidator, _ := spec["x-amazon-apigateway-request-validator"].(string)
+
+	var table routeTable
+	for path, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for _, method := range openAPIMethods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rt := &route{Path: path}
+			if method != "x-amazon-apigateway-any-method" {
+				rt.Method = strings.ToUpper(method)
+			}
+			integration, _ := operation["x-amazon-apigateway-integration"].(map[string]interface{})
+			rt.Function = integrationFunction(integration["uri"])
+			if millis, ok := integration["timeoutInMillis"].(int); ok {
+				rt.Timeout = (time.Duration(millis) * time.Millisecond).String()
+			}
+			// Documents written by the openapi command keep everything here.
+			if extension, ok := operation["x-http-lambda-invoker"].(map[string]interface{}); ok {
+				for key, field := range map[string]*string{"function": &rt.Function, "region": &rt.Region, "endpoint": &rt.Endpoint, "timeout": &rt.Timeout} {
+					if value, ok := extension[key].(string); ok {
+						*field = value
+					}
+				}
+			}
+
+			validatorName := defaultValidator
+			if name, ok := operation["x-amazon-apigateway-request-validator"].(string); ok {
+				validatorName = name
+			}
+			if validator, ok := validators[validatorName].(map[string]interface{}); ok {
+				rt.validation = newRequestValidation(spec, item, operation, validator)
+			}
+
+			if err := rt.compile(); err != nil {
+				return nil, fmt.Errorf("invalid OpenAPI file %v: %v", file, err)
+			}
+			table = append(table, rt)
+		}
+	}
+	sortRoutes(table)
+	return table, nil
+}
+
+// The function an integration URI such as
+// arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:users/invocations
+// invokes. A CloudFormation reference such as ${UsersFunction.Arn} gives the
+// logical ID, which is what sam local names functions. Qualifiers are dropped.
+func integrationFunction(uri interface{}) string {
+	if sub, ok := uri.(map[string]interface{}); ok {
+		uri = sub["Fn::Sub"]
+	}
+	s, _ := uri.(string)
+	start := strings.Index(s, "functions/")
+	if start < 0 {
+		return ""
+	}
+	function := strings.TrimSuffix(s[start+len("functions/"):], "/invocations")
+	if strings.HasPrefix(function, "${") && strings.HasSuffix(function, "}") {
+		return strings.TrimSuffix(strings.TrimPrefix(function, "${"), ".Arn}")
+	}
+	if i := strings.Index(function, ":function:"); i >= 0 {
+		function = function[i+len(":function:"):]
+	}
+	return strings.SplitN(function, ":", 2)[0]
+}
+
+// Order routes the way API Gateway picks between them, since a map of paths
+// has no order: literal segments before {param}, and {proxy+} last, with
+// routes for a method before those for any method.
+func sortRoutes(table routeTable) {
+	rank := func(segment string) int {
+		switch {
+		case strings.HasSuffix(segment, "+}"):
+			return 2
+		case strings.HasPrefix(segment, "{"):
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(table, func(i, j int) bool {
+		a, b := strings.Split(table[i].Path, "/"), strings.Split(table[j].Path, "/")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if ra, rb := rank(a[k]), rank(b[k]); ra != rb {
+				return ra < rb
+			}
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		if (table[i].Method == "") != (table[j].Method == "") {
+			return table[j].Method == ""
+		}
+		if table[i].Method != table[j].Method {
+			return table[i].Method < table[j].Method
+		}
+		return table[i].Function < table[j].Function
+	})
+}
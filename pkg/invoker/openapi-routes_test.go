@@ -0,0 +1,158 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+const testOpenAPI = `
+openapi: 3.0.1
+x-amazon-apigateway-request-validators:
+  all:
+    validateRequestBody: true
+    validateRequestParameters: true
+x-amazon-apigateway-request-validator: all
+paths:
+  /users/{id}:
+    get:
+      x-amazon-apigateway-integration:
+        type: aws_proxy
+        uri: arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:users:live/invocations
+        timeoutInMillis: 2000
+  /users/me:
+    x-amazon-apigateway-any-method:
+      x-amazon-apigateway-integration:
+        uri:
+          Fn::Sub: arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${MeFunction.Arn}/invocations
+  /users:
+    post:
+      parameters:
+        - name: X-Api-Key
+          in: header
+          required: true
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/User'
+  /{proxy+}:
+    x-amazon-apigateway-any-method:
+      x-amazon-apigateway-request-validator: none
+components:
+  schemas:
+    User:
+      type: object
+      required: [name]
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          minLength: 1
+        age:
+          type: integer
+          minimum: 0
+        tags:
+          type: array
+          items:
+            type: string
+`
+
+func TestLoadOpenAPIRoutes(t *testing.T) {
+	table, err := loadOpenAPIRoutes(writeConfigFile(t, "openapi*.yaml", testOpenAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, rt := range table {
+		order = append(order, rt.Method+" "+rt.Path)
+	}
+	if strings.Join(order, ",") != " /users/me,GET /users/{id},POST /users, /{proxy+}" {
+		t.Errorf("unexpected routes %q", order)
+	}
+
+	for _, c := range []struct {
+		method   string
+		path     string
+		function string
+		timeout  string
+	}{
+		{"GET", "/users/42", "users", "2s"},
+		{"DELETE", "/users/me", "MeFunction", ""},
+		{"GET", "/users/me", "MeFunction", ""},
+		{"GET", "/other", "", ""},
+	} {
+		rt, _ := table.match(c.method, c.path)
+		if rt == nil || rt.Function != c.function || rt.Timeout != c.timeout {
+			t.Errorf("%v %v: unexpected route %+v", c.method, c.path, rt)
+		}
+	}
+	if rt, _ := table.match("GET", "/other"); rt.validation != nil {
+		t.Error("expected no validation where the validator is turned off")
+	}
+
+	if _, err := loadOpenAPIRoutes(writeConfigFile(t, "openapi*.yaml", "openapi: 3.0.1\n")); err == nil {
+		t.Error("expected an error for a spec with no paths")
+	}
+}
+
+func TestOpenAPIRoundTrip(t *testing.T) {
+	rt := &route{Method: "GET", Path: "/orders/{id}", Function: "orders", Region: "us-west-2", Timeout: "5s"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	doc := openAPIFromRoutes(routeTable{rt})
+	data, _ := json.Marshal(doc)
+	table, err := loadOpenAPIRoutes(writeConfigFile(t, "openapi*.yaml", string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 1 || table[0].Function != "orders" || table[0].Region != "us-west-2" || table[0].Timeout != "5s" {
+		t.Errorf("unexpected routes %+v", table)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	table, err := loadOpenAPIRoutes(writeConfigFile(t, "openapi*.yaml", testOpenAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(table)
+	defer setRoutes(nil)
+
+	for _, c := range []struct {
+		body    string
+		apiKey  string
+		status  int
+		message string
+	}{
+		{`{"name":"Ann","age":30,"tags":["a"]}`, "key", http.StatusOK, ""},
+		{`{"name":"Ann"}`, "", http.StatusBadRequest, "Missing required request parameters: [X-Api-Key]"},
+		{``, "key", http.StatusBadRequest, "Invalid request body"},
+		{`{"age":30}`, "key", http.StatusBadRequest, "Invalid request body"},
+		{`{"name":"Ann","age":1.5}`, "key", http.StatusBadRequest, "Invalid request body"},
+		{`{"name":"Ann","tags":[1]}`, "key", http.StatusBadRequest, "Invalid request body"},
+		{`{"name":"Ann","extra":true}`, "key", http.StatusBadRequest, "Invalid request body"},
+		{`{"name":""}`, "key", http.StatusBadRequest, "Invalid request body"},
+		{`not json`, "key", http.StatusBadRequest, "Invalid request body"},
+	} {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(c.body))
+		if c.apiKey != "" {
+			req.Header.Set("X-Api-Key", c.apiKey)
+		}
+		rr := httptest.NewRecorder()
+		l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200}`)}}}
+		l.invokeLambda(rr, req)
+		if rr.Code != c.status || !strings.Contains(rr.Body.String(), c.message) {
+			t.Errorf("%v: unexpected response %v %s", c.body, rr.Code, rr.Body)
+		}
+	}
+}
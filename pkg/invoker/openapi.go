@@ -0,0 +1,117 @@
+package invoker
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Methods a route without one is documented with.
+var anyMethods = []string{"get", "post", "put", "patch", "delete"}
+
+type openAPIDocument struct {
+	OpenAPI string                                 `json:"openapi" yaml:"openapi"`
+	Info    openAPIInfo                            `json:"info" yaml:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths" yaml:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses" yaml:"responses"`
+	Function    *openAPIFunction           `json:"x-http-lambda-invoker,omitempty" yaml:"x-http-lambda-invoker,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string            `json:"name" yaml:"name"`
+	In       string            `json:"in" yaml:"in"`
+	Required bool              `json:"required" yaml:"required"`
+	Schema   map[string]string `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description" yaml:"description"`
+}
+
+// The route settings OpenAPI has no place for, so a document can be turned
+// back into routes.
+type openAPIFunction struct {
+	Function string `json:"function,omitempty" yaml:"function,omitempty"`
+	Region   string `json:"region,omitempty" yaml:"region,omitempty"`
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Timeout  string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Describe the routes as an OpenAPI 3 document. Greedy {proxy+} parameters
+// are written the way API Gateway exports them, and routes that match any
+// method get an operation for each of the usual ones. Like matching, the
+// first route for a method and path wins.
+func openAPIFromRoutes(table routeTable) openAPIDocument {
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "http-lambda-invoker", Version: version},
+		Paths:   make(map[string]map[string]openAPIOperation),
+	}
+	for _, rt := range table {
+		methods := anyMethods
+		if rt.Method != "" {
+			methods = []string{strings.ToLower(rt.Method)}
+		}
+		var parameters []openAPIParameter
+		for _, segment := range strings.Split(rt.Path, "/") {
+			if param := pathParameter.FindStringSubmatch(segment); param != nil {
+				parameters = append(parameters, openAPIParameter{Name: param[1], In: "path", Required: true, Schema: map[string]string{"type": "string"}})
+			}
+		}
+		var function *openAPIFunction
+		if rt.Function != "" || rt.Region != "" || rt.Endpoint != "" || rt.Timeout != "" {
+			function = &openAPIFunction{rt.Function, rt.Region, rt.Endpoint, rt.Timeout}
+		}
+
+		item := doc.Paths[rt.Path]
+		if item == nil {
+			item = make(map[string]openAPIOperation)
+			doc.Paths[rt.Path] = item
+		}
+		for _, method := range methods {
+			if _, ok := item[method]; ok {
+				continue
+			}
+			item[method] = openAPIOperation{
+				OperationID: operationID(method, rt.Path),
+				Parameters:  parameters,
+				Responses:   map[string]openAPIResponse{"default": {Description: "Response from the function"}},
+				Function:    function,
+			}
+		}
+	}
+	return doc
+}
+
+// An operation ID such as getUsersId for GET /users/{id}.
+func operationID(method string, path string) string {
+	id := []rune(method)
+	upper := true
+	for _, c := range path {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		id = append(id, c)
+	}
+	return string(id)
+}
+
+// Serve the current routes as an OpenAPI document.
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIFromRoutes(currentRoutes()))
+}
@@ -0,0 +1,51 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPIFromRoutes(t *testing.T) {
+	var table routeTable
+	for _, rt := range []*route{
+		{Method: "GET", Path: "/users/{id}", Timeout: "2s"},
+		{Path: "/users/{id}", Function: "users"},
+		{Path: "/files/{proxy+}"},
+	} {
+		if err := rt.compile(); err != nil {
+			t.Fatal(err)
+		}
+		table = append(table, rt)
+	}
+
+	doc := openAPIFromRoutes(table)
+	users := doc.Paths["/users/{id}"]
+	if len(users) != len(anyMethods) {
+		t.Fatalf("expected an operation per method, got %v", users)
+	}
+	if get := users["get"]; get.Function == nil || get.Function.Timeout != "2s" || get.Function.Function != "" {
+		t.Errorf("expected the first route to win for GET, got %+v", get.Function)
+	}
+	post := users["post"]
+	if post.OperationID != "postUsersId" || post.Function == nil || post.Function.Function != "users" {
+		t.Errorf("unexpected operation %+v", post)
+	}
+	expected := []openAPIParameter{{Name: "id", In: "path", Required: true, Schema: map[string]string{"type": "string"}}}
+	if !reflect.DeepEqual(post.Parameters, expected) {
+		t.Errorf("unexpected parameters %+v", post.Parameters)
+	}
+	if files := doc.Paths["/files/{proxy+}"]["delete"]; len(files.Parameters) != 1 || files.Parameters[0].Name != "proxy" || files.Function != nil {
+		t.Errorf("unexpected operation %+v", files)
+	}
+
+	setRoutes(table)
+	defer setRoutes(nil)
+	rr := httptest.NewRecorder()
+	newAdminMux(false, nil).ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	var served map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &served); err != nil || served["openapi"] != "3.0.3" {
+		t.Errorf("unexpected document %s", rr.Body)
+	}
+}
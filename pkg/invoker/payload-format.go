@@ -0,0 +1,162 @@
+package invoker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The payload format of the HTTP API events a function is invoked with, and
+// of the responses it returns, for rt: its own payloadFormatVersion or else
+// PAYLOAD_FORMAT_VERSION.
+func payloadFormatVersion(rt *route) string {
+	if rt != nil && rt.PayloadFormatVersion != "" {
+		return rt.PayloadFormatVersion
+	}
+	return getConfig("PAYLOAD_FORMAT_VERSION")
+}
+
+func checkPayloadFormatVersion(version string) error {
+	if version != "1.0" && version != "2.0" {
+		return fmt.Errorf("invalid payload format version %q: must be 1.0 or 2.0", version)
+	}
+	return nil
+}
+
+// An HTTP API event in payload format 2.0.
+type httpAPIRequest struct {
+	Version               string                `json:"version"`
+	RouteKey              string                `json:"routeKey"`
+	RawPath               string                `json:"rawPath"`
+	RawQueryString        string                `json:"rawQueryString"`
+	Cookies               []string              `json:"cookies,omitempty"`
+	Headers               map[string]string     `json:"headers"`
+	QueryStringParameters map[string]string     `json:"queryStringParameters,omitempty"`
+	PathParameters        map[string]string     `json:"pathParameters,omitempty"`
+	RequestContext        httpAPIRequestContext `json:"requestContext"`
+	Body                  string                `json:"body,omitempty"`
+	IsBase64Encoded       bool                  `json:"isBase64Encoded"`
+}
+
+type httpAPIRequestContext struct {
+	DomainName     string                 `json:"domainName"`
+	HTTP           httpAPIRequestHTTP     `json:"http"`
+	RequestID      string                 `json:"requestId"`
+	RouteKey       string                 `json:"routeKey"`
+	Stage          string                 `json:"stage"`
+	Time           string                 `json:"time"`
+	TimeEpoch      int64                  `json:"timeEpoch"`
+	Authentication *httpAPIAuthentication `json:"authentication,omitempty"`
+}
+
+type httpAPIRequestHTTP struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Protocol  string `json:"protocol"`
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+type httpAPIAuthentication struct {
+	ClientCert *proxyClientCert `json:"clientCert"`
+}
+
+// The route key an HTTP API would match r with, such as "GET /users/{id}",
+// or "$default" without a route.
+func routeKey(rt *route) string {
+	if rt == nil || rt.Path == "" {
+		return "$default"
+	}
+	method := rt.Method
+	if method == "" {
+		method = "ANY"
+	}
+	return method + " " + rt.Path
+}
+
+// Turn the event built for r, after any plugins changed it, into payload
+// format 2.0: header names are lower case, cookies move out of the headers
+// and repeated query parameters are joined with commas.
+func makeHTTPAPIRequest(r *http.Request, rt *route, event *Event) httpAPIRequest {
+	headers := make(map[string]string, len(event.Headers))
+	var cookies []string
+	for name, value := range event.Headers {
+		name = strings.ToLower(name)
+		if name == "cookie" {
+			for _, cookie := range strings.Split(value, ";") {
+				if cookie = strings.TrimSpace(cookie); cookie != "" {
+					cookies = append(cookies, cookie)
+				}
+			}
+			continue
+		}
+		headers[name] = value
+	}
+	var query map[string]string
+	for name, values := range event.QueryStringParams {
+		if query == nil {
+			query = make(map[string]string, len(event.QueryStringParams))
+		}
+		query[name] = strings.Join(values, ",")
+	}
+	sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		sourceIP = r.RemoteAddr
+	}
+	now := time.Now()
+	key := routeKey(rt)
+	request := httpAPIRequest{
+		Version:               "2.0",
+		RouteKey:              key,
+		RawPath:               event.Path,
+		RawQueryString:        r.URL.RawQuery,
+		Cookies:               cookies,
+		Headers:               headers,
+		QueryStringParameters: query,
+		PathParameters:        event.PathParameters,
+		RequestContext: httpAPIRequestContext{
+			DomainName: r.Host,
+			HTTP: httpAPIRequestHTTP{
+				Method:    event.HTTPMethod,
+				Path:      event.Path,
+				Protocol:  r.Proto,
+				SourceIP:  sourceIP,
+				UserAgent: r.UserAgent(),
+			},
+			RequestID: event.RequestContext.RequestID,
+			RouteKey:  key,
+			Stage:     "$default",
+			Time:      now.Format("02/Jan/2006:15:04:05 -0700"),
+			TimeEpoch: now.UnixNano() / int64(time.Millisecond),
+		},
+		Body:            event.Body,
+		IsBase64Encoded: event.IsBase64Encoded,
+	}
+	if cert := event.RequestContext.Identity.ClientCert; cert != nil {
+		request.RequestContext.Authentication = &httpAPIAuthentication{ClientCert: cert}
+	}
+	return request
+}
+
+// With payload format 2.0 a function can return any JSON, which an HTTP API
+// sends as a 200 with a Content-Type of application/json unless it's an
+// object with a statusCode. Turn that into a full response.
+func httpAPIResponse(payload []byte) []byte {
+	var probe struct {
+		StatusCode *int `json:"statusCode"`
+	}
+	trimmed := bytes.TrimSpace(payload)
+	if bytes.HasPrefix(trimmed, []byte("{")) && json.Unmarshal(trimmed, &probe) == nil && probe.StatusCode != nil {
+		return payload
+	}
+	response, _ := json.Marshal(Response{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(trimmed),
+	})
+	return response
+}
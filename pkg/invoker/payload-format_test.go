@@ -0,0 +1,82 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestPayloadFormatVersion(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	v2 := &route{Method: "GET", Path: "/orders/{id}", PayloadFormatVersion: "2.0"}
+	v1 := &route{Path: "/users"}
+	for _, rt := range []*route{v2, v1} {
+		if err := rt.compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setRoutes(routeTable{v2, v1})
+	defer setRoutes(nil)
+
+	var input lambda.InvokeInput
+	c := LambdaClient{payloadLambdaClient{input: &input}}
+	req := httptest.NewRequest("GET", "/orders/42?tag=a&tag=b", nil)
+	req.Header.Set("Cookie", "session=abc; theme=dark")
+	req.Header.Set("X-Tenant", "acme")
+	rr := httptest.NewRecorder()
+	c.invokeLambda(rr, req)
+
+	var event httpAPIRequest
+	if err := json.Unmarshal(input.Payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Version != "2.0" || event.RouteKey != "GET /orders/{id}" || event.RawPath != "/orders/42" || event.RawQueryString != "tag=a&tag=b" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.QueryStringParameters["tag"] != "a,b" || event.PathParameters["id"] != "42" || event.Headers["x-tenant"] != "acme" {
+		t.Errorf("unexpected parameters or headers in %+v", event)
+	}
+	if len(event.Cookies) != 2 || event.Cookies[1] != "theme=dark" || event.Headers["cookie"] != "" {
+		t.Errorf("expected cookies apart from the headers, got %v", event.Cookies)
+	}
+	if event.RequestContext.HTTP.Method != "GET" || event.RequestContext.RequestID == "" {
+		t.Errorf("unexpected request context %+v", event.RequestContext)
+	}
+	// A response without a statusCode is the body of a 200.
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" || rr.Body.String() != "null" {
+		t.Errorf("unexpected response %v %v %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+
+	var restEvent makeProxyRequest
+	c = LambdaClient{eventLambdaClient{event: &restEvent}}
+	c.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	if restEvent.HTTPMethod != "GET" || restEvent.Path != "/users" {
+		t.Errorf("expected a REST API event for the other route, got %+v", restEvent)
+	}
+
+	if err := (&route{Path: "/users", PayloadFormatVersion: "3.0"}).compile(); err == nil {
+		t.Error("expected an error for an unknown payload format version")
+	}
+}
+
+func TestHTTPAPIResponse(t *testing.T) {
+	payload := []byte(`{"statusCode":201,"cookies":["a=1","b=2"],"body":"created"}`)
+	if string(httpAPIResponse(payload)) != string(payload) {
+		t.Error("expected a full response to be kept")
+	}
+	rr := httptest.NewRecorder()
+	writeResponse(rr, httptest.NewRequest("POST", "/", nil), nil, payload, logFields{})
+	if rr.Code != 201 || len(rr.Header()["Set-Cookie"]) != 2 {
+		t.Errorf("expected a cookie header each, got %v", rr.Header())
+	}
+	var response Response
+	json.Unmarshal(httpAPIResponse([]byte(`{"message":"hi"}`)), &response)
+	if response.StatusCode != 200 || response.Body != `{"message":"hi"}` {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
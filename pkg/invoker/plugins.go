@@ -0,0 +1,92 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"plugin"
+	"strings"
+)
+
+// Plugin changes events before functions are invoked with them, and their
+// responses before they're sent back, such as to add claims a proprietary
+// authorizer would. A Go plugin built with -buildmode=plugin and named in
+// PLUGINS provides one by exporting a variable called Plugin.
+type Plugin interface {
+	TransformRequest(event *Event) error
+	TransformResponse(event *Event, response *Response) error
+}
+
+// Event is the API Gateway proxy event a function is invoked with.
+type Event = makeProxyRequest
+
+// Response is what a function returns for API Gateway to turn into the HTTP
+// response.
+type Response struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+}
+
+// Plugins from PLUGINS and those given to New, in the order they run.
+var plugins []Plugin
+
+// Open the comma separated Go plugins in paths. Opening the same path again
+// returns the plugin already loaded, as they can't be unloaded.
+func loadPlugins(paths string) ([]Plugin, error) {
+	var loaded []Plugin
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		p, err := plugin.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PLUGINS: %v", err)
+		}
+		symbol, err := p.Lookup("Plugin")
+		if err != nil {
+			return nil, fmt.Errorf("invalid PLUGINS: %v", err)
+		}
+		// Lookup gives a pointer to the variable, which is itself a Plugin when
+		// the variable is declared as one.
+		if ptr, ok := symbol.(*Plugin); ok {
+			symbol = *ptr
+		}
+		transformer, ok := symbol.(Plugin)
+		if !ok {
+			return nil, fmt.Errorf("invalid PLUGINS: Plugin in %v doesn't implement TransformRequest and TransformResponse", path)
+		}
+		loaded = append(loaded, transformer)
+	}
+	return loaded, nil
+}
+
+// Let each plugin change the event in turn.
+func transformRequest(event *Event) error {
+	for _, p := range plugins {
+		if err := p.TransformRequest(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Let each plugin change the function's response in turn, in the order they
+// saw the event. The payload is only decoded when there are plugins.
+func transformResponse(event *Event, payload []byte) ([]byte, error) {
+	if len(plugins) == 0 {
+		return payload, nil
+	}
+	var response Response
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if err := p.TransformResponse(event, &response); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(response)
+}
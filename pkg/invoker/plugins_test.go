@@ -0,0 +1,60 @@
+package invoker
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+type tenantPlugin struct {
+	err error
+}
+
+func (p tenantPlugin) TransformRequest(event *Event) error {
+	event.Headers["X-Tenant"] = "acme"
+	return p.err
+}
+
+func (p tenantPlugin) TransformResponse(event *Event, response *Response) error {
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	response.Headers["X-Tenant"] = event.Headers["X-Tenant"]
+	response.StatusCode = 201
+	return nil
+}
+
+func TestPlugins(t *testing.T) {
+	defer setRoutes(nil)
+
+	var event makeProxyRequest
+	handler, err := New(Config{
+		Settings: map[string]string{"LAMBDA_NAME": "users", "ACCESS_LOG": "off"},
+		Client:   eventLambdaClient{event: &event},
+		Plugins:  []Plugin{tenantPlugin{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+	if event.Headers["X-Tenant"] != "acme" {
+		t.Errorf("expected the plugin to change the event, got %v", event.Headers)
+	}
+	if rr.Code != 201 || rr.Header().Get("X-Tenant") != "acme" {
+		t.Errorf("expected the plugin to change the response, got %v %v", rr.Code, rr.Header())
+	}
+
+	event = makeProxyRequest{}
+	plugins = []Plugin{tenantPlugin{err: errors.New("no tenant")}}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+	if rr.Code != 500 || event.Path != "" {
+		t.Errorf("expected a failing plugin to stop the request, got %v", rr.Code)
+	}
+
+	if _, err := loadPlugins("testdata/missing.so"); err == nil {
+		t.Error("expected an error for a missing plugin")
+	}
+}
@@ -0,0 +1,109 @@
+package invoker
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The credentials a client needs to reach the proxy: user:password pairs for
+// Basic auth and a static bearer token.
+type proxyCredentials struct {
+	basic []string
+	token string
+}
+
+// Parse BASIC_AUTH, comma separated user:password pairs, and AUTH_TOKEN.
+func newProxyCredentials(basicAuth string, token string) (*proxyCredentials, error) {
+	creds := &proxyCredentials{token: token}
+	for _, pair := range strings.Split(basicAuth, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		if i := strings.Index(pair, ":"); i <= 0 {
+			return nil, fmt.Errorf("invalid BASIC_AUTH %q: expected user:password", pair)
+		}
+		creds.basic = append(creds.basic, pair)
+	}
+	if len(creds.basic) == 0 && creds.token == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// Whether an Authorization header value holds one of the credentials.
+// Every pair is compared, in constant time, so timing says nothing of which
+// came close.
+func (c *proxyCredentials) allow(authorization string) bool {
+	scheme, value := authorization, ""
+	if i := strings.Index(authorization, " "); i >= 0 {
+		scheme, value = authorization[:i], strings.TrimSpace(authorization[i+1:])
+	}
+	allowed := false
+	switch strings.ToLower(scheme) {
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return false
+		}
+		for _, pair := range c.basic {
+			if subtle.ConstantTimeCompare(decoded, []byte(pair)) == 1 {
+				allowed = true
+			}
+		}
+	case "bearer":
+		allowed = c.token != "" && subtle.ConstantTimeCompare([]byte(value), []byte(c.token)) == 1
+	}
+	return allowed
+}
+
+// Require Basic auth or AUTH_TOKEN as a bearer token to reach the proxy, for
+// when it's on a shared host or tunnelled to the internet. The credentials
+// can be sent in Proxy-Authorization, leaving Authorization for the
+// function's own auth, or in Authorization. Whichever header held them is
+// removed before the request goes on, so functions never see them. CORS
+// preflight requests, which browsers send without credentials, are answered
+// here rather than passed on, so they can't reach a function unchecked.
+// Without credentials next is returned as is.
+func requireProxyAuth(creds *proxyCredentials, next http.Handler) http.Handler {
+	if creds == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			answerPreflight(w, r)
+			return
+		}
+		switch {
+		case creds.allow(r.Header.Get("Proxy-Authorization")):
+			r.Header.Del("Proxy-Authorization")
+		case creds.allow(r.Header.Get("Authorization")):
+			r.Header.Del("Authorization")
+		default:
+			logInfo("Unauthorized request to the proxy", logFields{"request_id": requestID(r), "method": r.Method, "path": r.URL.Path, "remote_addr": r.RemoteAddr})
+			if len(creds.basic) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="http-lambda-invoker", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="http-lambda-invoker"`)
+			}
+			gatewayError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allow the method and headers a preflight asks for, so the browser goes on
+// to send the real request with its credentials. Access-Control-Allow-Origin
+// is left to the cors middleware, which runs first.
+func answerPreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package invoker
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// One request and the function's answer, as a line of RECORD_FILE.
+type recordedExchange struct {
+	Time      time.Time       `json:"time"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Function  string          `json:"function"`
+	LatencyMs float64         `json:"latencyMs"`
+	Event     json.RawMessage `json:"event"`
+	Response  json.RawMessage `json:"response"`
+}
+
+var (
+	recordMu    sync.Mutex
+	recordFiles = make(map[string]*os.File)
+)
+
+// Payloads that aren't JSON are recorded as strings.
+func recordedPayload(payload []byte) json.RawMessage {
+	if json.Valid(payload) {
+		return payload
+	}
+	quoted, _ := json.Marshal(string(payload))
+	return quoted
+}
+
+// Append an exchange to file as a JSON line. The file is opened the first time
+// it's used and kept open, so each line is a single append.
+func recordExchange(file string, exchange recordedExchange) error {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return err
+	}
+
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	f, ok := recordFiles[file]
+	if !ok {
+		if f, err = os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return err
+		}
+		recordFiles[file] = f
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
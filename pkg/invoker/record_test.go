@@ -0,0 +1,60 @@
+package invoker
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestRecordExchanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "traffic.jsonl")
+	os.Setenv("RECORD_FILE", file)
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("RECORD_FILE")
+	defer os.Unsetenv("LAMBDA_NAME")
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200,"body":"hi"}`)}}}
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("POST", "/users", strings.NewReader("hello")))
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var exchanges []recordedExchange
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var exchange recordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			t.Fatal(err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("expected 2 recorded exchanges, got %v", len(exchanges))
+	}
+	first := exchanges[0]
+	if first.Method != "POST" || first.Path != "/users" || first.Function != "MyFunction" || first.Time.IsZero() {
+		t.Errorf("unexpected recorded exchange %+v", first)
+	}
+	var event makeProxyRequest
+	if err := json.Unmarshal(first.Event, &event); err != nil || event.Body != "hello" {
+		t.Errorf("expected the event to be recorded, got %s", first.Event)
+	}
+	if string(first.Response) != `{"statusCode":200,"body":"hi"}` {
+		t.Errorf("expected the response to be recorded, got %s", first.Response)
+	}
+}
@@ -0,0 +1,49 @@
+package invoker
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// The port of the first HTTPS listener, which plain HTTP requests are
+// redirected to.
+func httpsPort(specs []listenerSpec) (string, error) {
+	for _, spec := range specs {
+		if spec.Network == "tcp" && spec.TLS {
+			_, port, err := net.SplitHostPort(spec.Address)
+			return port, err
+		}
+	}
+	return "", fmt.Errorf("HTTPS_REDIRECT_PORT needs an HTTPS listener")
+}
+
+// Answer requests arriving on redirectPort with a 301 to the same URL on
+// toPort over HTTPS, the way a gateway that only serves HTTPS would. Requests
+// on every other listener are passed to next.
+func redirectToHTTPS(next http.Handler, redirectPort string, toPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		if r.TLS != nil || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, port, err := net.SplitHostPort(addr.String()); err != nil || port != redirectPort {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if toPort != "443" {
+			host = net.JoinHostPort(host, toPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
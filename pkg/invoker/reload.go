@@ -0,0 +1,110 @@
+package invoker
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Files the configuration is read from, which watchConfig checks for changes.
+func configFiles() []string {
+	var files []string
+	for _, key := range []string{"CONFIG_FILE", "ROUTES_FILE", "OPENAPI_FILE", "SAM_TEMPLATE", "SERVERLESS_FILE", "CDK_OUT", "REPLAY_FILE"} {
+		if file := getConfig(key); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Modification times of the configuration files, so changes can be spotted
+// without pulling in a file notification library.
+func configModTimes() map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, file := range configFiles() {
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		}
+	}
+	return times
+}
+
+func sameModTimes(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, t := range a {
+		if !b[file].Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Load CONFIG_FILE, the routes, the header rules and REPLAY_FILE and swap
+// them in. If anything
+// is invalid the previous configuration stays in place.
+func reloadConfig() error {
+	cfg, err := readConfigFile(getConfig("CONFIG_FILE"))
+	if err != nil {
+		return err
+	}
+	previous := currentConfigFile()
+	setConfigFile(cfg)
+
+	table, err := loadRouteConfig()
+	if err != nil {
+		setConfigFile(previous)
+		return err
+	}
+	headerRules, err := loadHeaderRules()
+	if err != nil {
+		setConfigFile(previous)
+		return err
+	}
+	replayTable, err := loadReplayFile(getConfig("REPLAY_FILE"))
+	if err != nil {
+		setConfigFile(previous)
+		return err
+	}
+	setRoutes(table)
+	setHeaderRules(headerRules)
+	setReplay(replayTable)
+	return nil
+}
+
+// Reload the configuration whenever a signal arrives on hup or, if poll is
+// set, when a configuration file changes, until ctx is done.
+func watchConfig(ctx context.Context, hup <-chan os.Signal, poll time.Duration) {
+	modTimes := configModTimes()
+
+	var tick <-chan time.Time
+	if poll > 0 {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var reason string
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-hup:
+			reason = sig.String()
+		case <-tick:
+			latest := configModTimes()
+			if sameModTimes(modTimes, latest) {
+				continue
+			}
+			reason = "configuration file change"
+		}
+		modTimes = configModTimes()
+
+		if err := reloadConfig(); err != nil {
+			logError("Failed to reload configuration, keeping the previous one", logFields{"error": err})
+			continue
+		}
+		logInfo("Reloaded configuration", logFields{"reason": reason})
+	}
+}
@@ -0,0 +1,75 @@
+package invoker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Wait for the route table to contain a route for path.
+func waitForRoute(path string) bool {
+	for i := 0; i < 100; i++ {
+		if rt, _ := currentRoutes().match("GET", path); rt != nil {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReloadOnSignal(t *testing.T) {
+	file := writeConfigFile(t, "routes*.json", `[{"path": "/before"}]`)
+	os.Setenv("ROUTES_FILE", file)
+	defer os.Unsetenv("ROUTES_FILE")
+	defer setRoutes(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hup := make(chan os.Signal, 1)
+	go watchConfig(ctx, hup, 0)
+
+	if err := ioutil.WriteFile(file, []byte(`[{"path": "/after"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hup <- syscall.SIGHUP
+	if !waitForRoute("/after") {
+		t.Error("routes were not reloaded after SIGHUP")
+	}
+
+	// A broken file leaves the previous routes in place.
+	if err := ioutil.WriteFile(file, []byte(`[{"path": "no-slash"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err == nil {
+		t.Error("expected an error reloading an invalid routes file")
+	}
+	if rt, _ := currentRoutes().match("GET", "/after"); rt == nil {
+		t.Error("previous routes were not kept after a failed reload")
+	}
+}
+
+func TestReloadOnFileChange(t *testing.T) {
+	file := writeConfigFile(t, "routes*.json", `[{"path": "/before"}]`)
+	os.Setenv("ROUTES_FILE", file)
+	defer os.Unsetenv("ROUTES_FILE")
+	defer setRoutes(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchConfig(ctx, nil, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if err := ioutil.WriteFile(file, []byte(`[{"path": "/changed"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !waitForRoute("/changed") {
+		t.Error("routes were not reloaded after the file changed")
+	}
+}
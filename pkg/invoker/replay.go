@@ -0,0 +1,86 @@
+package invoker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+)
+
+// Recorded responses by method and path, for answering requests without
+// invoking anything.
+type replayTable struct {
+	mu        sync.Mutex
+	responses map[string][]json.RawMessage
+	next      map[string]int
+}
+
+// The replay table loaded from REPLAY_FILE, if there is one.
+var replay atomic.Value
+
+func currentReplay() *replayTable {
+	table, _ := replay.Load().(*replayTable)
+	return table
+}
+
+func setReplay(table *replayTable) {
+	replay.Store(table)
+}
+
+// Whether every request is answered from REPLAY_FILE, so no function is
+// needed at all.
+func replayOnly() bool {
+	return getConfig("REPLAY_FILE") != "" && getConfig("REPLAY_FALLBACK") != "invoke"
+}
+
+// Read a file of exchanges in RECORD_FILE's format, one JSON object per line.
+// Hand written fixtures only need method, path and response.
+func loadReplayFile(file string) (*replayTable, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	table := &replayTable{responses: make(map[string][]json.RawMessage), next: make(map[string]int)}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange recordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("invalid replay file %v line %v: %v", file, line, err)
+		}
+		if exchange.Method == "" || exchange.Path == "" || len(exchange.Response) == 0 {
+			return nil, fmt.Errorf("invalid replay file %v line %v: method, path and response are required", file, line)
+		}
+		key := exchange.Method + " " + exchange.Path
+		table.responses[key] = append(table.responses[key], exchange.Response)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid replay file %v: %v", file, err)
+	}
+	return table, nil
+}
+
+// The recorded response for a request. When a method and path were recorded
+// more than once the responses are returned in turn, starting again after
+// the last, so a recorded session plays back in order.
+func (t *replayTable) lookup(method string, path string) (json.RawMessage, bool) {
+	key := method + " " + path
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	responses := t.responses[key]
+	if len(responses) == 0 {
+		return nil, false
+	}
+	i := t.next[key]
+	t.next[key] = (i + 1) % len(responses)
+	return responses[i], true
+}
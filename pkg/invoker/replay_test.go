@@ -0,0 +1,78 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestReplay(t *testing.T) {
+	file := writeConfigFile(t, "replay*.jsonl", `{"method":"GET","path":"/users/1","response":{"statusCode":200,"body":"first"}}
+
+{"method":"GET","path":"/users/1","response":{"statusCode":200,"body":"second"}}
+`)
+	table, err := loadReplayFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setReplay(table)
+	defer setReplay(nil)
+
+	l := LambdaClient{unusedLambdaClient{}}
+	for _, body := range []string{"first", "second", "first"} {
+		rr := httptest.NewRecorder()
+		l.invokeLambda(rr, httptest.NewRequest("GET", "/users/1", nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != body {
+			t.Errorf("expected %q to be replayed, got %v %q", body, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/users/2", nil))
+	if rr.Code != http.StatusNotFound || rr.Body.String() != `{"message":"No recorded exchange for GET /users/2"}` {
+		t.Errorf("unexpected response without a recording: %v %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReplayFallbackInvokes(t *testing.T) {
+	file := writeConfigFile(t, "replay*.jsonl", `{"method":"GET","path":"/users/1","response":{"statusCode":200,"body":"recorded"}}`)
+	os.Setenv("REPLAY_FILE", file)
+	os.Setenv("REPLAY_FALLBACK", "invoke")
+	defer os.Unsetenv("REPLAY_FILE")
+	defer os.Unsetenv("REPLAY_FALLBACK")
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	defer setReplay(nil)
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200,"body":"live"}`)}}}
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/users/2", nil))
+	if rr.Body.String() != "live" {
+		t.Errorf("expected requests without a recording to invoke the function, got %q", rr.Body.String())
+	}
+}
+
+func TestReplayOnlyNeedsNoFunction(t *testing.T) {
+	os.Setenv("REPLAY_FILE", "fixtures.jsonl")
+	defer os.Unsetenv("REPLAY_FILE")
+	if err := validateConfig(); err != nil {
+		t.Errorf("expected LAMBDA_NAME to be optional when replaying, got %v", err)
+	}
+
+	os.Setenv("REPLAY_FALLBACK", "guess")
+	defer os.Unsetenv("REPLAY_FALLBACK")
+	if err := validateConfig(); err == nil {
+		t.Error("expected an error for an invalid REPLAY_FALLBACK")
+	}
+}
+
+func TestLoadReplayFileInvalid(t *testing.T) {
+	file := writeConfigFile(t, "replay*.jsonl", `{"method":"GET","response":{}}`)
+	if _, err := loadReplayFile(file); err == nil {
+		t.Error("expected an error for an exchange without a path")
+	}
+}
@@ -0,0 +1,131 @@
+package invoker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errRequestTooLarge = errors.New("request body too large")
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// How compressed request bodies reach the function: as they came, which
+// suits functions that decompress them themselves, decompressed, or base64
+// encoded so their bytes arrive intact.
+const (
+	requestEncodingPassthrough = "passthrough"
+	requestEncodingDecompress  = "decompress"
+	requestEncodingBase64      = "base64"
+)
+
+// The route's requestEncoding, or REQUEST_ENCODING.
+func requestEncoding(rt *route) string {
+	if rt != nil && rt.RequestEncoding != "" {
+		return rt.RequestEncoding
+	}
+	return getConfig("REQUEST_ENCODING")
+}
+
+func checkRequestEncoding(encoding string) error {
+	switch encoding {
+	case "", requestEncodingPassthrough, requestEncodingDecompress, requestEncodingBase64:
+		return nil
+	}
+	return fmt.Errorf("invalid request encoding %q: must be passthrough, decompress or base64", encoding)
+}
+
+// Read the request body into a pooled buffer without ever holding more than
+// limit bytes of it. Requests that declare a larger Content-Length are refused
+// before reading anything, and the buffer is sized up front so it doesn't
+// double as it grows. A limit of zero reads the whole body. Release the buffer
+// with putBuffer when done.
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) (*bytes.Buffer, error) {
+	if limit > 0 && r.ContentLength > limit {
+		return nil, errRequestTooLarge
+	}
+
+	buf := getBuffer()
+	if r.ContentLength > 0 {
+		buf.Grow(int(r.ContentLength) + bytes.MinRead)
+	}
+
+	body := r.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, body, limit)
+	}
+	n, err := io.Copy(buf, body)
+	if err != nil {
+		putBuffer(buf)
+		if limit > 0 && n >= limit {
+			return nil, errRequestTooLarge
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// The body as the event carries it, and whether it's base64 encoded. As with
+// API Gateway, multipart bodies such as file uploads are base64 encoded so
+// their bytes arrive intact, and the Content-Type, boundary and all, is passed
+// on untouched for the function to parse them. So are bodies with a
+// Content-Encoding when encoding is base64.
+func eventBody(contentType string, contentEncoding string, encoding string, body []byte) (string, bool) {
+	if encoding == requestEncodingBase64 && contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity") {
+		return base64.StdEncoding.EncodeToString(body), true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		return base64.StdEncoding.EncodeToString(body), true
+	}
+	return string(body), false
+}
+
+// Decompress a gzip or deflate body, as the Content-Encoding says, without
+// ever holding more than limit bytes of the result. The Content-Encoding
+// header is removed and Content-Length is that of the decompressed body, so
+// the function sees the request as if it had been sent uncompressed. A body
+// without a Content-Encoding is returned as is, and the new one is pooled
+// like the old.
+func decompressBody(r *http.Request, body *bytes.Buffer, limit int64) (*bytes.Buffer, error) {
+	var reader io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(body)
+	case "deflate":
+		reader, err = zlib.NewReader(body)
+	default:
+		return nil, errUnsupportedEncoding
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit+1)
+	}
+	decoded := getBuffer()
+	n, err := io.Copy(decoded, reader)
+	if err != nil {
+		putBuffer(decoded)
+		return nil, err
+	}
+	if limit > 0 && n > limit {
+		putBuffer(decoded)
+		return nil, errRequestTooLarge
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Set("Content-Length", strconv.Itoa(decoded.Len()))
+	r.ContentLength = int64(decoded.Len())
+	return decoded, nil
+}
@@ -0,0 +1,62 @@
+package invoker
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Parts of API Gateway's requestContext to send to Lambda.
+type proxyRequestContext struct {
+	RequestID string        `json:"requestId"`
+	Identity  proxyIdentity `json:"identity"`
+}
+
+type proxyIdentity struct {
+	ClientCert *proxyClientCert `json:"clientCert,omitempty"`
+}
+
+// The client certificate presented over mutual TLS, as API Gateway describes it.
+type proxyClientCert struct {
+	ClientCertPem string                `json:"clientCertPem"`
+	SubjectDN     string                `json:"subjectDN"`
+	IssuerDN      string                `json:"issuerDN"`
+	SerialNumber  string                `json:"serialNumber"`
+	Validity      proxyClientCertExpiry `json:"validity"`
+}
+
+type proxyClientCertExpiry struct {
+	NotBefore string `json:"notBefore"`
+	NotAfter  string `json:"notAfter"`
+}
+
+// API Gateway's format for certificate dates, such as "May 28 12:30:02 2019 GMT".
+const clientCertTimeFormat = "Jan 2 15:04:05 2006 GMT"
+
+func makeRequestContext(r *http.Request) proxyRequestContext {
+	requestContext := proxyRequestContext{RequestID: requestID(r)}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		requestContext.Identity.ClientCert = makeClientCert(r.TLS.PeerCertificates[0])
+	}
+	return requestContext
+}
+
+func makeClientCert(cert *x509.Certificate) *proxyClientCert {
+	serial := cert.SerialNumber.Bytes()
+	hex := make([]string, len(serial))
+	for i, b := range serial {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	return &proxyClientCert{
+		ClientCertPem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		SubjectDN:     cert.Subject.String(),
+		IssuerDN:      cert.Issuer.String(),
+		SerialNumber:  strings.Join(hex, ":"),
+		Validity: proxyClientCertExpiry{
+			NotBefore: cert.NotBefore.UTC().Format(clientCertTimeFormat),
+			NotAfter:  cert.NotAfter.UTC().Format(clientCertTimeFormat),
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package invoker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientCertInRequestContext(t *testing.T) {
+	cert := &x509.Certificate{
+		Raw:          []byte("not really DER"),
+		SerialNumber: big.NewInt(0xa1b2c3),
+		Subject:      pkix.Name{CommonName: "client.example.com", Organization: []string{"Example"}},
+		Issuer:       pkix.Name{CommonName: "Example CA"},
+		NotBefore:    time.Date(2019, 5, 28, 12, 30, 2, 0, time.UTC),
+		NotAfter:     time.Date(2020, 5, 28, 12, 30, 2, 0, time.UTC),
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	clientCert := makeRequestContext(req).Identity.ClientCert
+	if clientCert == nil {
+		t.Fatal("expected a client certificate in the request context")
+	}
+	expected := proxyClientCert{
+		SubjectDN:    "CN=client.example.com,O=Example",
+		IssuerDN:     "CN=Example CA",
+		SerialNumber: "a1:b2:c3",
+		Validity: proxyClientCertExpiry{
+			NotBefore: "May 28 12:30:02 2019 GMT",
+			NotAfter:  "May 28 12:30:02 2020 GMT",
+		},
+	}
+	if !strings.HasPrefix(clientCert.ClientCertPem, "-----BEGIN CERTIFICATE-----") {
+		t.Errorf("unexpected clientCertPem: %v", clientCert.ClientCertPem)
+	}
+	clientCert.ClientCertPem = ""
+	if *clientCert != expected {
+		t.Errorf("unexpected client certificate: got %+v want %+v", *clientCert, expected)
+	}
+
+	// Plain requests have no certificate.
+	if makeRequestContext(httptest.NewRequest("GET", "/", nil)).Identity.ClientCert != nil {
+		t.Error("unexpected client certificate without TLS")
+	}
+}
+
+func TestConfigureClientAuth(t *testing.T) {
+	tests := []struct {
+		mode       string
+		clientAuth tls.ClientAuthType
+	}{
+		{"", tls.NoClientCert},
+		{"none", tls.NoClientCert},
+		{"request", tls.RequestClientCert},
+		{"require", tls.RequireAnyClientCert},
+	}
+	for _, test := range tests {
+		os.Setenv("TLS_CLIENT_AUTH", test.mode)
+		cfg := &tls.Config{}
+		if err := configureClientAuth(cfg); err != nil {
+			t.Errorf("%q: unexpected error %v", test.mode, err)
+		} else if cfg.ClientAuth != test.clientAuth {
+			t.Errorf("%q: got %v want %v", test.mode, cfg.ClientAuth, test.clientAuth)
+		}
+	}
+
+	os.Setenv("TLS_CLIENT_AUTH", "always")
+	if err := configureClientAuth(&tls.Config{}); err == nil {
+		t.Error("expected an error for an invalid TLS_CLIENT_AUTH")
+	}
+	os.Unsetenv("TLS_CLIENT_AUTH")
+}
@@ -0,0 +1,83 @@
+package invoker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestRequestEncoding(t *testing.T) {
+	compressed := gzipped(t, `{"name":"ada"}`)
+	decompress := &route{Path: "/users", RequestEncoding: "decompress"}
+	base64Route := &route{Path: "/uploads", RequestEncoding: "base64"}
+	for _, rt := range []*route{decompress, base64Route} {
+		if err := rt.compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var event makeProxyRequest
+	l := LambdaClient{eventLambdaClient{event: &event}}
+	send := func(rt *route, encoding string, body []byte) *httptest.ResponseRecorder {
+		event = makeProxyRequest{}
+		req := httptest.NewRequest("POST", rt.Path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		l.invokeRoute(rr, req, rt, nil)
+		return rr
+	}
+
+	send(decompress, "gzip", compressed)
+	if event.Body != `{"name":"ada"}` || event.IsBase64Encoded {
+		t.Errorf("expected the decompressed body, got %q", event.Body)
+	}
+	if event.Headers["Content-Encoding"] != "" || event.Headers["Content-Length"] != "14" {
+		t.Errorf("expected the headers of an uncompressed body, got %v", event.Headers)
+	}
+
+	send(base64Route, "gzip", compressed)
+	if decoded, err := base64.StdEncoding.DecodeString(event.Body); err != nil || !bytes.Equal(decoded, compressed) || !event.IsBase64Encoded {
+		t.Errorf("expected the compressed body base64 encoded, got %q: %v", event.Body, err)
+	}
+	if event.Headers["Content-Encoding"] != "gzip" {
+		t.Errorf("expected the Content-Encoding to be kept, got %v", event.Headers)
+	}
+
+	send(base64Route, "", []byte(`{"name":"ada"}`))
+	if event.Body != `{"name":"ada"}` || event.IsBase64Encoded {
+		t.Errorf("expected an uncompressed body as is, got %q", event.Body)
+	}
+
+	os.Setenv("MAX_REQUEST_SIZE", "10")
+	rr := send(decompress, "gzip", compressed)
+	os.Unsetenv("MAX_REQUEST_SIZE")
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a 413 for a body too large once decompressed, got %v", rr.Code)
+	}
+	if rr := send(decompress, "gzip", []byte("not gzip")); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400 for a corrupt body, got %v", rr.Code)
+	}
+	if rr := send(decompress, "br", compressed); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a 415 for an unsupported encoding, got %v", rr.Code)
+	}
+
+	if err := (&route{Path: "/users", RequestEncoding: "zip"}).compile(); err == nil || !strings.Contains(err.Error(), "requestEncoding") {
+		t.Errorf("expected an error for an unknown requestEncoding, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package invoker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+type correlationIDKey struct{}
+
+// A random (version 4) UUID, the form API Gateway's request IDs take.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// The ID withRequestID gave this request, or a fresh one if it didn't run.
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return newRequestID()
+}
+
+// The correlation ID withRequestID found or made for this request.
+func correlationID(r *http.Request) string {
+	id, _ := r.Context().Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Give every request an ID, returned in the x-amzn-RequestId header as API
+// Gateway does, sent to the function as requestContext.requestId and logged
+// with the request.
+//
+// A correlation ID sent by the client in header is kept so traces through
+// several services line up, and requests without one use the request ID. It
+// reaches the function in the same header and is echoed in the response.
+func withRequestID(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := newRequestID()
+		w.Header().Set("x-amzn-RequestId", id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		if header != "" {
+			correlation := r.Header.Get(header)
+			if correlation == "" {
+				correlation = id
+				r.Header.Set(header, correlation)
+			}
+			w.Header().Set(header, correlation)
+			ctx = context.WithValue(ctx, correlationIDKey{}, correlation)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
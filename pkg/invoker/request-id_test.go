@@ -0,0 +1,60 @@
+package invoker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if id := newRequestID(); !uuid.MatchString(id) {
+		t.Fatalf("expected a version 4 UUID, got %q", id)
+	}
+
+	var event makeProxyRequest
+	h := withRequestID("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event.RequestContext = makeRequestContext(r)
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	id := rr.Header().Get("x-amzn-RequestId")
+	if !uuid.MatchString(id) || event.RequestContext.RequestID != id {
+		t.Errorf("expected requestContext.requestId %q to match the x-amzn-RequestId header %q", event.RequestContext.RequestID, id)
+	}
+	b, _ := json.Marshal(event.RequestContext)
+	if !regexp.MustCompile(`"requestId":"` + id + `"`).Match(b) {
+		t.Errorf("expected requestId in the event, got %s", b)
+	}
+
+	rr2 := httptest.NewRecorder()
+	h.ServeHTTP(rr2, httptest.NewRequest("GET", "/", nil))
+	if rr2.Header().Get("x-amzn-RequestId") == id {
+		t.Error("expected a new ID for every request")
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	var seen, logged string
+	h := withRequestID("X-Correlation-Id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = makeProxyHeaders(r.Header)["X-Correlation-Id"]
+		logged = correlationID(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Correlation-Id", "upstream-42")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if seen != "upstream-42" || logged != "upstream-42" || rr.Header().Get("X-Correlation-Id") != "upstream-42" {
+		t.Errorf("expected the client's correlation ID to be kept, got event %q, log %q, response %q", seen, logged, rr.Header().Get("X-Correlation-Id"))
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if id := rr.Header().Get("x-amzn-RequestId"); seen != id || logged != id || rr.Header().Get("X-Correlation-Id") != id {
+		t.Errorf("expected the request ID %q as the correlation ID, got event %q, log %q", id, seen, logged)
+	}
+}
@@ -0,0 +1,54 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMaxRequestSize(t *testing.T) {
+	os.Setenv("MAX_REQUEST_SIZE", "8")
+	defer os.Unsetenv("MAX_REQUEST_SIZE")
+
+	l := LambdaClient{mockLambdaClient{}}
+
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789")))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+	if b := rr.Body.String(); b != `{"message":"Request Too Long"}` {
+		t.Errorf("handler returned unexpected body: got %v", b)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		body          string
+		contentLength int64
+		limit         int64
+		err           error
+	}{
+		{"small", 5, 8, nil},
+		{"exactly8", 8, 8, nil},
+		{"0123456789", 10, 8, errRequestTooLarge},
+		// Chunked requests don't declare a length up front.
+		{"0123456789", -1, 8, errRequestTooLarge},
+		{"0123456789", -1, 0, nil},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader(test.body))
+		req.ContentLength = test.contentLength
+		body, err := readBody(httptest.NewRecorder(), req, test.limit)
+		if err != test.err {
+			t.Errorf("reading %q with limit %v returned error %v want %v", test.body, test.limit, err, test.err)
+			continue
+		}
+		if err == nil && body.String() != test.body {
+			t.Errorf("reading %q returned body %q", test.body, body)
+		}
+	}
+}
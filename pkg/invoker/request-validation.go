@@ -0,0 +1,273 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// What an API Gateway request validator checks for a route imported from
+// OpenAPI: required query string parameters and headers, and the body against
+// its JSON schema.
+type requestValidation struct {
+	query        []string
+	headers      []string
+	bodyRequired bool
+	schema       interface{}
+
+	// The whole spec, for resolving $refs to components.
+	spec map[string]interface{}
+}
+
+func newRequestValidation(spec map[string]interface{}, item map[string]interface{}, operation map[string]interface{}, validator map[string]interface{}) *requestValidation {
+	v := &requestValidation{spec: spec}
+	if validate, _ := validator["validateRequestParameters"].(bool); validate {
+		var parameters []interface{}
+		itemParameters, _ := item["parameters"].([]interface{})
+		operationParameters, _ := operation["parameters"].([]interface{})
+		parameters = append(append(parameters, itemParameters...), operationParameters...)
+		for _, parameter := range parameters {
+			parameter, _ := v.resolve(parameter).(map[string]interface{})
+			if required, _ := parameter["required"].(bool); !required {
+				continue
+			}
+			name, _ := parameter["name"].(string)
+			switch parameter["in"] {
+			case "query":
+				v.query = append(v.query, name)
+			case "header":
+				v.headers = append(v.headers, name)
+			}
+		}
+	}
+	if validate, _ := validator["validateRequestBody"].(bool); validate {
+		body, _ := v.resolve(operation["requestBody"]).(map[string]interface{})
+		v.bodyRequired, _ = body["required"].(bool)
+		content, _ := body["content"].(map[string]interface{})
+		for contentType, media := range content {
+			if strings.Contains(contentType, "json") {
+				media, _ := media.(map[string]interface{})
+				v.schema = media["schema"]
+			}
+		}
+	}
+	if len(v.query) == 0 && len(v.headers) == 0 && !v.bodyRequired && v.schema == nil {
+		return nil
+	}
+	return v
+}
+
+// Check a request, returning the message API Gateway would reject it with
+// and the reason why, or an empty message if it's valid.
+func (v *requestValidation) check(r *http.Request, body []byte) (string, error) {
+	var missing []string
+	query := r.URL.Query()
+	for _, name := range v.query {
+		if query.Get(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range v.headers {
+		if r.Header.Get(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf("Missing required request parameters: [%v]", strings.Join(missing, ", ")), nil
+	}
+
+	if len(body) == 0 {
+		if v.bodyRequired {
+			return "Invalid request body", fmt.Errorf("body is required")
+		}
+		return "", nil
+	}
+	if v.schema == nil {
+		return "", nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "Invalid request body", err
+	}
+	if err := v.validate(v.schema, value, "body"); err != nil {
+		return "Invalid request body", err
+	}
+	return "", nil
+}
+
+// Follow a local $ref such as #/components/schemas/User.
+func (v *requestValidation) resolve(node interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return node
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+		var target interface{} = v.spec
+		for _, part := range strings.Split(ref[2:], "/") {
+			parent, _ := target.(map[string]interface{})
+			target = parent[strings.NewReplacer("~1", "/", "~0", "~").Replace(part)]
+		}
+		node = target
+	}
+	return node
+}
+
+// Check a decoded JSON value against the parts of a JSON schema that API
+// Gateway models commonly use.
+func (v *requestValidation) validate(node interface{}, value interface{}, at string) error {
+	schema, ok := v.resolve(node).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+	}
+
+	if t, ok := schema["type"].(string); ok && !hasJSONType(value, t) {
+		return fmt.Errorf("%v must be %v", at, t)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, value) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%v must be one of %v", at, enum)
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		subschemas, ok := schema[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		var firstErr error
+		for _, subschema := range subschemas {
+			if err := v.validate(subschema, value, at); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			matched++
+		}
+		switch {
+		case key == "allOf" && firstErr != nil:
+			return firstErr
+		case key == "anyOf" && matched == 0:
+			return fmt.Errorf("%v matches none of anyOf: %v", at, firstErr)
+		case key == "oneOf" && matched != 1:
+			return fmt.Errorf("%v must match exactly one of oneOf, matched %v", at, matched)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := value[fmt.Sprint(name)]; !ok {
+				return fmt.Errorf("%v.%v is required", at, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range value {
+			if propertySchema, ok := properties[name]; ok {
+				if err := v.validate(propertySchema, property, at+"."+name); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%v.%v is not allowed", at, name)
+				}
+			case map[string]interface{}:
+				if err := v.validate(additional, property, at+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if min, ok := schemaNumber(schema["minItems"]); ok && float64(len(value)) < min {
+			return fmt.Errorf("%v must have at least %v items", at, min)
+		}
+		if max, ok := schemaNumber(schema["maxItems"]); ok && float64(len(value)) > max {
+			return fmt.Errorf("%v must have at most %v items", at, max)
+		}
+		for i, item := range value {
+			if err := v.validate(schema["items"], item, fmt.Sprintf("%v[%v]", at, i)); err != nil {
+				return err
+			}
+		}
+	case string:
+		length := float64(len([]rune(value)))
+		if min, ok := schemaNumber(schema["minLength"]); ok && length < min {
+			return fmt.Errorf("%v must be at least %v characters", at, min)
+		}
+		if max, ok := schemaNumber(schema["maxLength"]); ok && length > max {
+			return fmt.Errorf("%v must be at most %v characters", at, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+				return fmt.Errorf("%v must match %v", at, pattern)
+			}
+		}
+	case float64:
+		if min, ok := schemaNumber(schema["minimum"]); ok && value < min {
+			return fmt.Errorf("%v must be at least %v", at, min)
+		}
+		if max, ok := schemaNumber(schema["maximum"]); ok && value > max {
+			return fmt.Errorf("%v must be at most %v", at, max)
+		}
+	}
+	return nil
+}
+
+func hasJSONType(value interface{}, t string) bool {
+	switch value := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && value == math.Trunc(value))
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+// Numbers in the spec are ints or floats depending on how they're written.
+func schemaNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func jsonEqual(a interface{}, b interface{}) bool {
+	if n, ok := schemaNumber(a); ok {
+		a = n
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,28 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestMaxResponseSize(t *testing.T) {
+	os.Setenv("MAX_RESPONSE_SIZE", "16")
+	defer os.Unsetenv("MAX_RESPONSE_SIZE")
+
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{
+		Payload: []byte(`{"Body":"this is far too long","StatusCode":200}`),
+	}}}
+
+	rr := httptest.NewRecorder()
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadGateway)
+	}
+	if b := rr.Body.String(); b != `{"message":"Internal server error"}` {
+		t.Errorf("handler returned unexpected body: got %v", b)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux
+// +build linux
+
+package invoker
+
+import (
+	"runtime"
+	"strings"
+)
+
+// syscall doesn't define SO_REUSEPORT for Linux. It's 15 everywhere but MIPS.
+var soReusePort = func() int {
+	if strings.HasPrefix(runtime.GOARCH, "mips") {
+		return 0x200
+	}
+	return 0xf
+}()
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package invoker
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// SO_REUSEPORT isn't available on this platform.
+func reusePort(network string, address string, conn syscall.RawConn) error {
+	return fmt.Errorf("REUSE_PORT isn't supported on %v", runtime.GOOS)
+}
@@ -0,0 +1,49 @@
+package invoker
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT isn't supported on windows")
+	}
+	listenConfig.Control = reusePort
+	defer func() { listenConfig.Control = nil }()
+
+	old, err := openListeners([]listenerSpec{{"tcp", "127.0.0.1:0", false}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := old[0].Addr().String()
+	// A second process would take over the port like this while the first drains.
+	taken, err := openListeners([]listenerSpec{{"tcp", address, false}}, nil)
+	if err != nil {
+		old[0].Close()
+		t.Fatalf("couldn't listen on %v again: %v", address, err)
+	}
+
+	next := &http.Server{Handler: http.HandlerFunc(healthHandler)}
+	go next.Serve(taken[0])
+	defer next.Close()
+
+	// Once the first process stops listening the second answers on its own.
+	old[0].Close()
+	if err := healthcheck("http://" + address); err != nil {
+		t.Errorf("new listener: %v", err)
+	}
+}
+
+func TestListenWithoutReusePort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if _, err := openListeners([]listenerSpec{{"tcp", ln.Addr().String(), false}}, nil); err == nil {
+		t.Error("expected an error listening on a port in use")
+	}
+}
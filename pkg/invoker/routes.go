@@ -0,0 +1,361 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Settings for requests matching a method and path.
+type route struct {
+	Method  string `json:"method" yaml:"method"`
+	Path    string `json:"path" yaml:"path"`
+	Timeout string `json:"timeout" yaml:"timeout"`
+
+	// Where to send matching requests instead of LAMBDA_NAME at AWS_REGION
+	// and LAMBDA_ENDPOINT.
+	Function string `json:"function" yaml:"function"`
+	Region   string `json:"region" yaml:"region"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// A second function to send canaryWeight percent of requests to, as a
+	// canary deployment would.
+	CanaryFunction string  `json:"canaryFunction,omitempty" yaml:"canaryFunction"`
+	CanaryWeight   float64 `json:"canaryWeight,omitempty" yaml:"canaryWeight"`
+
+	// A Step Functions state machine to start instead of invoking a function,
+	// at STEP_FUNCTIONS_ENDPOINT, with the StartSyncExecution (the default)
+	// or StartExecution action. Its input is the request body, or the event a
+	// function would get with stateMachineInput event.
+	StateMachineArn    string `json:"stateMachineArn,omitempty" yaml:"stateMachineArn"`
+	StateMachineAction string `json:"stateMachineAction,omitempty" yaml:"stateMachineAction"`
+	StateMachineInput  string `json:"stateMachineInput,omitempty" yaml:"stateMachineInput"`
+
+	// A function to mirror requests to as well, instead of SHADOW_FUNCTION.
+	ShadowFunction string `json:"shadowFunction,omitempty" yaml:"shadowFunction"`
+
+	// The payload format of the function's events and responses, 1.0 or 2.0,
+	// instead of PAYLOAD_FORMAT_VERSION.
+	PayloadFormatVersion string `json:"payloadFormatVersion,omitempty" yaml:"payloadFormatVersion"`
+
+	// How compressed request bodies reach the function, passthrough,
+	// decompress or base64, instead of REQUEST_ENCODING.
+	RequestEncoding string `json:"requestEncoding,omitempty" yaml:"requestEncoding"`
+
+	// Whether the function streams its response, as with RESPONSE_STREAMING.
+	Streaming bool `json:"streaming,omitempty" yaml:"streaming"`
+
+	// Changes to the request's headers before the event is built, after
+	// any REQUEST_HEADERS.
+	RequestHeaders *headerRules `json:"requestHeaders,omitempty" yaml:"requestHeaders"`
+	// Changes to the function's response headers, after any RESPONSE_HEADERS.
+	ResponseHeaders *headerRules `json:"responseHeaders,omitempty" yaml:"responseHeaders"`
+
+	pattern    *regexp.Regexp
+	timeout    time.Duration
+	validation *requestValidation
+}
+
+type routeTable []*route
+
+// Routes added through the admin API followed by those loaded from ROUTE,
+// CONFIG_FILE and the other route sources, and then those discovered from
+// function tags. Swapped as a whole on reload or change so requests always
+// see a complete table.
+var routes atomic.Value
+
+var (
+	routesMu         sync.Mutex
+	configuredRoutes routeTable
+	discoveredRoutes routeTable
+	runtimeRoutes    []adminRoute
+	nextRouteID      = 1
+)
+
+func currentRoutes() routeTable {
+	table, _ := routes.Load().(routeTable)
+	return table
+}
+
+// Replace the configured routes, keeping those added at runtime.
+func setRoutes(table routeTable) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	configuredRoutes = table
+	publishRoutes()
+}
+
+// Replace the routes discovered from function tags.
+func setDiscoveredRoutes(table routeTable) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	discoveredRoutes = table
+	publishRoutes()
+}
+
+// Swap in the combined table. Callers hold routesMu.
+func publishRoutes() {
+	table := make(routeTable, 0, len(runtimeRoutes)+len(configuredRoutes)+len(discoveredRoutes))
+	for _, rt := range runtimeRoutes {
+		table = append(table, rt.route)
+	}
+	table = append(table, configuredRoutes...)
+	routes.Store(append(table, discoveredRoutes...))
+}
+
+var pathParameter = regexp.MustCompile(`^\{(\w+)(\+?)\}$`)
+
+// Turn an API Gateway style path such as /users/{id} or /files/{proxy+} into
+// a regex capturing each parameter by name. This is done once when routes are
+// loaded so requests only pay for matching. With ignoreTrailingSlash a
+// trailing slash on either the path or the request makes no difference, and
+// with caseInsensitive neither does case.
+func compilePathPattern(path string, ignoreTrailingSlash bool, caseInsensitive bool) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid route %v: path must start with /", path)
+	}
+	trimmed := path
+	if ignoreTrailingSlash && len(path) > 1 {
+		trimmed = strings.TrimSuffix(path, "/")
+	}
+	segments := strings.Split(trimmed, "/")
+	for i, segment := range segments {
+		if param := pathParameter.FindStringSubmatch(segment); param != nil {
+			if param[2] == "" {
+				segments[i] = fmt.Sprintf("(?P<%v>[^/]+)", param[1])
+				continue
+			}
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("invalid route %v: {%v+} must be the last segment", path, param[1])
+			}
+			greedy := ".+"
+			if ignoreTrailingSlash {
+				greedy = ".+?"
+			}
+			segments[i] = fmt.Sprintf("(?P<%v>%v)", param[1], greedy)
+			continue
+		}
+		if strings.ContainsAny(segment, "{}") {
+			return nil, fmt.Errorf("invalid route %v: bad parameter in segment %q", path, segment)
+		}
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	expr := "^" + strings.Join(segments, "/")
+	if ignoreTrailingSlash && trimmed != "/" {
+		expr += "/?"
+	}
+	if caseInsensitive {
+		expr = "(?i)" + expr
+	}
+	pattern, err := regexp.Compile(expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid route %v: %v", path, err)
+	}
+	return pattern, nil
+}
+
+// Validate a route and prepare it for matching.
+func (rt *route) compile() error {
+	if rt.Path == "" {
+		return fmt.Errorf("route is missing a path")
+	}
+	ignoreTrailingSlash, err := getConfigBool("ROUTE_IGNORE_TRAILING_SLASH")
+	if err != nil {
+		return err
+	}
+	caseInsensitive, err := getConfigBool("ROUTE_CASE_INSENSITIVE")
+	if err != nil {
+		return err
+	}
+	if rt.pattern, err = compilePathPattern(rt.Path, ignoreTrailingSlash, caseInsensitive); err != nil {
+		return err
+	}
+	if rt.Timeout != "" {
+		if rt.timeout, err = time.ParseDuration(rt.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout for route %v: %v", rt.Path, err)
+		}
+	}
+	if rt.CanaryWeight < 0 || rt.CanaryWeight > 100 {
+		return fmt.Errorf("invalid canaryWeight for route %v: must be between 0 and 100", rt.Path)
+	}
+	if rt.CanaryWeight > 0 && rt.CanaryFunction == "" {
+		return fmt.Errorf("invalid route %v: canaryWeight needs a canaryFunction", rt.Path)
+	}
+	if rt.PayloadFormatVersion != "" {
+		if err := checkPayloadFormatVersion(rt.PayloadFormatVersion); err != nil {
+			return fmt.Errorf("invalid payloadFormatVersion for route %v: %v", rt.Path, err)
+		}
+	}
+	if err := checkRequestEncoding(rt.RequestEncoding); err != nil {
+		return fmt.Errorf("invalid requestEncoding for route %v: %v", rt.Path, err)
+	}
+	if err := rt.checkStateMachine(); err != nil {
+		return fmt.Errorf("invalid route %v: %v", rt.Path, err)
+	}
+	if err := rt.RequestHeaders.check(); err != nil {
+		return fmt.Errorf("invalid requestHeaders for route %v: %v", rt.Path, err)
+	}
+	if err := rt.ResponseHeaders.check(); err != nil {
+		return fmt.Errorf("invalid responseHeaders for route %v: %v", rt.Path, err)
+	}
+	return nil
+}
+
+// Whether to send this request to the route's canaryFunction, which gets
+// canaryWeight percent of them.
+func (rt *route) pickCanary() bool {
+	if rt == nil || rt.CanaryFunction == "" || rt.CanaryWeight <= 0 {
+		return false
+	}
+	return rt.CanaryWeight >= 100 || rand.Float64()*100 < rt.CanaryWeight
+}
+
+// Read routes from a JSON file such as:
+//
+//	[
+//	  {"method": "GET", "path": "/reports", "timeout": "2m"},
+//	  {"path": "/users/{id}", "timeout": "2s"},
+//	  {"path": "/orders", "function": "orders", "region": "us-west-2"}
+//	]
+func loadRoutes(file string) (routeTable, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var table routeTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("invalid routes file %v: %v", file, err)
+	}
+	for _, rt := range table {
+		if err := rt.compile(); err != nil {
+			return nil, fmt.Errorf("invalid routes file %v: %v", file, err)
+		}
+	}
+	return table, nil
+}
+
+// Parse routes written as ROUTES, such as
+// "GET /users=users-fn;POST /orders/:id=orders-fn". Each route is an optional
+// method, a path and, after =, an optional function. :name segments can stand
+// in for {name}, which is awkward to quote in some shells.
+func parseRoutes(spec string) (routeTable, error) {
+	var table routeTable
+	for _, entry := range strings.Split(spec, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		rt := &route{}
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			entry, rt.Function = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		parts := strings.Fields(entry)
+		switch len(parts) {
+		case 1:
+			rt.Path = parts[0]
+		case 2:
+			rt.Method, rt.Path = strings.ToUpper(parts[0]), parts[1]
+		default:
+			return nil, fmt.Errorf("invalid ROUTES entry %q: must be [METHOD] PATH[=FUNCTION]", entry)
+		}
+		if rt.Method == "ANY" {
+			rt.Method = ""
+		}
+		rt.Path = colonParameter.ReplaceAllString(rt.Path, "/{$1$2}")
+		if err := rt.compile(); err != nil {
+			return nil, fmt.Errorf("invalid ROUTES: %v", err)
+		}
+		table = append(table, rt)
+	}
+	return table, nil
+}
+
+// Build the route table from ROUTE, a single path pattern for the function,
+// followed by ROUTES, the routes in CONFIG_FILE, ROUTES_FILE, OPENAPI_FILE,
+// SAM_TEMPLATE, SERVERLESS_FILE and then CDK_OUT.
+func loadRouteConfig() (routeTable, error) {
+	table, err := loadRoutes(getConfig("ROUTES_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	openAPIRoutes, err := loadOpenAPIRoutes(getConfig("OPENAPI_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	samRoutes, err := loadSAMRoutes(getConfig("SAM_TEMPLATE"))
+	if err != nil {
+		return nil, err
+	}
+	serverlessRoutes, err := loadServerlessRoutes(getConfig("SERVERLESS_FILE"), getConfig("SERVERLESS_STAGE"))
+	if err != nil {
+		return nil, err
+	}
+	cdkRoutes, err := loadCDKRoutes(getConfig("CDK_OUT"))
+	if err != nil {
+		return nil, err
+	}
+	for _, routes := range []routeTable{openAPIRoutes, samRoutes, serverlessRoutes, cdkRoutes} {
+		table = append(table, routes...)
+	}
+	if cfg := currentConfigFile(); cfg != nil {
+		// Compile them again, as the file may have set ROUTE_* options.
+		for _, rt := range cfg.Routes {
+			if err := rt.compile(); err != nil {
+				return nil, err
+			}
+		}
+		table = append(append(routeTable{}, cfg.Routes...), table...)
+	}
+	envRoutes, err := parseRoutes(getConfig("ROUTES"))
+	if err != nil {
+		return nil, err
+	}
+	table = append(envRoutes, table...)
+	if pattern := getConfig("ROUTE"); pattern != "" {
+		rt := &route{Path: pattern}
+		if err := rt.compile(); err != nil {
+			return nil, err
+		}
+		table = append(routeTable{rt}, table...)
+	}
+	return table, nil
+}
+
+// Find the first route for this request along with the path parameters it
+// captured. Routes without a method match any method, and HEAD requests
+// without a route of their own use the GET route.
+func (t routeTable) match(method string, path string) (*route, map[string]string) {
+	for _, rt := range t {
+		if rt.Method != "" && rt.Method != method {
+			continue
+		}
+		values := rt.pattern.FindStringSubmatch(path)
+		if values == nil {
+			continue
+		}
+		var params map[string]string
+		for i, name := range rt.pattern.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = values[i]
+		}
+		return rt, params
+	}
+	if method == http.MethodHead {
+		return t.match(http.MethodGet, path)
+	}
+	return nil, nil
+}
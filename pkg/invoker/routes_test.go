@@ -0,0 +1,230 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestLoadRoutes(t *testing.T) {
+	file := writeConfigFile(t, "routes*.json", `[
+		{"method": "GET", "path": "/reports", "timeout": "2m"},
+		{"path": "/health", "timeout": "2s"}
+	]`)
+
+	table, err := loadRoutes(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rt, _ := table.match("GET", "/reports"); rt == nil || rt.timeout != 2*time.Minute {
+		t.Errorf("GET /reports matched unexpected route %+v", rt)
+	}
+	if rt, _ := table.match("POST", "/reports"); rt != nil {
+		t.Errorf("POST /reports matched unexpected route %+v", rt)
+	}
+	if rt, _ := table.match("DELETE", "/health"); rt == nil || rt.timeout != 2*time.Second {
+		t.Errorf("DELETE /health matched unexpected route %+v", rt)
+	}
+}
+
+func TestLoadRoutesInvalidTimeout(t *testing.T) {
+	file := writeConfigFile(t, "routes*.json", `[{"path": "/reports", "timeout": "soon"}]`)
+
+	if _, err := loadRoutes(file); err == nil {
+		t.Error("expected an error for an invalid route timeout")
+	}
+}
+
+func TestRouteTimeoutOverridesDefault(t *testing.T) {
+	rt := &route{Path: "/slow", Timeout: "10ms"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+
+	rr := httptest.NewRecorder()
+	l := LambdaClient{slowLambdaClient{}}
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/slow", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestPathParameters(t *testing.T) {
+	var table routeTable
+	for _, path := range []string{"/users/{id}", "/files/{proxy+}", "/v1.0/status"} {
+		rt := &route{Path: path}
+		if err := rt.compile(); err != nil {
+			t.Fatal(err)
+		}
+		table = append(table, rt)
+	}
+
+	tests := []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/users/123", "/users/{id}", map[string]string{"id": "123"}},
+		{"/users/123/orders", "", nil},
+		{"/files/a/b/c.txt", "/files/{proxy+}", map[string]string{"proxy": "a/b/c.txt"}},
+		{"/v1.0/status", "/v1.0/status", nil},
+		{"/v1x0/status", "", nil},
+	}
+
+	for _, test := range tests {
+		rt, params := table.match("GET", test.path)
+		if test.route == "" {
+			if rt != nil {
+				t.Errorf("%v matched unexpected route %v", test.path, rt.Path)
+			}
+			continue
+		}
+		if rt == nil || rt.Path != test.route {
+			t.Errorf("%v did not match route %v", test.path, test.route)
+			continue
+		}
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%v captured %v want %v", test.path, params, test.params)
+		}
+	}
+}
+
+func TestInvalidPathPatterns(t *testing.T) {
+	for _, path := range []string{"users", "/users/{id", "/files/{proxy+}/more", "/users/{}"} {
+		if _, err := compilePathPattern(path, false, false); err == nil {
+			t.Errorf("expected an error for route %v", path)
+		}
+	}
+}
+
+func TestRouteTarget(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	rt := &route{Path: "/orders", Function: "orders", Region: "us-west-2", Endpoint: "http://localstack:4566"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+
+	m := &recordingLambdaClient{}
+	l := LambdaClient{m}
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+	l.invokeLambda(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	if len(m.Inputs) != 2 || *m.Inputs[0].FunctionName != "orders" || *m.Inputs[1].FunctionName != "MyFunction" {
+		t.Fatalf("unexpected invocations %v", m.Inputs)
+	}
+
+	c, err := lambdaClientFor(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.LambdaAPI.(*lambda.Lambda)
+	if client.Endpoint != "http://localstack:4566" || *client.Config.Region != "us-west-2" {
+		t.Errorf("unexpected client for route: %v in %v", client.Endpoint, *client.Config.Region)
+	}
+	if again, _ := lambdaClientFor(rt); again != c {
+		t.Error("expected the route's client to be reused")
+	}
+	if global, _ := getLambdaClient(); global == c {
+		t.Error("expected routes with their own endpoint to get their own client")
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	table, err := parseRoutes("GET /users=users-fn; POST /orders/:id=orders-fn;any /files/:path+ ;/health;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 4 {
+		t.Fatalf("expected 4 routes, got %v", len(table))
+	}
+	for _, c := range []struct {
+		method   string
+		path     string
+		function string
+		params   map[string]string
+	}{
+		{"GET", "/users", "users-fn", nil},
+		{"POST", "/orders/42", "orders-fn", map[string]string{"id": "42"}},
+		{"PUT", "/files/a/b", "", map[string]string{"path": "a/b"}},
+		{"DELETE", "/health", "", nil},
+	} {
+		rt, params := table.match(c.method, c.path)
+		if rt == nil || rt.Function != c.function || !reflect.DeepEqual(params, c.params) {
+			t.Errorf("%v %v: unexpected route %+v %v", c.method, c.path, rt, params)
+		}
+	}
+	if rt, _ := table.match("POST", "/users"); rt != nil {
+		t.Errorf("expected GET only, got %+v", rt)
+	}
+
+	for _, spec := range []string{"GET users=fn", "GET /a /b=fn"} {
+		if _, err := parseRoutes(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+
+	os.Setenv("ROUTES", "GET /users=users-fn")
+	defer os.Unsetenv("ROUTES")
+	os.Setenv("ROUTE", "/users/{id}")
+	defer os.Unsetenv("ROUTE")
+	table, err = loadRouteConfig()
+	if err != nil || len(table) != 2 || table[0].Path != "/users/{id}" || table[1].Function != "users-fn" {
+		t.Errorf("expected ROUTE then ROUTES, got %+v %v", table, err)
+	}
+}
+
+func TestRouteMatchingOptions(t *testing.T) {
+	compile := func(path string) *route {
+		rt := &route{Path: path}
+		if err := rt.compile(); err != nil {
+			t.Fatal(err)
+		}
+		return rt
+	}
+	table := routeTable{compile("/users/{id}"), compile("/orders/"), compile("/files/{proxy+}"), compile("/")}
+	if rt, _ := table.match("GET", "/users/42/"); rt != nil {
+		t.Errorf("expected no match for a trailing slash by default, got %+v", rt)
+	}
+
+	os.Setenv("ROUTE_IGNORE_TRAILING_SLASH", "true")
+	defer os.Unsetenv("ROUTE_IGNORE_TRAILING_SLASH")
+	os.Setenv("ROUTE_CASE_INSENSITIVE", "true")
+	defer os.Unsetenv("ROUTE_CASE_INSENSITIVE")
+	table = routeTable{compile("/users/{id}"), compile("/orders/"), compile("/files/{proxy+}"), compile("/")}
+	for _, c := range []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/users/42/", "/users/{id}", map[string]string{"id": "42"}},
+		{"/Users/AbC", "/users/{id}", map[string]string{"id": "AbC"}},
+		{"/orders", "/orders/", nil},
+		{"/orders/", "/orders/", nil},
+		{"/files/a/b/", "/files/{proxy+}", map[string]string{"proxy": "a/b"}},
+		{"/", "/", nil},
+	} {
+		rt, params := table.match("GET", c.path)
+		if rt == nil || rt.Path != c.route || !reflect.DeepEqual(params, c.params) {
+			t.Errorf("%v: unexpected match %+v %v", c.path, rt, params)
+		}
+	}
+	if rt, _ := table.match("GET", "//"); rt != nil {
+		t.Errorf("unexpected match for // %+v", rt)
+	}
+
+	os.Setenv("ROUTE_CASE_INSENSITIVE", "maybe")
+	if err := (&route{Path: "/users"}).compile(); err == nil {
+		t.Error("expected an error for an invalid ROUTE_CASE_INSENSITIVE")
+	}
+}
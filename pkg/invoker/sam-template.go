@@ -0,0 +1,79 @@
+package invoker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Read routes from the Api and HttpApi events of the functions in an AWS SAM
+// template, sending each to the function's logical ID, which is the name
+// `sam local start-lambda` gives it. CloudFormation tags such as !Ref are
+// read as plain values.
+func loadSAMRoutes(file string) (routeTable, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var template struct {
+		Resources map[string]struct {
+			Type       string `yaml:"Type"`
+			Properties struct {
+				Events map[string]struct {
+					Type       string `yaml:"Type"`
+					Properties struct {
+						Path                 string `yaml:"Path"`
+						Method               string `yaml:"Method"`
+						TimeoutInMillis      int    `yaml:"TimeoutInMillis"`
+						PayloadFormatVersion string `yaml:"PayloadFormatVersion"`
+					} `yaml:"Properties"`
+				} `yaml:"Events"`
+			} `yaml:"Properties"`
+		} `yaml:"Resources"`
+	}
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("invalid SAM template %v: %v", file, err)
+	}
+
+	var table routeTable
+	for name, resource := range template.Resources {
+		if resource.Type != "AWS::Serverless::Function" {
+			continue
+		}
+		for _, event := range resource.Properties.Events {
+			if event.Type != "Api" && event.Type != "HttpApi" {
+				continue
+			}
+			rt := &route{Path: event.Properties.Path, Method: strings.ToUpper(event.Properties.Method), Function: name}
+			// An HttpApi event with no path is the API's $default route, and
+			// its functions get events in payload format 2.0 unless told otherwise.
+			if event.Type == "HttpApi" {
+				if rt.Path == "" {
+					rt.Path = "/{proxy+}"
+				}
+				rt.PayloadFormatVersion = event.Properties.PayloadFormatVersion
+				if rt.PayloadFormatVersion == "" {
+					rt.PayloadFormatVersion = "2.0"
+				}
+			}
+			if rt.Method == "ANY" {
+				rt.Method = ""
+			}
+			if event.Properties.TimeoutInMillis > 0 {
+				rt.Timeout = (time.Duration(event.Properties.TimeoutInMillis) * time.Millisecond).String()
+			}
+			if err := rt.compile(); err != nil {
+				return nil, fmt.Errorf("invalid SAM template %v: function %v: %v", file, name, err)
+			}
+			table = append(table, rt)
+		}
+	}
+	sortRoutes(table)
+	return table, nil
+}
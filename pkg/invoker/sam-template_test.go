@@ -0,0 +1,94 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testSAMTemplate = `
+AWSTemplateFormatVersion: '2010-09-09'
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  UsersFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Handler: bootstrap
+      Events:
+        GetUser:
+          Type: Api
+          Properties:
+            Path: /users/{id}
+            Method: get
+        AnyUsers:
+          Type: Api
+          Properties:
+            Path: /users
+            Method: ANY
+            RestApiId: !Ref Api
+        Queue:
+          Type: SQS
+          Properties:
+            Queue: !GetAtt Queue.Arn
+  DefaultFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Default:
+          Type: HttpApi
+          Properties:
+            TimeoutInMillis: 5000
+  Queue:
+    Type: AWS::SQS::Queue
+`
+
+func TestLoadSAMRoutes(t *testing.T) {
+	table, err := loadSAMRoutes(writeConfigFile(t, "template*.yaml", testSAMTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 3 {
+		t.Fatalf("expected 3 routes, got %v", len(table))
+	}
+	for _, c := range []struct {
+		method   string
+		path     string
+		function string
+		timeout  string
+		version  string
+	}{
+		{"GET", "/users/42", "UsersFunction", "", ""},
+		{"DELETE", "/users", "UsersFunction", "", ""},
+		{"POST", "/users/42", "DefaultFunction", "5s", "2.0"},
+		{"GET", "/anything/else", "DefaultFunction", "5s", "2.0"},
+	} {
+		rt, _ := table.match(c.method, c.path)
+		if rt == nil || rt.Function != c.function || rt.Timeout != c.timeout || rt.PayloadFormatVersion != c.version {
+			t.Errorf("%v %v: unexpected route %+v", c.method, c.path, rt)
+		}
+	}
+
+	if _, err := loadSAMRoutes(writeConfigFile(t, "template*.yaml", "Resources:\n  F:\n    Type: AWS::Serverless::Function\n    Properties:\n      Events:\n        E:\n          Type: Api\n          Properties:\n            Path: users\n")); err == nil {
+		t.Error("expected an error for an invalid path")
+	}
+}
+
+func TestRoutesWithoutLambdaName(t *testing.T) {
+	os.Unsetenv("LAMBDA_NAME")
+	rt := &route{Path: "/users", Function: "UsersFunction"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+
+	if err := validateConfig(); err != nil {
+		t.Errorf("expected LAMBDA_NAME to be optional, got %v", err)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/other", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected a 404 for a request with no route, got %v", rr.Code)
+	}
+}
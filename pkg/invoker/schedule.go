@@ -0,0 +1,288 @@
+package invoker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// A function to invoke on an EventBridge schedule expression, such as
+// "cron(0 2 * * ? *)" or "rate(5 minutes)", from CONFIG_FILE's schedules.
+type schedule struct {
+	Name       string `json:"name" yaml:"name"`
+	Function   string `json:"function" yaml:"function"`
+	Expression string `json:"expression" yaml:"expression"`
+
+	rate time.Duration
+	cron *cronExpression
+}
+
+// The fields of a cron expression, with the values each allows. A nil
+// day of month or day of week is the ? that stands for the other one.
+type cronExpression struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek, years map[int]bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{0, 59, nil},
+	{0, 23, nil},
+	{1, 31, nil},
+	{1, 12, []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{1, 7, []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+	{1970, 2199, nil},
+}
+
+// Validate a schedule and parse its expression.
+func (s *schedule) compile() error {
+	if s.Function == "" {
+		return fmt.Errorf("schedule %v is missing a function", s.Expression)
+	}
+	if s.Name == "" {
+		s.Name = s.Function
+	}
+	expression := strings.TrimSpace(s.Expression)
+	switch {
+	case strings.HasPrefix(expression, "rate(") && strings.HasSuffix(expression, ")"):
+		parts := strings.Fields(expression[len("rate(") : len(expression)-1])
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid schedule %v: rate must be a value and a unit", s.Expression)
+		}
+		value, err := strconv.Atoi(parts[0])
+		if err != nil || value < 1 {
+			return fmt.Errorf("invalid schedule %v: rate must be a positive whole number", s.Expression)
+		}
+		units := map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+		unit, ok := units[strings.TrimSuffix(parts[1], "s")]
+		if !ok {
+			return fmt.Errorf("invalid schedule %v: unit must be minutes, hours or days", s.Expression)
+		}
+		s.rate = time.Duration(value) * unit
+	case strings.HasPrefix(expression, "cron(") && strings.HasSuffix(expression, ")"):
+		cron, err := parseCron(expression[len("cron(") : len(expression)-1])
+		if err != nil {
+			return fmt.Errorf("invalid schedule %v: %v", s.Expression, err)
+		}
+		s.cron = cron
+	default:
+		return fmt.Errorf("invalid schedule %q: must be cron(...) or rate(...)", s.Expression)
+	}
+	return nil
+}
+
+// Parse the six fields of an EventBridge cron expression: minutes, hours,
+// day of month, month, day of week and year. Lists, ranges, steps and names
+// are supported, but not L, W or #.
+func parseCron(spec string) (*cronExpression, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron needs %v fields", len(cronFields))
+	}
+	if (parts[2] == "?") == (parts[4] == "?") {
+		return nil, fmt.Errorf("one of day of month and day of week must be ?")
+	}
+	values := make([]map[int]bool, len(parts))
+	for i, part := range parts {
+		if part == "?" && (i == 2 || i == 4) {
+			continue
+		}
+		var err error
+		if values[i], err = cronFields[i].parse(part); err != nil {
+			return nil, err
+		}
+	}
+	return &cronExpression{values[0], values[1], values[2], values[3], values[4], values[5]}, nil
+}
+
+// The values a field such as "*", "0/15", "1,15" or "MON-FRI" allows.
+func (f cronField) parse(part string) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			item = item[:i]
+		}
+		low, high := f.min, f.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return nil, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			if low, err = f.value(item); err != nil {
+				return nil, err
+			}
+			// A step from a single value, such as 0/15, runs to the end.
+			if step == 1 {
+				high = low
+			}
+		}
+		if low > high {
+			return nil, fmt.Errorf("invalid range in %q", part)
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// A single value, which may be a name such as JAN or MON.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q: must be from %v to %v", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// The first minute after t that the expression matches, in UTC as
+// EventBridge uses, or the zero time if there isn't one.
+func (c *cronExpression) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	for t.Year() <= cronFields[5].max {
+		switch {
+		case !c.years[t.Year()]:
+			t = time.Date(t.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !c.hours[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronExpression) matchesDay(t time.Time) bool {
+	if c.daysOfMonth != nil {
+		return c.daysOfMonth[t.Day()]
+	}
+	return c.daysOfWeek[int(t.Weekday())+1]
+}
+
+// When the schedule should next run after t.
+func (s *schedule) next(t time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.next(t)
+	}
+	return t.Add(s.rate)
+}
+
+// The Scheduled Event EventBridge sends to a rule's targets.
+func scheduledEvent(s *schedule, at time.Time) []byte {
+	region := getConfig("AWS_REGION")
+	event, _ := json.Marshal(struct {
+		Version    string          `json:"version"`
+		ID         string          `json:"id"`
+		DetailType string          `json:"detail-type"`
+		Source     string          `json:"source"`
+		Account    string          `json:"account"`
+		Time       string          `json:"time"`
+		Region     string          `json:"region"`
+		Resources  []string        `json:"resources"`
+		Detail     json.RawMessage `json:"detail"`
+	}{
+		Version:    "0",
+		ID:         newRequestID(),
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		Account:    "123456789012",
+		Time:       at.UTC().Format(time.RFC3339),
+		Region:     region,
+		Resources:  []string{fmt.Sprintf("arn:aws:events:%v:123456789012:rule/%v", region, s.Name)},
+		Detail:     json.RawMessage("{}"),
+	})
+	return event
+}
+
+// Invoke the schedule's function with a Scheduled Event for at.
+func (c *LambdaClient) runSchedule(ctx context.Context, s *schedule, at time.Time) {
+	fields := logFields{"schedule": s.Name, "function": s.Function}
+	start := time.Now()
+	result, err := c.InvokeWithContext(ctx, &lambda.InvokeInput{FunctionName: aws.String(s.Function), Payload: scheduledEvent(s, at)})
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		if ctx.Err() == nil {
+			fields["error"] = err
+			logError("Scheduled invocation failed", fields)
+		}
+		return
+	}
+	if result.FunctionError != nil {
+		fields["error"] = *result.FunctionError
+		logWarn("Scheduled function returned an error", fields)
+		return
+	}
+	logInfo("Invoked scheduled function", fields)
+}
+
+// Invoke the functions in CONFIG_FILE's schedules when they're due, until ctx
+// is done. The schedules are read again on each pass so reloads take effect.
+func runScheduler(ctx context.Context) {
+	due := make(map[*schedule]time.Time)
+	for {
+		now := time.Now()
+		wake := now.Add(time.Minute)
+		var schedules []*schedule
+		if cfg := currentConfigFile(); cfg != nil {
+			schedules = cfg.Schedules
+		}
+		next := make(map[*schedule]time.Time, len(schedules))
+		for _, s := range schedules {
+			at, ok := due[s]
+			if !ok {
+				at = s.next(now)
+			}
+			if !at.IsZero() && !at.After(now) {
+				if c, err := getLambdaClient(); err != nil {
+					logError("Scheduled invocation failed", logFields{"schedule": s.Name, "function": s.Function, "error": err})
+				} else {
+					go c.runSchedule(ctx, s, at)
+				}
+				at = s.next(now)
+			}
+			next[s] = at
+			if !at.IsZero() && at.Before(wake) {
+				wake = at
+			}
+		}
+		due = next
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wake.Sub(now)):
+		}
+	}
+}
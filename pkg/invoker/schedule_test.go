@@ -0,0 +1,102 @@
+package invoker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+func TestScheduleNext(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 34, 56, 0, time.UTC) // A Thursday.
+	for _, c := range []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"rate(5 minutes)", now.Add(5 * time.Minute)},
+		{"rate(1 day)", now.Add(24 * time.Hour)},
+		{"cron(0 2 * * ? *)", time.Date(2026, 10, 16, 2, 0, 0, 0, time.UTC)},
+		{"cron(0/15 * * * ? *)", time.Date(2026, 10, 15, 12, 45, 0, 0, time.UTC)},
+		{"cron(0 9 ? * MON-FRI *)", time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)},
+		{"cron(30 8 ? * 2 *)", time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)},
+		{"cron(0 0 1 JAN,JUL ? 2027-2030)", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"cron(0 0 31 2 ? *)", time.Time{}},
+	} {
+		s := &schedule{Function: "report", Expression: c.expression}
+		if err := s.compile(); err != nil {
+			t.Errorf("%v: %v", c.expression, err)
+			continue
+		}
+		if next := s.next(now); !next.Equal(c.expected) {
+			t.Errorf("%v: got %v want %v", c.expression, next, c.expected)
+		}
+	}
+
+	for _, expression := range []string{"", "every 5 minutes", "rate(0 minutes)", "rate(5 weeks)", "cron(0 2 * * * *)", "cron(0 2 ? * ? *)", "cron(0 2 * *)", "cron(0 25 * * ? *)", "cron(0 2 L * ? *)"} {
+		s := &schedule{Function: "report", Expression: expression}
+		if err := s.compile(); err == nil {
+			t.Errorf("%q: expected an error", expression)
+		}
+	}
+}
+
+type payloadLambdaClient struct {
+	lambdaiface.LambdaAPI
+	input *lambda.InvokeInput
+}
+
+func (m payloadLambdaClient) InvokeWithContext(_ aws.Context, in *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	*m.input = *in
+	return &lambda.InvokeOutput{Payload: []byte(`null`)}, nil
+}
+
+func TestRunSchedule(t *testing.T) {
+	file := writeConfigFile(t, "config*.yaml", `
+schedules:
+  - name: nightly
+    function: report
+    expression: cron(0 2 * * ? *)
+`)
+	cfg, err := readConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Schedules) != 1 || cfg.Schedules[0].cron == nil {
+		t.Fatalf("unexpected schedules %+v", cfg.Schedules)
+	}
+
+	var input lambda.InvokeInput
+	at := time.Date(2026, 10, 16, 2, 0, 0, 0, time.UTC)
+	c := LambdaClient{payloadLambdaClient{input: &input}}
+	c.runSchedule(context.Background(), cfg.Schedules[0], at)
+	if aws.StringValue(input.FunctionName) != "report" {
+		t.Errorf("invoked %v", aws.StringValue(input.FunctionName))
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(input.Payload, &event); err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]interface{}{
+		"detail-type": "Scheduled Event",
+		"source":      "aws.events",
+		"time":        "2026-10-16T02:00:00Z",
+		"region":      "us-east-1",
+	} {
+		if event[key] != expected {
+			t.Errorf("unexpected %v %v", key, event[key])
+		}
+	}
+	if resources, _ := event["resources"].([]interface{}); len(resources) != 1 || resources[0] != "arn:aws:events:us-east-1:123456789012:rule/nightly" {
+		t.Errorf("unexpected resources %v", event["resources"])
+	}
+
+	bad := writeConfigFile(t, "config*.yaml", "schedules:\n  - expression: rate(1 hour)\n")
+	if _, err := readConfigFile(bad); err == nil {
+		t.Error("expected an error for a schedule without a function")
+	}
+}
@@ -0,0 +1,70 @@
+package invoker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Serve on every listener until a signal arrives on stop, then stop accepting
+// connections and give in-flight requests up to drain to finish. A drain of
+// zero waits for them indefinitely.
+func serve(srv *http.Server, listeners []net.Listener, drain time.Duration, stop <-chan os.Signal) error {
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		logInfo("Listening", logFields{"address": ln.Addr().String()})
+		go func(ln net.Listener) {
+			errs <- srv.Serve(ln)
+		}(ln)
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		logInfo("Draining connections", logFields{"signal": sig.String()})
+		sdNotify("STOPPING=1")
+	}
+
+	ctx := context.Background()
+	if drain > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, drain)
+		defer cancel()
+	}
+	return srv.Shutdown(ctx)
+}
+
+// Address to listen on. An empty host listens on every interface, and IPv6
+// literals can be given with or without brackets.
+func listenAddress(host string, port string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, port)
+}
+
+// Address a local client such as the healthcheck subcommand should use to
+// reach a proxy listening on host.
+func localAddress(host string, port string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if ip != nil && ip.To4() == nil {
+			host = "::1"
+		} else {
+			host = "127.0.0.1"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Also accept HTTP/2 without TLS (h2c) on plain listeners, for gRPC-web and
+// other clients that expect to multiplex requests. HTTPS listeners negotiate
+// HTTP/2 regardless.
+func allowH2C(handler http.Handler) http.Handler {
+	return h2c.NewHandler(handler, &http2.Server{})
+}
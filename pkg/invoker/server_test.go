@@ -0,0 +1,83 @@
+package invoker
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(srv, []net.Listener{ln}, time.Second, stop)
+	}()
+
+	inFlight := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Error(err)
+		}
+		inFlight <- resp
+	}()
+	<-started
+
+	stop <- syscall.SIGTERM
+	time.Sleep(50 * time.Millisecond)
+
+	// New connections are refused while draining.
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("expected new connections to be refused during shutdown")
+	}
+
+	// The in-flight request still completes.
+	close(release)
+	if resp := <-inFlight; resp == nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("in-flight request did not complete: %v", resp)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		host   string
+		listen string
+		local  string
+	}{
+		{"", ":8080", "127.0.0.1:8080"},
+		{"0.0.0.0", "0.0.0.0:8080", "127.0.0.1:8080"},
+		{"127.0.0.1", "127.0.0.1:8080", "127.0.0.1:8080"},
+		{"::", "[::]:8080", "[::1]:8080"},
+		{"::1", "[::1]:8080", "[::1]:8080"},
+		{"[::1]", "[::1]:8080", "[::1]:8080"},
+		{"172.18.0.2", "172.18.0.2:8080", "172.18.0.2:8080"},
+	}
+
+	for _, test := range tests {
+		if addr := listenAddress(test.host, "8080"); addr != test.listen {
+			t.Errorf("listen address for %q: got %v want %v", test.host, addr, test.listen)
+		}
+		if addr := localAddress(test.host, "8080"); addr != test.local {
+			t.Errorf("local address for %q: got %v want %v", test.host, addr, test.local)
+		}
+	}
+}
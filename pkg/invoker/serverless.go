@@ -0,0 +1,124 @@
+package invoker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The variables that usually make up function names in serverless.yml.
+var serverlessVariable = regexp.MustCompile(`\$\{(self:service|self:provider\.stage|sls:stage|opt:stage[^}]*)\}`)
+
+// Read routes from the http and httpApi events of the functions in a
+// Serverless Framework serverless.yml. Functions are named as the framework
+// deploys them, service-stage-function, unless they set their own name.
+func loadServerlessRoutes(file string, stage string) (routeTable, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Service  interface{} `yaml:"service"`
+		Provider struct {
+			Stage   string `yaml:"stage"`
+			HTTPAPI struct {
+				Payload string `yaml:"payload"`
+			} `yaml:"httpApi"`
+		} `yaml:"provider"`
+		Functions map[string]struct {
+			Name   string                   `yaml:"name"`
+			Events []map[string]interface{} `yaml:"events"`
+		} `yaml:"functions"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid serverless file %v: %v", file, err)
+	}
+	// The service can also be an object with a name.
+	service, ok := config.Service.(string)
+	if object, isObject := config.Service.(map[string]interface{}); isObject {
+		service, ok = object["name"].(string)
+	}
+	if !ok || service == "" {
+		return nil, fmt.Errorf("invalid serverless file %v: no service", file)
+	}
+	if stage == "" {
+		stage = config.Provider.Stage
+	}
+	if stage == "" || strings.Contains(stage, "${") {
+		stage = "dev"
+	}
+	expand := func(s string) string {
+		return serverlessVariable.ReplaceAllStringFunc(s, func(variable string) string {
+			if variable == "${self:service}" {
+				return service
+			}
+			return stage
+		})
+	}
+
+	var table routeTable
+	for key, function := range config.Functions {
+		name := fmt.Sprintf("%v-%v-%v", service, stage, key)
+		if function.Name != "" {
+			name = expand(function.Name)
+		}
+		for _, event := range function.Events {
+			for eventType, properties := range event {
+				if eventType != "http" && eventType != "httpApi" {
+					continue
+				}
+				method, path := serverlessEventRoute(properties)
+				if path == "" && eventType == "httpApi" && (method == "*" || method == "") {
+					path = "/{proxy+}"
+				}
+				if !strings.HasPrefix(path, "/") {
+					path = "/" + path
+				}
+				rt := &route{Method: strings.ToUpper(method), Path: path, Function: name}
+				if rt.Method == "ANY" || rt.Method == "*" {
+					rt.Method = ""
+				}
+				// httpApi functions get events in payload format 2.0 unless
+				// the provider says otherwise.
+				if eventType == "httpApi" {
+					rt.PayloadFormatVersion = config.Provider.HTTPAPI.Payload
+					if rt.PayloadFormatVersion == "" {
+						rt.PayloadFormatVersion = "2.0"
+					}
+				}
+				if err := rt.compile(); err != nil {
+					return nil, fmt.Errorf("invalid serverless file %v: function %v: %v", file, key, err)
+				}
+				table = append(table, rt)
+			}
+		}
+	}
+	sortRoutes(table)
+	return table, nil
+}
+
+// The method and path of an event written as "GET users/{id}" or as an object
+// with method and path. An httpApi event of "*" is the catch-all route.
+func serverlessEventRoute(properties interface{}) (string, string) {
+	switch properties := properties.(type) {
+	case string:
+		parts := strings.Fields(properties)
+		if len(parts) == 1 {
+			return parts[0], ""
+		}
+		if len(parts) == 2 {
+			return parts[0], parts[1]
+		}
+	case map[string]interface{}:
+		method, _ := properties["method"].(string)
+		path, _ := properties["path"].(string)
+		return method, path
+	}
+	return "", ""
+}
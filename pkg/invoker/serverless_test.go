@@ -0,0 +1,61 @@
+package invoker
+
+import "testing"
+
+const testServerless = `
+service: users-api
+provider:
+  name: aws
+  stage: ${opt:stage, 'dev'}
+functions:
+  getUser:
+    handler: bin/get
+    events:
+      - http: GET users/{id}
+      - http:
+          path: users
+          method: any
+      - sqs: arn:aws:sqs:us-east-1:123456789012:queue
+  catchAll:
+    handler: bin/all
+    name: ${self:service}-${sls:stage}-everything
+    events:
+      - httpApi: '*'
+`
+
+func TestLoadServerlessRoutes(t *testing.T) {
+	file := writeConfigFile(t, "serverless*.yml", testServerless)
+	table, err := loadServerlessRoutes(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 3 {
+		t.Fatalf("expected 3 routes, got %v", len(table))
+	}
+	for _, c := range []struct {
+		method   string
+		path     string
+		function string
+		version  string
+	}{
+		{"GET", "/users/42", "users-api-dev-getUser", ""},
+		{"POST", "/users", "users-api-dev-getUser", ""},
+		{"POST", "/users/42", "users-api-dev-everything", "2.0"},
+	} {
+		if rt, _ := table.match(c.method, c.path); rt == nil || rt.Function != c.function || rt.PayloadFormatVersion != c.version {
+			t.Errorf("%v %v: unexpected route %+v", c.method, c.path, rt)
+		}
+	}
+
+	table, err = loadServerlessRoutes(file, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt, _ := table.match("GET", "/users/42"); rt == nil || rt.Function != "users-api-local-getUser" {
+		t.Errorf("expected SERVERLESS_STAGE in the name, got %+v", rt)
+	}
+
+	if _, err := loadServerlessRoutes(writeConfigFile(t, "serverless*.yml", "functions: {}\n"), ""); err == nil {
+		t.Error("expected an error without a service")
+	}
+}
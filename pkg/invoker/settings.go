@@ -0,0 +1,187 @@
+package invoker
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Kinds of value a setting can hold, so all of them can be checked up front.
+const (
+	stringSetting = iota
+	intSetting
+	durationSetting
+	boolSetting
+)
+
+// A setting can be given as an environment variable, a command line flag or,
+// where file is set, a section.key in CONFIG_FILE.
+type setting struct {
+	key   string
+	file  string
+	kind  int
+	usage string
+}
+
+var settings = []setting{
+	{"LAMBDA_NAME", "lambda.name", stringSetting, "name of the function to invoke"},
+	{"LAMBDA_ENDPOINT", "lambda.endpoint", stringSetting, "address of the Lambda API, such as http://lambda:9001"},
+	{"HOST", "server.host", stringSetting, "address to listen on, such as 127.0.0.1 or ::1 (default all interfaces)"},
+	{"PORT", "server.port", stringSetting, "port to listen on"},
+	{"LISTEN", "server.listen", stringSetting, "comma separated addresses to listen on instead of HOST and PORT, such as :8080,unix:/tmp/invoker.sock"},
+	{"AWS_CREDENTIALS", "aws.credentials", stringSetting, "static for AWS_ACCESS_KEY_ID and friends, or default for the AWS credential chain"},
+	{"AWS_PROFILE", "aws.profile", stringSetting, "shared config profile for default credentials"},
+	{"AWS_ACCESS_KEY_ID", "aws.accessKeyId", stringSetting, "access key for the Lambda API"},
+	{"AWS_SECRET_ACCESS_KEY", "aws.secretAccessKey", stringSetting, "secret key for the Lambda API"},
+	{"AWS_SESSION_TOKEN", "aws.sessionToken", stringSetting, "session token for the Lambda API"},
+	{"AWS_REGION", "aws.region", stringSetting, "region of the Lambda API"},
+	{"ASSUME_ROLE_ARN", "aws.assumeRoleArn", stringSetting, "role to assume before invoking"},
+	{"ASSUME_ROLE_EXTERNAL_ID", "aws.assumeRoleExternalId", stringSetting, "external ID for ASSUME_ROLE_ARN"},
+	{"ASSUME_ROLE_SESSION_NAME", "aws.assumeRoleSessionName", stringSetting, "session name for ASSUME_ROLE_ARN"},
+	{"TLS", "server.tls", boolSetting, "serve HTTPS on PORT, with a self-signed certificate unless TLS_CERT_FILE is set"},
+	{"TLS_SANS", "server.tlsSans", stringSetting, "comma separated names and IPs for the self-signed certificate"},
+	{"TLS_CERT_FILE", "server.tlsCertFile", stringSetting, "PEM certificate for serving HTTPS"},
+	{"TLS_KEY_FILE", "server.tlsKeyFile", stringSetting, "PEM private key for serving HTTPS"},
+	{"TLS_CLIENT_CA_FILE", "server.tlsClientCaFile", stringSetting, "PEM CA bundle to verify client certificates against"},
+	{"TLS_CLIENT_AUTH", "server.tlsClientAuth", stringSetting, "none, request or require client certificates"},
+	{"H2C", "server.h2c", boolSetting, "accept HTTP/2 without TLS on plain listeners"},
+	{"HTTPS_REDIRECT_PORT", "server.httpsRedirectPort", stringSetting, "also listen for plain HTTP on this port and redirect it to HTTPS"},
+	{"REUSE_PORT", "server.reusePort", boolSetting, "open listeners with SO_REUSEPORT so a new process can take over the port while this one drains"},
+	{"MAX_CONCURRENCY", "server.maxConcurrency", intSetting, "simultaneous invocations before throttling with a 429"},
+	{"CLIENT_RATE_LIMIT", "server.clientRateLimit", intSetting, "requests a second each client IP may send before throttling with a 429"},
+	{"CLIENT_BURST_LIMIT", "server.clientBurstLimit", intSetting, "requests a client IP may send at once within CLIENT_RATE_LIMIT"},
+	{"BASIC_AUTH", "server.basicAuth", stringSetting, "comma separated user:password pairs required as HTTP Basic auth to reach the proxy"},
+	{"AUTH_TOKEN", "server.authToken", stringSetting, "bearer token required to reach the proxy"},
+	{"INVOKE_CONCURRENCY", "server.invokeConcurrency", intSetting, "simultaneous invocations before queueing"},
+	{"INVOKE_QUEUE_DEPTH", "server.invokeQueueDepth", intSetting, "requests that may wait for an invocation"},
+	{"INVOKE_QUEUE_TIMEOUT", "server.invokeQueueTimeout", durationSetting, "how long requests may wait for an invocation"},
+	{"IDEMPOTENCY_TTL", "server.idempotencyTtl", durationSetting, "how long to answer retries with the same IDEMPOTENCY_HEADER from the first response"},
+	{"IDEMPOTENCY_HEADER", "server.idempotencyHeader", stringSetting, "request header holding idempotency keys"},
+	{"INTEGRATION_TIMEOUT", "server.integrationTimeout", durationSetting, "how long to wait for the function before a 504"},
+	{"SHADOW_FUNCTION", "server.shadowFunction", stringSetting, "function to mirror every request to in the background, logging how its responses differ"},
+	{"PAYLOAD_FORMAT_VERSION", "server.payloadFormatVersion", stringSetting, "1.0 for REST API events and responses, or 2.0 for HTTP API ones"},
+	{"REQUEST_ENCODING", "server.requestEncoding", stringSetting, "passthrough, decompress or base64, how request bodies with a Content-Encoding reach the function"},
+	{"DEFAULT_CONTENT_TYPE", "server.defaultContentType", stringSetting, "Content-Type for responses without one, or comma separated types to choose from by Accept, or off"},
+	{"MAX_REQUEST_SIZE", "server.maxRequestSize", intSetting, "largest request body in bytes"},
+	{"MAX_RESPONSE_SIZE", "server.maxResponseSize", intSetting, "largest function response in bytes"},
+	{"LAMBDA_MAX_IDLE_CONNS_PER_HOST", "lambda.maxIdleConnsPerHost", intSetting, "idle connections kept to the Lambda API"},
+	{"LAMBDA_IDLE_CONN_TIMEOUT", "lambda.idleConnTimeout", durationSetting, "how long idle connections to the Lambda API are kept"},
+	{"LAMBDA_TLS_HANDSHAKE_TIMEOUT", "lambda.tlsHandshakeTimeout", durationSetting, "TLS handshake timeout for the Lambda API"},
+	{"LAMBDA_DISABLE_KEEP_ALIVES", "lambda.disableKeepAlives", boolSetting, "open a new connection to the Lambda API for every request"},
+	{"LAMBDA_CA_FILE", "lambda.caFile", stringSetting, "PEM CA bundle to trust for an HTTPS LAMBDA_ENDPOINT"},
+	{"LAMBDA_PROXY", "lambda.proxy", stringSetting, "HTTP proxy for the Lambda API, instead of HTTP_PROXY and HTTPS_PROXY"},
+	{"LAMBDA_NO_PROXY", "lambda.noProxy", stringSetting, "comma separated hosts to reach without LAMBDA_PROXY"},
+	{"LAMBDA_INSECURE_SKIP_VERIFY", "lambda.insecureSkipVerify", boolSetting, "INSECURE: don't verify the LAMBDA_ENDPOINT certificate"},
+	{"LAMBDA_TAIL_LOGS", "lambda.tailLogs", boolSetting, "ask Lambda for the end of each invocation's logs and report its duration, memory used and cold starts"},
+	{"STEP_FUNCTIONS_ENDPOINT", "lambda.stepFunctionsEndpoint", stringSetting, "address of the Step Functions API for routes with a stateMachineArn, such as http://stepfunctions:8083"},
+	{"DISCOVER_INTERVAL", "lambda.discoverInterval", durationSetting, "how often to build routes from the tags of the functions at LAMBDA_ENDPOINT"},
+	{"DISCOVER_TAG", "lambda.discoverTag", stringSetting, "prefix of the function tags DISCOVER_INTERVAL reads routes from"},
+	{"WARM_INTERVAL", "lambda.warmInterval", durationSetting, "how often to invoke functions to keep them warm"},
+	{"WARM_FUNCTIONS", "lambda.warmFunctions", stringSetting, "comma separated functions to keep warm"},
+	{"SHUTDOWN_TIMEOUT", "server.shutdownTimeout", durationSetting, "how long to let requests finish on shutdown"},
+	{"ROUTE", "server.route", stringSetting, "path pattern for the function, such as /users/{id}"},
+	{"REQUEST_HEADERS", "server.requestHeaders", stringSetting, "changes to request headers such as \"set X-Tenant=acme; remove Cookie\""},
+	{"RESPONSE_HEADERS", "server.responseHeaders", stringSetting, "changes to response headers such as \"set X-Frame-Options=DENY; remove X-Powered-By\""},
+	{"ROUTE_IGNORE_TRAILING_SLASH", "server.routeIgnoreTrailingSlash", boolSetting, "match routes whether or not the path ends in a slash"},
+	{"ROUTE_CASE_INSENSITIVE", "server.routeCaseInsensitive", boolSetting, "match route paths whatever their case"},
+	{"ROUTES", "", stringSetting, "routes such as \"GET /users=users-fn;POST /orders/:id=orders-fn\""},
+	{"GRAPHQL_PATH", "server.graphqlPath", stringSetting, "path to answer GraphQL requests at with the resolvers in CONFIG_FILE, such as /graphql"},
+	{"GRAPHQL_JWT_IDENTITY", "server.graphqlJwtIdentity", boolSetting, "pass the claims of bearer JWTs to GraphQL resolvers as the identity, UNVERIFIED, so only for local testing"},
+	{"FALLBACK_URL", "server.fallbackUrl", stringSetting, "URL of a deployed API to send requests that match no route to, instead of LAMBDA_NAME"},
+	{"FALLBACK_SIGN", "server.fallbackSign", boolSetting, "sign requests to FALLBACK_URL with the AWS credentials, for IAM authorization"},
+	{"ROUTES_FILE", "server.routesFile", stringSetting, "JSON file of per-route settings"},
+	{"OPENAPI_FILE", "server.openapiFile", stringSetting, "OpenAPI 3 file to read routes and request validation from"},
+	{"SAM_TEMPLATE", "server.samTemplate", stringSetting, "AWS SAM template to read routes from its functions' Api and HttpApi events"},
+	{"SERVERLESS_FILE", "server.serverlessFile", stringSetting, "serverless.yml to read routes from its functions' http and httpApi events"},
+	{"SERVERLESS_STAGE", "server.serverlessStage", stringSetting, "stage in SERVERLESS_FILE function names (default provider.stage or dev)"},
+	{"CDK_OUT", "server.cdkOut", stringSetting, "CDK cloud assembly directory or synthesized template to read API Gateway routes from"},
+	{"WATCH_CONFIG", "server.watchConfig", boolSetting, "reload when CONFIG_FILE or a routes file changes"},
+	{"LOG_LEVEL", "server.logLevel", stringSetting, "debug, info, warn or error"},
+	{"LOG_FORMAT", "server.logFormat", stringSetting, "json or text"},
+	{"ACCESS_LOG", "server.accessLog", stringSetting, "json, combined, off or a template for access log lines"},
+	{"CORRELATION_ID_HEADER", "server.correlationIdHeader", stringSetting, "header carrying correlation IDs between services"},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "server.otelExporterOtlpEndpoint", stringSetting, "OTLP/HTTP collector to send OpenTelemetry traces to"},
+	{"OTEL_SERVICE_NAME", "server.otelServiceName", stringSetting, "service name for OpenTelemetry traces"},
+	{"STATSD_HOST", "server.statsdHost", stringSetting, "StatsD server to send request metrics to"},
+	{"STATSD_PORT", "server.statsdPort", stringSetting, "port of the StatsD server"},
+	{"STATSD_PREFIX", "server.statsdPrefix", stringSetting, "prefix for StatsD metric names"},
+	{"STATSD_TAGS", "server.statsdTags", stringSetting, "comma separated tags such as env:dev added to every StatsD metric"},
+	{"EMF_NAMESPACE", "server.emfNamespace", stringSetting, "log CloudWatch Embedded Metric Format metrics in this namespace"},
+	{"ADMIN_ADDRESS", "server.adminAddress", stringSetting, "address such as 127.0.0.1:6060 to serve admin endpoints on"},
+	{"PPROF", "server.pprof", boolSetting, "serve net/http/pprof profiles on ADMIN_ADDRESS"},
+	{"DASHBOARD_SIZE", "server.dashboardSize", intSetting, "number of recent requests the ADMIN_ADDRESS dashboard keeps, 0 to turn it off"},
+	{"DEBUG_PAYLOADS", "server.debugPayloads", boolSetting, "log every event and response payload"},
+	{"DEBUG_REDACT_HEADERS", "server.debugRedactHeaders", stringSetting, "comma separated headers to hide in DEBUG_PAYLOADS logs"},
+	{"RECORD_FILE", "server.recordFile", stringSetting, "append every event and response to this JSON lines file"},
+	{"REPLAY_FILE", "server.replayFile", stringSetting, "answer requests from exchanges recorded in this file instead of invoking"},
+	{"REPLAY_FALLBACK", "server.replayFallback", stringSetting, "error or invoke, for requests REPLAY_FILE has no exchange for"},
+	{"MIDDLEWARE", "server.middleware", stringSetting, "comma separated order of the middlewares around the function, outermost first"},
+	{"PLUGINS", "server.plugins", stringSetting, "comma separated Go plugins that can change events and responses"},
+	{"PRE_INVOKE_HOOK", "server.preInvokeHook", stringSetting, "URL or command given each event, which can change it or answer instead of the function"},
+	{"POST_INVOKE_HOOK", "server.postInvokeHook", stringSetting, "URL or command given each event and response, which can change the response"},
+	{"HOOK_TIMEOUT", "server.hookTimeout", durationSetting, "how long PRE_INVOKE_HOOK and POST_INVOKE_HOOK may take"},
+	{"RESPONSE_STREAMING", "server.responseStreaming", boolSetting, "invoke functions with InvokeWithResponseStream and send their output as it arrives"},
+	{"METHOD_OVERRIDE", "server.methodOverride", boolSetting, "treat POSTs with an X-HTTP-Method-Override header as the method it names"},
+	{"CHAOS_LATENCY_PERCENT", "server.chaosLatencyPercent", intSetting, "percentage of requests to delay by CHAOS_LATENCY"},
+	{"CHAOS_LATENCY", "server.chaosLatency", durationSetting, "how long CHAOS_LATENCY_PERCENT delays requests"},
+	{"CHAOS_ERROR_PERCENT", "server.chaosErrorPercent", intSetting, "percentage of requests to answer with CHAOS_ERROR_STATUS without invoking"},
+	{"CHAOS_ERROR_STATUS", "server.chaosErrorStatus", intSetting, "status of the errors CHAOS_ERROR_PERCENT injects"},
+	{"CHAOS_DROP_PERCENT", "server.chaosDropPercent", intSetting, "percentage of connections to drop without a response"},
+	{"CHAOS_TRUNCATE_PERCENT", "server.chaosTruncatePercent", intSetting, "percentage of responses to cut off halfway through the body"},
+	{"DRY_RUN", "server.dryRun", boolSetting, "return the event instead of invoking the function"},
+	{"CONFIG_FILE", "", stringSetting, "YAML or JSON file of settings and routes"},
+	{"DOTENV_FILE", "", stringSetting, ".env file to load (default .env)"},
+}
+
+// Find the setting a CONFIG_FILE key stands in for.
+func fileSettingKey(section string, name string) (string, bool) {
+	for _, s := range settings {
+		if s.file != "" && s.file == section+"."+name {
+			return s.key, true
+		}
+	}
+	return "", false
+}
+
+// Values given on the command line, which win over everything else.
+var flagSettings = make(map[string]string)
+
+type settingFlag struct {
+	key    string
+	isBool bool
+}
+
+func (f settingFlag) String() string     { return flagSettings[f.key] }
+func (f settingFlag) IsBoolFlag() bool   { return f.isBool }
+func (f settingFlag) Set(v string) error { flagSettings[f.key] = v; return nil }
+
+// PORT becomes --port and LAMBDA_NAME becomes --lambda-name.
+func flagName(key string) string {
+	return strings.ToLower(strings.Replace(key, "_", "-", -1))
+}
+
+// Add a flag for every setting to fs.
+func registerSettingFlags(fs *flag.FlagSet) {
+	for _, s := range settings {
+		fs.Var(settingFlag{s.key, s.kind == boolSetting}, flagName(s.key), fmt.Sprintf("%v (%v)", s.usage, s.key))
+	}
+}
+
+// Make sure every setting that has been given can be parsed.
+func checkSettings() error {
+	for _, s := range settings {
+		var err error
+		switch s.kind {
+		case intSetting:
+			_, err = getConfigInt(s.key)
+		case durationSetting:
+			_, err = getConfigDuration(s.key)
+		case boolSetting:
+			_, err = getConfigBool(s.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
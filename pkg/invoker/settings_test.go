@@ -0,0 +1,63 @@
+package invoker
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSettingFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	registerSettingFlags(fs)
+	defer func() { flagSettings = make(map[string]string) }()
+
+	os.Setenv("LAMBDA_NAME", "FromEnv")
+	defer os.Unsetenv("LAMBDA_NAME")
+
+	if err := fs.Parse([]string{"--lambda-name", "FromFlag", "-port=9000", "--watch-config", "validate"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"LAMBDA_NAME":  "FromFlag",
+		"PORT":         "9000",
+		"WATCH_CONFIG": "true",
+	}
+	for key, value := range expected {
+		if got := getConfig(key); got != value {
+			t.Errorf("unexpected %v: got %v want %v", key, got, value)
+		}
+	}
+	if fs.Arg(0) != "validate" {
+		t.Errorf("unexpected subcommand: %v", fs.Arg(0))
+	}
+}
+
+func TestCheckSettings(t *testing.T) {
+	if err := checkSettings(); err != nil {
+		t.Errorf("defaults should be valid, got %v", err)
+	}
+
+	os.Setenv("LAMBDA_DISABLE_KEEP_ALIVES", "sometimes")
+	defer os.Unsetenv("LAMBDA_DISABLE_KEEP_ALIVES")
+	if err := checkSettings(); err == nil {
+		t.Error("expected an error for an invalid LAMBDA_DISABLE_KEEP_ALIVES")
+	}
+}
+
+func TestSettingsAreUnique(t *testing.T) {
+	keys := make(map[string]bool)
+	files := make(map[string]bool)
+	for _, s := range settings {
+		if keys[s.key] {
+			t.Errorf("setting %v is listed twice", s.key)
+		}
+		keys[s.key] = true
+		if s.file != "" && files[s.file] {
+			t.Errorf("config file key %v is used twice", s.file)
+		}
+		files[s.file] = true
+	}
+}
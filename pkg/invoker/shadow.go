@@ -0,0 +1,97 @@
+package invoker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsrequest "github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// The function to mirror requests for rt to: its own shadowFunction, or else
+// SHADOW_FUNCTION.
+func shadowFunction(rt *route) string {
+	if rt != nil && rt.ShadowFunction != "" {
+		return rt.ShadowFunction
+	}
+	return getConfig("SHADOW_FUNCTION")
+}
+
+// Invoke shadow with the same event as the function, in the background, and
+// log how its response differs from the one the client got. Nothing the
+// shadow does reaches the client.
+func (c *LambdaClient) mirrorInvocation(shadow string, payload []byte, response []byte, trace string, timeout time.Duration, fields logFields) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	fields["shadow_function"] = shadow
+	start := time.Now()
+	result, err := c.InvokeWithContext(ctx, &lambda.InvokeInput{FunctionName: aws.String(shadow), Payload: payload},
+		awsrequest.WithSetRequestHeaders(map[string]string{traceHeaderName: trace}))
+	fields["shadow_latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		fields["error"] = err
+		logWarn("Shadow invocation failed", fields)
+		return
+	}
+	debugPayload("Shadow function returned", result.Payload, fields)
+	if result.FunctionError != nil {
+		fields["error"] = aws.StringValue(result.FunctionError)
+		logWarn("Shadow invocation failed", fields)
+		return
+	}
+	differences := compareResponses(response, result.Payload)
+	if len(differences) == 0 {
+		logInfo("Shadow response matched", fields)
+		return
+	}
+	fields["differences"] = differences
+	logWarn("Shadow response differed", fields)
+}
+
+// Describe how two function responses differ in status, headers and body.
+// Responses that aren't JSON objects are compared as they are.
+func compareResponses(primary []byte, shadow []byte) []string {
+	var a, b Response
+	if json.Unmarshal(primary, &a) != nil || json.Unmarshal(shadow, &b) != nil {
+		if string(primary) != string(shadow) {
+			return []string{"response"}
+		}
+		return nil
+	}
+	var differences []string
+	if a.StatusCode != b.StatusCode {
+		differences = append(differences, fmt.Sprintf("statusCode: %v != %v", a.StatusCode, b.StatusCode))
+	}
+	names := make(map[string]bool)
+	for name := range a.Headers {
+		names[name] = true
+	}
+	for name := range b.Headers {
+		names[name] = true
+	}
+	var changed []string
+	for name := range names {
+		if a.Headers[name] != b.Headers[name] {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	for _, name := range changed {
+		differences = append(differences, fmt.Sprintf("headers.%v: %q != %q", name, a.Headers[name], b.Headers[name]))
+	}
+	if fmt.Sprint(a.Cookies) != fmt.Sprint(b.Cookies) {
+		differences = append(differences, "cookies")
+	}
+	if a.Body != b.Body || a.IsBase64Encoded != b.IsBase64Encoded {
+		differences = append(differences, fmt.Sprintf("body: %v bytes != %v bytes", len(a.Body), len(b.Body)))
+	}
+	return differences
+}
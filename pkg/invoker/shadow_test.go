@@ -0,0 +1,74 @@
+package invoker
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// Answers with a response for each function, and passes on the names of
+// functions invoked.
+type namedLambdaClient struct {
+	lambdaiface.LambdaAPI
+	responses map[string]string
+	invoked   chan string
+}
+
+func (m namedLambdaClient) InvokeWithContext(_ aws.Context, in *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	name := aws.StringValue(in.FunctionName)
+	if m.invoked != nil {
+		m.invoked <- name
+	}
+	return &lambda.InvokeOutput{Payload: []byte(m.responses[name])}, nil
+}
+
+func TestShadowFunction(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "users")
+	defer os.Unsetenv("LAMBDA_NAME")
+	os.Setenv("SHADOW_FUNCTION", "users-v2")
+	defer os.Unsetenv("SHADOW_FUNCTION")
+
+	invoked := make(chan string, 2)
+	c := LambdaClient{namedLambdaClient{
+		responses: map[string]string{"users": `{"statusCode":200,"body":"ok"}`, "users-v2": `{"statusCode":500}`},
+		invoked:   invoked,
+	}}
+	rr := httptest.NewRecorder()
+	c.invokeLambda(rr, httptest.NewRequest("GET", "/users", nil))
+	if rr.Code != 200 || rr.Body.String() != "ok" {
+		t.Errorf("expected the function's response, got %v %v", rr.Code, rr.Body.String())
+	}
+	for _, want := range []string{"users", "users-v2"} {
+		select {
+		case name := <-invoked:
+			if name != want {
+				t.Errorf("expected %v to be invoked, got %v", want, name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v wasn't invoked", want)
+		}
+	}
+}
+
+func TestMirrorInvocation(t *testing.T) {
+	out := captureLogs(t)
+	c := LambdaClient{namedLambdaClient{responses: map[string]string{"users-v2": `{"statusCode":200,"headers":{"X-Version":"2"},"body":"ok!"}`}}}
+	c.mirrorInvocation("users-v2", []byte(`{}`), []byte(`{"statusCode":200,"headers":{"X-Version":"1"},"body":"ok"}`), "", 0, logFields{"path": "/users"})
+	logged := out.String()
+	for _, want := range []string{"Shadow response differed", `headers.X-Version: \"1\" != \"2\"`, "body: 2 bytes != 3 bytes", `"shadow_function":"users-v2"`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected %v in %v", want, logged)
+		}
+	}
+
+	if differences := compareResponses([]byte(`{"statusCode":201,"body":"x"}`), []byte(`{"body":"x","statusCode":201}`)); len(differences) != 0 {
+		t.Errorf("expected the same response, got %v", differences)
+	}
+}
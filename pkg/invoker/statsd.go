@@ -0,0 +1,62 @@
+package invoker
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Sends request metrics to a StatsD server over UDP, tagged in the DogStatsD
+// style understood by the Datadog agent, Telegraf and statsd_exporter.
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+}
+
+func newStatsdClient(address string, prefix string, tags string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STATSD_HOST: %v", err)
+	}
+	c := &statsdClient{conn: conn, prefix: prefix}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			c.tags = append(c.tags, tag)
+		}
+	}
+	return c, nil
+}
+
+// Count the request, time it and count it again as an error if it failed,
+// tagged with its function, route and status. With LAMBDA_TAIL_LOGS the
+// function's own duration, memory used and cold starts go too. Metrics go out
+// in one packet and are lost if nothing is listening, as is usual for StatsD.
+func (c *statsdClient) observe(s *requestSummary) {
+	tags := append([]string{}, c.tags...)
+	if s.Function != "" {
+		tags = append(tags, "function:"+s.Function)
+	}
+	if s.Route != "" {
+		tags = append(tags, "route:"+s.Route)
+	}
+	tags = append(tags, fmt.Sprintf("method:%v", s.Method), fmt.Sprintf("status:%v", s.Status))
+	suffix := "|#" + strings.Join(tags, ",")
+
+	var packet bytes.Buffer
+	fmt.Fprintf(&packet, "%vrequests:1|c%v\n", c.prefix, suffix)
+	fmt.Fprintf(&packet, "%vlatency:%v|ms%v\n", c.prefix, float64(s.Latency.Microseconds())/1000, suffix)
+	if s.Status >= 500 {
+		fmt.Fprintf(&packet, "%verrors:1|c%v\n", c.prefix, suffix)
+	}
+	if report := s.Report; report != nil {
+		fmt.Fprintf(&packet, "%vduration:%v|ms%v\n", c.prefix, float64(report.Duration.Microseconds())/1000, suffix)
+		fmt.Fprintf(&packet, "%vmax_memory_used:%v|h%v\n", c.prefix, report.MaxMemoryUsed, suffix)
+		if report.ColdStart() {
+			fmt.Fprintf(&packet, "%vcold_starts:1|c%v\n", c.prefix, suffix)
+			fmt.Fprintf(&packet, "%vinit_duration:%v|ms%v\n", c.prefix, float64(report.InitDuration.Microseconds())/1000, suffix)
+		}
+	}
+	c.conn.Write(bytes.TrimSuffix(packet.Bytes(), []byte("\n")))
+}
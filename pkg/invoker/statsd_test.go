@@ -0,0 +1,33 @@
+package invoker
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsdMetrics(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	c, err := newStatsdClient(server.LocalAddr().String(), "invoker.", "env:dev, ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.observe(&requestSummary{Method: "GET", Function: "users", Route: "/users/{id}", Status: 502, Latency: 1500 * time.Microsecond})
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := "|#env:dev,function:users,route:/users/{id},method:GET,status:502"
+	expected := "invoker.requests:1|c" + tags + "\ninvoker.latency:1.5|ms" + tags + "\ninvoker.errors:1|c" + tags
+	if packet := string(buf[:n]); packet != expected {
+		t.Errorf("unexpected metrics:\n%v\nwant:\n%v", packet, expected)
+	}
+}
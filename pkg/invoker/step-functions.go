@@ -0,0 +1,177 @@
+package invoker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
+)
+
+// The actions a route can start its state machine with, as API Gateway's
+// Step Functions integrations do. Only express workflows can be run
+// synchronously.
+const (
+	startSyncExecution = "StartSyncExecution"
+	startExecution     = "StartExecution"
+)
+
+var sfnClients = make(map[clientConfig]sfniface.SFNAPI)
+
+// Check the state machine settings of a route.
+func (rt *route) checkStateMachine() error {
+	if rt.StateMachineArn == "" {
+		if rt.StateMachineAction != "" || rt.StateMachineInput != "" {
+			return fmt.Errorf("stateMachineAction and stateMachineInput need a stateMachineArn")
+		}
+		return nil
+	}
+	switch rt.StateMachineAction {
+	case "", startSyncExecution, startExecution:
+	default:
+		return fmt.Errorf("invalid stateMachineAction %q: must be %v or %v", rt.StateMachineAction, startSyncExecution, startExecution)
+	}
+	switch rt.StateMachineInput {
+	case "", "body", "event":
+	default:
+		return fmt.Errorf("invalid stateMachineInput %q: must be body or event", rt.StateMachineInput)
+	}
+	return nil
+}
+
+// The Step Functions client for a route, at STEP_FUNCTIONS_ENDPOINT or the
+// route's own endpoint, with the same credentials as the Lambda client.
+func sfnClientFor(rt *route) (sfniface.SFNAPI, error) {
+	cfg, err := currentClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Endpoint = getConfig("STEP_FUNCTIONS_ENDPOINT")
+	if rt.Region != "" {
+		cfg.Region = rt.Region
+	}
+	if rt.Endpoint != "" {
+		cfg.Endpoint = rt.Endpoint
+	}
+
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if c, ok := sfnClients[cfg]; ok {
+		return c, nil
+	}
+	sess, err := newSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sfnConfig := &aws.Config{}
+	// Step Functions Local has no sync- host for synchronous executions as
+	// AWS does.
+	if cfg.Endpoint != "" {
+		sfnConfig.Endpoint = aws.String(cfg.Endpoint)
+		sfnConfig.DisableEndpointHostPrefix = aws.Bool(true)
+	}
+	if cfg.RoleARN != "" {
+		sfnConfig.Credentials = assumeRoleCredentials(sess, cfg)
+	}
+	c := sfn.New(sess, sfnConfig)
+	sfnClients[cfg] = c
+	return c, nil
+}
+
+// Start the route's state machine with the request body, or the whole event
+// with stateMachineInput event, as its input. A synchronous execution answers
+// with its output, which can be a proxy integration response to set the
+// status and headers, while an asynchronous one answers with the execution's
+// ARN and start date as API Gateway does.
+func startStateMachine(ctx context.Context, w http.ResponseWriter, r *http.Request, rt *route, input []byte, trace string, fields logFields) {
+	fields["state_machine"] = rt.StateMachineArn
+	if len(input) == 0 {
+		input = []byte("{}")
+	}
+	if !json.Valid(input) {
+		logWarn("Request body isn't JSON", fields)
+		gatewayError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	c, err := sfnClientFor(rt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	start := time.Now()
+	var response []byte
+	if rt.StateMachineAction == startExecution {
+		var result *sfn.StartExecutionOutput
+		result, err = c.StartExecutionWithContext(ctx, &sfn.StartExecutionInput{
+			StateMachineArn: aws.String(rt.StateMachineArn),
+			Input:           aws.String(string(input)),
+			TraceHeader:     aws.String(trace),
+		})
+		if err == nil {
+			body, _ := json.Marshal(map[string]interface{}{
+				"executionArn": aws.StringValue(result.ExecutionArn),
+				"startDate":    float64(aws.TimeValue(result.StartDate).UnixNano()) / 1e9,
+			})
+			response, _ = json.Marshal(restResponse{StatusCode: http.StatusOK, Body: string(body)})
+		}
+	} else {
+		var result *sfn.StartSyncExecutionOutput
+		result, err = c.StartSyncExecutionWithContext(ctx, &sfn.StartSyncExecutionInput{
+			StateMachineArn: aws.String(rt.StateMachineArn),
+			Input:           aws.String(string(input)),
+			TraceHeader:     aws.String(trace),
+		})
+		if err == nil {
+			fields["execution_arn"] = aws.StringValue(result.ExecutionArn)
+			response = executionResponse(result)
+		}
+	}
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			logWarn("Endpoint request timed out", fields)
+			gatewayError(w, http.StatusGatewayTimeout, "Endpoint request timed out")
+			return
+		case context.Canceled:
+			logInfo("Client disconnected, cancelled execution", fields)
+			return
+		}
+		fields["error"] = err
+		logError("Execution failed to start", fields)
+		gatewayError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+	writeResponse(w, r, rt, response, fields)
+}
+
+// The response for a synchronous execution. Output that is already a proxy
+// integration response is used as it is, other output is the body of a 200
+// and executions that didn't succeed are a 502 with their error and cause.
+func executionResponse(result *sfn.StartSyncExecutionOutput) []byte {
+	status := aws.StringValue(result.Status)
+	if status != sfn.SyncExecutionStatusSucceeded {
+		body, _ := json.Marshal(map[string]string{
+			"status": status,
+			"error":  aws.StringValue(result.Error),
+			"cause":  aws.StringValue(result.Cause),
+		})
+		response, _ := json.Marshal(restResponse{StatusCode: http.StatusBadGateway, Body: string(body)})
+		return response
+	}
+	output := strings.TrimSpace(aws.StringValue(result.Output))
+	var proxyResponse struct {
+		StatusCode int `json:"statusCode"`
+	}
+	if json.Unmarshal([]byte(output), &proxyResponse) == nil && proxyResponse.StatusCode != 0 {
+		return []byte(output)
+	}
+	response, _ := json.Marshal(restResponse{StatusCode: http.StatusOK, Body: output})
+	return response
+}
@@ -0,0 +1,152 @@
+package invoker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// Answers StartSyncExecution and StartExecution as Step Functions Local
+// would, passing on the action and input of each.
+func fakeStepFunctions(t *testing.T, output string) (*httptest.Server, chan map[string]string) {
+	started := make(chan map[string]string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			StateMachineArn string `json:"stateMachineArn"`
+			Input           string `json:"input"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &input); err != nil {
+			t.Errorf("invalid request %s: %v", body, err)
+		}
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AWSStepFunctions.")
+		started <- map[string]string{"action": action, "host": r.Host, "arn": input.StateMachineArn, "input": input.Input}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if action == "StartExecution" {
+			w.Write([]byte(`{"executionArn":"arn:aws:states:us-east-1:123456789012:execution:orders:1","startDate":1600000000.5}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"executionArn": "arn:aws:states:us-east-1:123456789012:express:orders:1",
+			"status":       "SUCCEEDED",
+			"output":       output,
+		})
+	}))
+	return server, started
+}
+
+func TestStateMachineRoute(t *testing.T) {
+	server, started := fakeStepFunctions(t, `{"id":42}`)
+	defer server.Close()
+	os.Setenv("STEP_FUNCTIONS_ENDPOINT", server.URL)
+	defer os.Unsetenv("STEP_FUNCTIONS_ENDPOINT")
+
+	arn := "arn:aws:states:us-east-1:123456789012:stateMachine:orders"
+	rt := &route{Method: "POST", Path: "/orders", StateMachineArn: arn}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+	if err := validateConfig(); err != nil {
+		t.Errorf("expected no need for LAMBDA_NAME with only state machine routes: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"item":"book"}`)))
+	if rr.Code != 200 || rr.Body.String() != `{"id":42}` || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %v %v %v", rr.Code, rr.Header(), rr.Body.String())
+	}
+	call := <-started
+	if call["action"] != "StartSyncExecution" || call["arn"] != arn || call["input"] != `{"item":"book"}` {
+		t.Errorf("unexpected execution %v", call)
+	}
+	if !strings.HasPrefix(server.URL, "http://"+call["host"]) {
+		t.Errorf("expected no host prefix for a local endpoint, got %v", call["host"])
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("POST", "/orders", strings.NewReader("not json")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400 for a body that isn't JSON, got %v", rr.Code)
+	}
+
+	rt.StateMachineAction = "StartExecution"
+	rt.StateMachineInput = "event"
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("POST", "/orders?rush=true", strings.NewReader(`{"item":"book"}`)))
+	if rr.Code != 200 || rr.Body.String() != `{"executionArn":"arn:aws:states:us-east-1:123456789012:execution:orders:1","startDate":1600000000.5}` {
+		t.Errorf("unexpected response %v %v", rr.Code, rr.Body.String())
+	}
+	call = <-started
+	var event makeProxyRequest
+	if err := json.Unmarshal([]byte(call["input"]), &event); err != nil || call["action"] != "StartExecution" {
+		t.Fatalf("unexpected execution %v: %v", call, err)
+	}
+	if event.Path != "/orders" || event.Body != `{"item":"book"}` || strings.Join(event.QueryStringParams["rush"], ",") != "true" {
+		t.Errorf("expected the event as input, got %+v", event)
+	}
+}
+
+func TestExecutionResponse(t *testing.T) {
+	for _, c := range []struct {
+		result   sfn.StartSyncExecutionOutput
+		expected restResponse
+	}{
+		{
+			sfn.StartSyncExecutionOutput{Status: aws.String("SUCCEEDED"), Output: aws.String(`{"statusCode":201,"headers":{"Location":"/orders/1"},"body":""}`)},
+			restResponse{StatusCode: 201, Headers: map[string]string{"Location": "/orders/1"}},
+		},
+		{
+			sfn.StartSyncExecutionOutput{Status: aws.String("SUCCEEDED"), Output: aws.String("[1,2]")},
+			restResponse{StatusCode: 200, Body: "[1,2]"},
+		},
+		{
+			sfn.StartSyncExecutionOutput{Status: aws.String("FAILED"), Error: aws.String("States.TaskFailed"), Cause: aws.String("boom")},
+			restResponse{StatusCode: 502, Body: `{"cause":"boom","error":"States.TaskFailed","status":"FAILED"}`},
+		},
+	} {
+		var response restResponse
+		if err := json.Unmarshal(executionResponse(&c.result), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != c.expected.StatusCode || response.Body != c.expected.Body || response.Headers["Location"] != c.expected.Headers["Location"] {
+			t.Errorf("%v: got %+v want %+v", c.result, response, c.expected)
+		}
+	}
+
+	rt := &route{Path: "/orders", StateMachineArn: "arn", StateMachineAction: "Invoke"}
+	if err := rt.compile(); err == nil {
+		t.Error("expected an error for an unknown stateMachineAction")
+	}
+}
+
+func TestStateMachineStartFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"StateMachineDoesNotExist","message":"State Machine Does Not Exist"}`))
+	}))
+	defer server.Close()
+	os.Setenv("STEP_FUNCTIONS_ENDPOINT", server.URL)
+	defer os.Unsetenv("STEP_FUNCTIONS_ENDPOINT")
+
+	rt := &route{Method: "POST", Path: "/orders", StateMachineArn: "arn:aws:states:us-east-1:123456789012:stateMachine:missing"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("POST", "/orders", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusBadGateway || rr.Body.String() != `{"message":"Internal server error"}` {
+		t.Errorf("expected a 502 when the execution can't start, got %v %v", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,218 @@
+package invoker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// The content type of a stream that starts with the response's status and
+// headers, as functions using awslambda.HttpResponseStream.from send.
+const httpIntegrationResponse = "application/vnd.awslambda.http-integration-response"
+
+// What separates the status and headers from the body in such a stream.
+var httpIntegrationDelimiter = make([]byte, 8)
+
+// A function's output arriving from InvokeWithResponseStream.
+type responseStream struct {
+	contentType string
+	body        io.ReadCloser
+	decoder     *eventstream.Decoder
+}
+
+// Invoke a function with InvokeWithResponseStream, which the SDK this is built
+// with predates, leaving the output to be read as it arrives.
+func (c *LambdaClient) invokeWithResponseStream(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*responseStream, error) {
+	svc, ok := c.LambdaAPI.(*lambda.Lambda)
+	if !ok {
+		return nil, errors.New("response streaming needs a Lambda API client")
+	}
+	req := svc.NewRequest(&request.Operation{
+		Name:       "InvokeWithResponseStream",
+		HTTPMethod: "POST",
+		HTTPPath:   "/2021-11-15/functions/{FunctionName}/response-streaming-invocations",
+	}, input, &lambda.InvokeOutput{})
+	// Unmarshalling would read the whole body before returning.
+	req.Handlers.Unmarshal.Clear()
+	req.SetContext(ctx)
+	req.ApplyOptions(opts...)
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+	return &responseStream{
+		contentType: req.HTTPResponse.Header.Get("Content-Type"),
+		body:        req.HTTPResponse.Body,
+		decoder:     eventstream.NewDecoder(req.HTTPResponse.Body),
+	}, nil
+}
+
+// The next chunk of output, or io.EOF once the function has finished.
+func (s *responseStream) next() ([]byte, error) {
+	for {
+		msg, err := s.decoder.Decode(nil)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		header := func(name string) string {
+			value, _ := msg.Headers.Get(name).(eventstream.StringValue)
+			return string(value)
+		}
+		if header(":message-type") != "event" {
+			return nil, fmt.Errorf("%v: %s", header(":exception-type")+header(":error-code"), msg.Payload)
+		}
+		switch header(":event-type") {
+		case "PayloadChunk":
+			if len(msg.Payload) > 0 {
+				return msg.Payload, nil
+			}
+		case "InvokeComplete":
+			var complete struct {
+				ErrorCode    string
+				ErrorDetails string
+			}
+			json.Unmarshal(msg.Payload, &complete)
+			if complete.ErrorCode != "" {
+				return nil, fmt.Errorf("%v: %v", complete.ErrorCode, complete.ErrorDetails)
+			}
+			return nil, io.EOF
+		}
+	}
+}
+
+// Whether rt's function streams its response, from the route or
+// RESPONSE_STREAMING.
+func isStreaming(rt *route) (bool, error) {
+	if rt != nil && rt.Streaming {
+		return true, nil
+	}
+	return getConfigBool("RESPONSE_STREAMING")
+}
+
+// Invoke the function and send its output to the client as it arrives,
+// flushing each chunk, so Server-Sent Events and the like aren't held back.
+// The timeout only applies until the output starts. If the function fails
+// after that the connection is dropped, so the client can tell the response
+// was cut short.
+func (c *LambdaClient) streamRoute(w http.ResponseWriter, r *http.Request, rt *route, function string, payload []byte, trace string, timeout time.Duration, fields logFields) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	timer := time.AfterFunc(timeout, cancel)
+	if timeout <= 0 {
+		timer.Stop()
+	}
+
+	debugPayload("Invoking function", payload, fields)
+	invokeSpan := startSpan(ctx, "Lambda.InvokeWithResponseStream", spanKindClient)
+	invokeSpan.set("rpc.system", "aws-api")
+	invokeSpan.set("rpc.service", "Lambda")
+	invokeSpan.set("rpc.method", "InvokeWithResponseStream")
+	invokeSpan.set("faas.invoked_name", function)
+	start := time.Now()
+	stream, err := c.invokeWithResponseStream(ctx, &lambda.InvokeInput{FunctionName: aws.String(function), Payload: payload},
+		request.WithSetRequestHeaders(map[string]string{traceHeaderName: trace}))
+	var chunk []byte
+	if err == nil {
+		defer stream.body.Close()
+		chunk, err = stream.next()
+	}
+	// Time to first byte, as the rest depends on the function.
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil && err != io.EOF {
+		invokeSpan.fail()
+	}
+	invokeSpan.finish()
+	if err != nil && err != io.EOF {
+		switch {
+		case r.Context().Err() == nil && ctx.Err() != nil:
+			logWarn("Endpoint request timed out", fields)
+			gatewayError(w, http.StatusGatewayTimeout, "Endpoint request timed out")
+		case r.Context().Err() != nil:
+			logInfo("Client disconnected, cancelled invocation", fields)
+		default:
+			fields["error"] = err
+			logError("Invocation failed", fields)
+			gatewayError(w, http.StatusBadGateway, "Internal server error")
+		}
+		return
+	}
+	// The output has started, so let it run as long as the client stays.
+	timer.Stop()
+
+	status := http.StatusOK
+	if stream.contentType == httpIntegrationResponse {
+		var prelude []byte
+		i := -1
+		for err == nil {
+			prelude = append(prelude, chunk...)
+			if i = bytes.Index(prelude, httpIntegrationDelimiter); i >= 0 {
+				break
+			}
+			chunk, err = stream.next()
+		}
+		var response struct {
+			StatusCode int               `json:"statusCode"`
+			Headers    map[string]string `json:"headers"`
+			Cookies    []string          `json:"cookies"`
+		}
+		if i < 0 || json.Unmarshal(prelude[:i], &response) != nil {
+			fields["error"] = err
+			logError("Invalid streamed response", fields)
+			gatewayError(w, http.StatusBadGateway, "Internal server error")
+			return
+		}
+		chunk = prelude[i+len(httpIntegrationDelimiter):]
+		if response.StatusCode != 0 {
+			status = response.StatusCode
+		}
+		for key, value := range response.Headers {
+			w.Header().Set(key, value)
+		}
+		for _, cookie := range response.Cookies {
+			w.Header().Add("Set-Cookie", cookie)
+		}
+	} else if stream.contentType != "" {
+		w.Header().Set("Content-Type", stream.contentType)
+	}
+	setResponseHeaders(w, rt)
+	// The length isn't known until the function finishes.
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	fields["status"] = status
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		if len(chunk) > 0 && r.Method != http.MethodHead {
+			if _, werr := w.Write(chunk); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			break
+		}
+		chunk, err = stream.next()
+	}
+	if err != io.EOF && r.Context().Err() == nil {
+		fields["error"] = err
+		logError("Streamed invocation failed", fields)
+		// The status has been sent, so reset the connection rather than
+		// end the body as if it were complete.
+		panic(http.ErrAbortHandler)
+	}
+	logDebug("Invoked function", fields)
+}
@@ -0,0 +1,149 @@
+package invoker
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+)
+
+// A Lambda API that streams chunks, waiting for each to be received, and then
+// completes with complete.
+func streamingLambda(t *testing.T, contentType string, chunks []string, complete string, received chan string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2021-11-15/functions/chat/response-streaming-invocations" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", contentType)
+		encoder := eventstream.NewEncoder(w)
+		event := func(eventType string, payload string) {
+			encoder.Encode(eventstream.Message{
+				Headers: eventstream.Headers{
+					{Name: ":message-type", Value: eventstream.StringValue("event")},
+					{Name: ":event-type", Value: eventstream.StringValue(eventType)},
+				},
+				Payload: []byte(payload),
+			})
+			w.(http.Flusher).Flush()
+		}
+		for _, chunk := range chunks {
+			event("PayloadChunk", chunk)
+			if received != nil {
+				select {
+				case <-received:
+				case <-time.After(5 * time.Second):
+					t.Error("chunk wasn't flushed to the client")
+				}
+			}
+		}
+		event("InvokeComplete", complete)
+	}))
+}
+
+func TestStreamedServerSentEvents(t *testing.T) {
+	received := make(chan string)
+	lambdaAPI := streamingLambda(t, httpIntegrationResponse, []string{
+		`{"statusCode":200,"headers":{"Content-Type":"text/event-stream"},"cookies":["a=1"]}` + "\x00\x00\x00\x00",
+		"\x00\x00\x00\x00data: one\n\n",
+		"data: two\n\n",
+	}, "{}", received)
+	defer lambdaAPI.Close()
+	os.Setenv("LAMBDA_ENDPOINT", lambdaAPI.URL)
+	defer os.Unsetenv("LAMBDA_ENDPOINT")
+	os.Setenv("RESPONSE_STREAMING", "true")
+	defer os.Unsetenv("RESPONSE_STREAMING")
+
+	c, err := getLambdaClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &route{Path: "/chat", Function: "chat"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.invokeRoute(w, r, rt, nil)
+	}))
+	defer proxy.Close()
+
+	// The first chunk only completes the prelude, so nothing is received yet.
+	go func() { received <- "" }()
+	resp, err := http.Get(proxy.URL + "/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("Set-Cookie") != "a=1" || resp.ContentLength != -1 {
+		t.Errorf("unexpected response %v %v", resp.StatusCode, resp.Header)
+	}
+	lines := bufio.NewReader(resp.Body)
+	for _, expected := range []string{"data: one\n", "\n", "data: two\n", "\n"} {
+		line, err := lines.ReadString('\n')
+		if err != nil || line != expected {
+			t.Fatalf("got %q %v want %q", line, err, expected)
+		}
+		if strings.HasPrefix(line, "data:") {
+			received <- line
+		}
+	}
+}
+
+func TestStreamedResponseWithoutPrelude(t *testing.T) {
+	lambdaAPI := streamingLambda(t, "text/plain", []string{"hello ", "world"}, "{}", nil)
+	defer lambdaAPI.Close()
+	os.Setenv("LAMBDA_ENDPOINT", lambdaAPI.URL)
+	defer os.Unsetenv("LAMBDA_ENDPOINT")
+
+	c, err := getLambdaClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &route{Path: "/chat", Function: "chat", Streaming: true}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	c.invokeRoute(rr, httptest.NewRequest("GET", "/chat", nil), rt, nil)
+	if rr.Code != 200 || rr.Header().Get("Content-Type") != "text/plain" || rr.Body.String() != "hello world" || !rr.Flushed {
+		t.Errorf("unexpected response %v %v %q", rr.Code, rr.Header(), rr.Body)
+	}
+}
+
+func TestStreamFailsMidBody(t *testing.T) {
+	lambdaAPI := streamingLambda(t, "text/event-stream", []string{"data: one\n\n"}, `{"ErrorCode":"Unhandled","ErrorDetails":"out of tokens"}`, nil)
+	defer lambdaAPI.Close()
+	os.Setenv("LAMBDA_ENDPOINT", lambdaAPI.URL)
+	defer os.Unsetenv("LAMBDA_ENDPOINT")
+
+	c, err := getLambdaClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &route{Path: "/chat", Function: "chat", Streaming: true}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.invokeRoute(w, r, rt, nil)
+	}))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL + "/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "data: one\n\n" {
+		t.Errorf("unexpected response %v %q", resp.StatusCode, body)
+	}
+	if err == nil {
+		t.Error("expected the connection to be reset rather than the body to end cleanly")
+	}
+}
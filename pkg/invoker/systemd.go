@@ -0,0 +1,75 @@
+package invoker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The first file descriptor systemd passes, after stdin, stdout and stderr.
+const listenFDsStart = 3
+
+// Take the sockets systemd passed for socket activation, as described by
+// LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES, and clear those so anything
+// started from here doesn't take them too. Returns nil without any, or when
+// they were meant for another process. HTTPS sockets need tlsConfig.
+func activatedListeners(tlsConfig *tls.Config) ([]net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	fds, names := os.Getenv("LISTEN_FDS"), strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	count, err := strconv.Atoi(fds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q: %v", fds, err)
+	}
+
+	var listeners []net.Listener
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%v", listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		// FileListener takes a copy, so the original can be closed.
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("invalid socket %v from systemd: %v", name, err)
+		}
+		if tlsConfig != nil {
+			ln = tls.NewListener(ln, tlsConfig)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// Tell systemd about a change of state, such as READY=1 once listening, when
+// it runs the proxy as a Type=notify service. Does nothing without
+// NOTIFY_SOCKET.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Names starting with @ are in the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,58 @@
+package invoker
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSdNotify(t *testing.T) {
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("expected nothing to happen without NOTIFY_SOCKET, got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Errorf("expected READY=1, got %q %v", buf[:n], err)
+	}
+}
+
+func TestActivatedListeners(t *testing.T) {
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	if listeners, err := activatedListeners(nil); listeners != nil || err != nil {
+		t.Errorf("expected sockets for another process to be ignored, got %v %v", listeners, err)
+	}
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "many")
+	if _, err := activatedListeners(nil); err == nil {
+		t.Error("expected an error for an invalid LISTEN_FDS")
+	}
+	if os.Getenv("LISTEN_PID") != "" {
+		t.Error("expected LISTEN_PID to be cleared")
+	}
+}
@@ -0,0 +1,93 @@
+package invoker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The figures Lambda reports at the end of every invocation's logs, in a line
+// such as "REPORT RequestId: ...	Duration: 12.34 ms	Billed Duration: 13 ms
+// Memory Size: 128 MB	Max Memory Used: 45 MB	Init Duration: 150.01 ms".
+type invocationReport struct {
+	Duration       time.Duration
+	BilledDuration time.Duration
+	// Only set when the invocation had to start a new execution environment.
+	InitDuration  time.Duration
+	MemorySize    int
+	MaxMemoryUsed int
+}
+
+var reportField = regexp.MustCompile(`(Init Duration|Billed Duration|Duration|Memory Size|Max Memory Used): ([0-9.]+) (ms|MB)`)
+
+// Whether the invocation was a cold start.
+func (report *invocationReport) ColdStart() bool {
+	return report.InitDuration > 0
+}
+
+// Find the REPORT line in the base64 encoded tail of an invocation's logs
+// that LAMBDA_TAIL_LOGS asks Lambda for. Returns nil without one, as when the
+// logs were cut off before it.
+func parseReport(logResult *string) *invocationReport {
+	if logResult == nil {
+		return nil
+	}
+	logs, err := base64.StdEncoding.DecodeString(*logResult)
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(logs), "\n") {
+		if !strings.HasPrefix(line, "REPORT ") {
+			continue
+		}
+		report := &invocationReport{}
+		for _, field := range reportField.FindAllStringSubmatch(line, -1) {
+			value, err := strconv.ParseFloat(field[2], 64)
+			if err != nil {
+				continue
+			}
+			milliseconds := time.Duration(value * float64(time.Millisecond))
+			switch field[1] {
+			case "Duration":
+				report.Duration = milliseconds
+			case "Billed Duration":
+				report.BilledDuration = milliseconds
+			case "Init Duration":
+				report.InitDuration = milliseconds
+			case "Memory Size":
+				report.MemorySize = int(value)
+			case "Max Memory Used":
+				report.MaxMemoryUsed = int(value)
+			}
+		}
+		return report
+	}
+	return nil
+}
+
+// Pass the report on in response headers, and in the request's log fields and
+// summary for metrics.
+func setReport(w http.ResponseWriter, r *http.Request, report *invocationReport, fields logFields) {
+	milliseconds := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', -1, 64)
+	}
+	w.Header().Set("X-Lambda-Duration", milliseconds(report.Duration))
+	w.Header().Set("X-Lambda-Billed-Duration", milliseconds(report.BilledDuration))
+	w.Header().Set("X-Lambda-Memory-Size", strconv.Itoa(report.MemorySize))
+	w.Header().Set("X-Lambda-Max-Memory-Used", strconv.Itoa(report.MaxMemoryUsed))
+	w.Header().Set("X-Lambda-Cold-Start", fmt.Sprint(report.ColdStart()))
+	if report.ColdStart() {
+		w.Header().Set("X-Lambda-Init-Duration", milliseconds(report.InitDuration))
+		fields["init_duration_ms"] = float64(report.InitDuration.Microseconds()) / 1000
+	}
+	fields["cold_start"] = report.ColdStart()
+	fields["duration_ms"] = float64(report.Duration.Microseconds()) / 1000
+	fields["max_memory_used_mb"] = report.MaxMemoryUsed
+	if summary, ok := r.Context().Value(requestSummaryKey{}).(*requestSummary); ok {
+		summary.Report = report
+	}
+}
@@ -0,0 +1,122 @@
+package invoker
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+const coldStartLogs = "START RequestId: 1 Version: $LATEST\nhello\nEND RequestId: 1\n" +
+	"REPORT RequestId: 1\tDuration: 12.34 ms\tBilled Duration: 13 ms\tMemory Size: 128 MB\tMax Memory Used: 45 MB\tInit Duration: 150.5 ms\t\n"
+
+// Returns logs for invocations that ask for them.
+type tailLambdaClient struct {
+	lambdaiface.LambdaAPI
+	logs string
+}
+
+func (m tailLambdaClient) InvokeWithContext(_ aws.Context, in *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	out := &lambda.InvokeOutput{Payload: []byte(`{"statusCode":200,"body":"ok"}`)}
+	if aws.StringValue(in.LogType) == lambda.LogTypeTail {
+		out.LogResult = aws.String(base64.StdEncoding.EncodeToString([]byte(m.logs)))
+	}
+	return out, nil
+}
+
+func TestParseReport(t *testing.T) {
+	encode := func(logs string) *string {
+		return aws.String(base64.StdEncoding.EncodeToString([]byte(logs)))
+	}
+	report := parseReport(encode(coldStartLogs))
+	if report == nil {
+		t.Fatal("expected a report")
+	}
+	expected := invocationReport{12340 * time.Microsecond, 13 * time.Millisecond, 150500 * time.Microsecond, 128, 45}
+	if *report != expected || !report.ColdStart() {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	warm := parseReport(encode("REPORT RequestId: 2  Duration: 1.5 ms  Billed Duration: 2 ms  Memory Size: 3008 MB  Max Memory Used: 3008 MB"))
+	if warm == nil || warm.ColdStart() || warm.BilledDuration != 2*time.Millisecond || warm.MaxMemoryUsed != 3008 {
+		t.Errorf("unexpected report %+v", warm)
+	}
+
+	for _, logs := range []*string{nil, encode("START RequestId: 3\nhello"), aws.String("not base64!")} {
+		if report := parseReport(logs); report != nil {
+			t.Errorf("expected no report, got %+v", report)
+		}
+	}
+}
+
+func TestTailLogs(t *testing.T) {
+	c := LambdaClient{tailLambdaClient{logs: coldStartLogs}}
+	rr := httptest.NewRecorder()
+	c.invokeLambda(rr, httptest.NewRequest("GET", "/", nil))
+	if duration := rr.Header().Get("X-Lambda-Duration"); duration != "" {
+		t.Errorf("expected no report without LAMBDA_TAIL_LOGS, got a duration of %v", duration)
+	}
+
+	os.Setenv("LAMBDA_TAIL_LOGS", "true")
+	defer os.Unsetenv("LAMBDA_TAIL_LOGS")
+	rr = httptest.NewRecorder()
+	var summary *requestSummary
+	observeRequests(http.HandlerFunc(c.invokeLambda), func(s *requestSummary) {
+		summary = s
+	}).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	for header, want := range map[string]string{
+		"X-Lambda-Duration":        "12.34",
+		"X-Lambda-Billed-Duration": "13",
+		"X-Lambda-Init-Duration":   "150.5",
+		"X-Lambda-Memory-Size":     "128",
+		"X-Lambda-Max-Memory-Used": "45",
+		"X-Lambda-Cold-Start":      "true",
+	} {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("unexpected %v: got %q want %q", header, got, want)
+		}
+	}
+	if rr.Body.String() != "ok" {
+		t.Errorf("unexpected body %q", rr.Body.String())
+	}
+	if summary == nil || summary.Report == nil || summary.Report.MaxMemoryUsed != 45 {
+		t.Errorf("expected the report in the request summary, got %+v", summary)
+	}
+}
+
+func TestStatsdReportMetrics(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	c, err := newStatsdClient(server.LocalAddr().String(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.observe(&requestSummary{Method: "GET", Function: "users", Status: 200, Latency: time.Millisecond,
+		Report: &invocationReport{Duration: 500 * time.Microsecond, InitDuration: 100 * time.Millisecond, MaxMemoryUsed: 64}})
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := string(buf[:n])
+	for _, want := range []string{"\nduration:0.5|ms|#", "\nmax_memory_used:64|h|#", "\ncold_starts:1|c|#", "\ninit_duration:100|ms|#"} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("expected %q in metrics:\n%v", want, packet)
+		}
+	}
+}
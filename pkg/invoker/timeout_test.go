@@ -0,0 +1,60 @@
+package invoker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+type slowLambdaClient struct {
+	lambdaiface.LambdaAPI
+}
+
+// Never returns before the caller gives up.
+func (slowLambdaClient) InvokeWithContext(ctx aws.Context, _ *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestIntegrationTimeout(t *testing.T) {
+	os.Setenv("INTEGRATION_TIMEOUT", "10ms")
+	defer os.Unsetenv("INTEGRATION_TIMEOUT")
+
+	rr := httptest.NewRecorder()
+	l := LambdaClient{slowLambdaClient{}}
+	l.invokeLambda(rr, httptest.NewRequest("GET", "/slow", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusGatewayTimeout)
+	}
+	if b := rr.Body.String(); b != `{"message":"Endpoint request timed out"}` {
+		t.Errorf("handler returned unexpected body: got %v", b)
+	}
+}
+
+func TestClientDisconnectCancelsInvoke(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/slow", nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		l := LambdaClient{slowLambdaClient{}}
+		l.invokeLambda(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("invocation was not cancelled when the client disconnected")
+	}
+}
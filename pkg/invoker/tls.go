@@ -0,0 +1,168 @@
+package invoker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// TLS settings for HTTPS listeners. TLS_CERT_FILE and TLS_KEY_FILE are used
+// when set. Otherwise, if HTTPS is needed, a self-signed certificate is made
+// up for the names in TLS_SANS. Returns nil when HTTPS isn't needed at all.
+func loadTLSConfig(needed bool) (*tls.Config, error) {
+	certFile, keyFile := getConfig("TLS_CERT_FILE"), getConfig("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" && !needed {
+		return nil, nil
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	var cert tls.Certificate
+	var err error
+	if certFile != "" {
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	} else {
+		cert, err = selfSignedCertificate(tlsNames())
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// Offer HTTP/2 as CloudFront and API Gateway do.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if err := configureClientAuth(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Ask clients for certificates, as an API Gateway custom domain with mutual
+// TLS does. TLS_CLIENT_AUTH is none, request or require, and defaults to
+// require once TLS_CLIENT_CA_FILE is set. Certificates are verified against
+// TLS_CLIENT_CA_FILE when given.
+func configureClientAuth(cfg *tls.Config) error {
+	caFile := getConfig("TLS_CLIENT_CA_FILE")
+	mode := clientAuthMode()
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in TLS_CLIENT_CA_FILE %v", caFile)
+		}
+	}
+
+	switch {
+	case mode == "" || mode == "none":
+		cfg.ClientAuth = tls.NoClientCert
+	case mode == "request" && caFile != "":
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case mode == "request":
+		cfg.ClientAuth = tls.RequestClientCert
+	case mode == "require" && caFile != "":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case mode == "require":
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	default:
+		return fmt.Errorf("invalid TLS_CLIENT_AUTH %q: expected none, request or require", mode)
+	}
+	return nil
+}
+
+// TLS_CLIENT_AUTH, which defaults to require when there's a
+// TLS_CLIENT_CA_FILE.
+func clientAuthMode() string {
+	mode := getConfig("TLS_CLIENT_AUTH")
+	if mode == "" && getConfig("TLS_CLIENT_CA_FILE") != "" {
+		mode = "require"
+	}
+	return mode
+}
+
+// Whether the default listener on HOST and PORT should serve HTTPS.
+func tlsEnabled() (bool, error) {
+	if getConfig("TLS_CERT_FILE") != "" || getConfig("TLS_KEY_FILE") != "" {
+		return true, nil
+	}
+	return getConfigBool("TLS")
+}
+
+// Names for the self-signed certificate from TLS_SANS.
+func tlsNames() []string {
+	var names []string
+	for _, name := range strings.Split(getConfig("TLS_SANS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Make a certificate valid for a year for the given host names and IPs, so
+// HTTPS works locally without any openssl incantations. Browsers will still
+// warn about it unless it is trusted.
+func selfSignedCertificate(names []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"http-lambda-invoker"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+	if len(names) > 0 {
+		template.Subject.CommonName = names[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	logInfo("Generated self-signed certificate", logFields{"names": strings.Join(names, ", "), "sha256": fmt.Sprintf("%X", sha256.Sum256(der))})
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// Whether any of the listeners serve HTTPS.
+func needsTLS(specs []listenerSpec) bool {
+	for _, spec := range specs {
+		if spec.TLS {
+			return true
+		}
+	}
+	return false
+}
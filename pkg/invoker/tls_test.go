@@ -0,0 +1,238 @@
+package invoker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Write a throwaway certificate and key for 127.0.0.1 into dir.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	if cfg, err := loadTLSConfig(false); cfg != nil || err != nil {
+		t.Errorf("expected no TLS without certificate settings, got %v %v", cfg, err)
+	}
+
+	os.Setenv("TLS_CERT_FILE", "cert.pem")
+	if _, err := loadTLSConfig(false); err == nil {
+		t.Error("expected an error with only TLS_CERT_FILE set")
+	}
+	os.Unsetenv("TLS_CERT_FILE")
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	os.Setenv("TLS_SANS", "localhost, api, 127.0.0.1")
+	defer os.Unsetenv("TLS_SANS")
+
+	cfg, err := loadTLSConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"localhost", "api", "127.0.0.1"} {
+		if err := cert.VerifyHostname(name); err != nil {
+			t.Errorf("certificate not valid for %v: %v", name, err)
+		}
+	}
+	if err := cert.VerifyHostname("example.com"); err == nil {
+		t.Error("certificate should not be valid for example.com")
+	}
+}
+
+func TestHTTPSListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCertificate(t, dir)
+	os.Setenv("TLS_CERT_FILE", certFile)
+	os.Setenv("TLS_KEY_FILE", keyFile)
+	defer os.Unsetenv("TLS_CERT_FILE")
+	defer os.Unsetenv("TLS_KEY_FILE")
+
+	tlsConfig, err := loadTLSConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners, err := openListeners([]listenerSpec{{"tcp", "127.0.0.1:0", true}}, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(healthHandler)}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(srv, listeners, time.Second, stop)
+	}()
+
+	addr := listeners[0].Addr().String()
+	if err := healthcheck("https://" + addr); err != nil {
+		t.Errorf("HTTPS listener: %v", err)
+	}
+	if err := healthcheck("http://" + addr); err == nil {
+		t.Error("expected plain HTTP to fail against an HTTPS listener")
+	}
+
+	stop <- syscall.SIGTERM
+	if err := <-served; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestHealthcheckWithClientCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCertificate(t, dir)
+	os.Setenv("TLS_CERT_FILE", certFile)
+	os.Setenv("TLS_KEY_FILE", keyFile)
+	os.Setenv("TLS_CLIENT_AUTH", "require")
+	defer os.Unsetenv("TLS_CERT_FILE")
+	defer os.Unsetenv("TLS_KEY_FILE")
+	defer os.Unsetenv("TLS_CLIENT_AUTH")
+
+	tlsConfig, err := loadTLSConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs := []listenerSpec{{"tcp", "127.0.0.1:0", true}}
+	listeners, err := openListeners(specs, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(healthHandler)}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(srv, listeners, time.Second, stop)
+	}()
+	admin := httptest.NewServer(newAdminMux(false, nil))
+	defer admin.Close()
+
+	specs[0].Address = listeners[0].Addr().String()
+	if err := healthcheck("https://" + specs[0].Address); err == nil {
+		t.Error("expected the health check to fail without a client certificate")
+	}
+	if _, err := healthcheckURL(specs, "", clientAuthMode() == "require"); err == nil {
+		t.Error("expected an error with only listeners requiring client certificates")
+	}
+	url, err := healthcheckURL(specs, admin.Listener.Addr().String(), clientAuthMode() == "require")
+	if err != nil || url != admin.URL {
+		t.Fatalf("expected the admin address, got %v %v", url, err)
+	}
+	if err := healthcheck(url); err != nil {
+		t.Errorf("expected a healthy proxy through the admin address, got %v", err)
+	}
+
+	stop <- syscall.SIGTERM
+	if err := <-served; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	tlsConfig, err := loadTLSConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners, err := openListeners([]listenerSpec{{"tcp", "127.0.0.1:0", true}, {"tcp", "127.0.0.1:0", false}}, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: allowH2C(http.HandlerFunc(healthHandler))}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(srv, listeners, time.Second, stop)
+	}()
+
+	// HTTP/2 negotiated over TLS.
+	client := http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + listeners[0].Addr().String() + healthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2 over TLS, got %v", resp.Proto)
+	}
+
+	// HTTP/2 with prior knowledge over plain TCP.
+	h2cClient := http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err = h2cClient.Get("http://" + listeners[1].Addr().String() + healthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected h2c, got %v", resp.Proto)
+	}
+
+	stop <- syscall.SIGTERM
+	if err := <-served; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
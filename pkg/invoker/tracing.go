@@ -0,0 +1,304 @@
+package invoker
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenTelemetry span kinds.
+const (
+	spanKindServer = 2
+	spanKindClient = 3
+)
+
+// A finished or in-progress OpenTelemetry span.
+type span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	failed     bool
+
+	exporter *spanExporter
+}
+
+type spanKey struct{}
+
+func newSpanID() (id [8]byte) {
+	copy(id[:], randomBytes(8))
+	return id
+}
+
+// Start a span as a child of the one in ctx, if the request is being traced.
+func startSpan(ctx context.Context, name string, kind int) *span {
+	parent, ok := ctx.Value(spanKey{}).(*span)
+	if !ok {
+		return nil
+	}
+	return &span{
+		traceID:    parent.traceID,
+		spanID:     newSpanID(),
+		parentID:   parent.spanID,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+		exporter:   parent.exporter,
+	}
+}
+
+// Spans are nil when tracing is off, so every method allows for that.
+func (s *span) set(key string, value interface{}) {
+	if s != nil {
+		s.attributes[key] = value
+	}
+}
+
+func (s *span) fail() {
+	if s != nil {
+		s.failed = true
+	}
+}
+
+func (s *span) finish() {
+	if s != nil {
+		s.end = time.Now()
+		s.exporter.export(s)
+	}
+}
+
+// The W3C traceparent header for the span.
+func (s *span) traceparent() string {
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
+
+// Continue the trace in an incoming W3C traceparent header, if there is one.
+func parseTraceparent(header string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return traceID, parentID, false
+	}
+	return traceID, parentID, traceID != [16]byte{}
+}
+
+// Trace every request to next with a server span exported through exporter,
+// continuing the client's trace when it sends a traceparent header. The
+// function gets a traceparent header pointing at the server span so its own
+// instrumentation joins the trace. A nil exporter turns tracing off.
+func traceRequests(exporter *spanExporter, next http.Handler) http.Handler {
+	if exporter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := &span{
+			spanID:     newSpanID(),
+			name:       r.Method,
+			kind:       spanKindServer,
+			start:      time.Now(),
+			attributes: map[string]interface{}{"http.method": r.Method, "http.target": r.URL.RequestURI()},
+			exporter:   exporter,
+		}
+		var ok bool
+		if s.traceID, s.parentID, ok = parseTraceparent(r.Header.Get("traceparent")); !ok {
+			copy(s.traceID[:], randomBytes(16))
+		}
+		r.Header.Set("traceparent", s.traceparent())
+
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			// A handler that panics, as chaos does to drop a connection,
+			// still gets its span, as a failure, before the panic goes on
+			// to the server.
+			if err := recover(); err != nil {
+				status := sw.status
+				if status == 0 {
+					status = statusConnectionDropped
+				}
+				s.set("http.status_code", status)
+				s.fail()
+				s.finish()
+				panic(err)
+			}
+		}()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), spanKey{}, s)))
+		if sw.status != 0 {
+			s.set("http.status_code", sw.status)
+		}
+		s.failed = sw.status >= 500
+		s.finish()
+	})
+}
+
+// Name the request's server span after the route it matched, as OpenTelemetry
+// recommends, and note the function it invokes.
+func traceRoute(r *http.Request, rt *route, function string) {
+	s, ok := r.Context().Value(spanKey{}).(*span)
+	if !ok {
+		return
+	}
+	if rt != nil {
+		s.name = r.Method + " " + rt.Path
+		s.set("http.route", rt.Path)
+	}
+	s.set("faas.invoked_name", function)
+}
+
+// Sends finished spans to an OTLP/HTTP collector as JSON, in batches.
+type spanExporter struct {
+	url     string
+	service string
+	client  *http.Client
+	spans   chan *span
+	done    chan struct{}
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newSpanExporter(endpoint string, service string) *spanExporter {
+	return &spanExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		spans:   make(chan *span, 2048),
+		done:    make(chan struct{}),
+	}
+}
+
+// Queue a span, dropping it rather than slowing requests down if the
+// collector can't keep up.
+func (e *spanExporter) export(s *span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	select {
+	case e.spans <- s:
+	default:
+	}
+}
+
+// Send queued spans every interval until stop is called.
+func (e *spanExporter) run(interval time.Duration) {
+	defer close(e.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch []*span
+	for {
+		select {
+		case s, ok := <-e.spans:
+			if !ok {
+				e.send(batch)
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) < 512 {
+				continue
+			}
+		case <-ticker.C:
+		}
+		e.send(batch)
+		batch = nil
+	}
+}
+
+// Flush any queued spans and stop exporting.
+func (e *spanExporter) stop() {
+	e.mu.Lock()
+	if !e.stopped {
+		e.stopped = true
+		close(e.spans)
+	}
+	e.mu.Unlock()
+	<-e.done
+}
+
+func (e *spanExporter) send(batch []*span) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(otlpRequest(e.service, batch))
+	if err != nil {
+		logError("Failed to encode spans", logFields{"error": err})
+		return
+	}
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logWarn("Failed to export spans", logFields{"error": err, "spans": len(batch)})
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		logWarn("Failed to export spans", logFields{"status": res.StatusCode, "spans": len(batch)})
+	}
+}
+
+// The OTLP/HTTP JSON encoding of an ExportTraceServiceRequest.
+func otlpRequest(service string, batch []*span) map[string]interface{} {
+	spans := make([]map[string]interface{}, len(batch))
+	for i, s := range batch {
+		encoded := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parentID != [8]byte{} {
+			encoded["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.failed {
+			encoded["status"] = map[string]interface{}{"code": 2}
+		}
+		spans[i] = encoded
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "http-lambda-invoker", "version": version},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	encoded := make([]interface{}, 0, len(attributes))
+	for key, value := range attributes {
+		var v map[string]interface{}
+		switch value := value.(type) {
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": v})
+	}
+	return encoded
+}
@@ -0,0 +1,86 @@
+package invoker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+type otlpSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+}
+
+func TestTraceRequests(t *testing.T) {
+	var mu sync.Mutex
+	var spans []otlpSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected collector path %v", r.URL.Path)
+		}
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	rt := &route{Path: "/users/{id}"}
+	if err := rt.compile(); err != nil {
+		t.Fatal(err)
+	}
+	setRoutes(routeTable{rt})
+	defer setRoutes(nil)
+
+	var traceparent string
+	exporter := newSpanExporter(collector.URL, "test")
+	go exporter.run(time.Hour)
+	l := LambdaClient{mockLambdaClient{Resp: lambda.InvokeOutput{Payload: []byte(`{"statusCode":200}`)}}}
+	h := traceRequests(exporter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		l.invokeLambda(w, r)
+	}))
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	exporter.stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(spans) != 2 {
+		t.Fatalf("expected a server and a client span, got %+v", spans)
+	}
+	invoke, server := spans[0], spans[1]
+	if server.Name != "GET /users/{id}" || server.Kind != spanKindServer || server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("unexpected server span %+v", server)
+	}
+	if invoke.Kind != spanKindClient || invoke.TraceID != server.TraceID || invoke.ParentSpanID != server.SpanID {
+		t.Errorf("unexpected invoke span %+v", invoke)
+	}
+	if traceparent != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+server.SpanID+"-01" {
+		t.Errorf("expected the function to get the server span as its parent, got %q", traceparent)
+	}
+}
@@ -0,0 +1,107 @@
+package invoker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Check settings that are otherwise only read when a request comes in, so
+// mistakes stop the proxy at startup instead of turning every request into a 400.
+func validateConfig() error {
+	if getConfig("LAMBDA_NAME") == "" && lambdaNameRequired() {
+		return errors.New("LAMBDA_NAME must be set")
+	}
+	if fallback := getConfig("REPLAY_FALLBACK"); fallback != "error" && fallback != "invoke" {
+		return fmt.Errorf("invalid REPLAY_FALLBACK %q: must be error or invoke", fallback)
+	}
+	if err := checkPayloadFormatVersion(getConfig("PAYLOAD_FORMAT_VERSION")); err != nil {
+		return fmt.Errorf("invalid PAYLOAD_FORMAT_VERSION: %v", err)
+	}
+	if err := checkRequestEncoding(getConfig("REQUEST_ENCODING")); err != nil {
+		return fmt.Errorf("invalid REQUEST_ENCODING: %v", err)
+	}
+	if fallback := getConfig("FALLBACK_URL"); fallback != "" {
+		if _, err := parseFallbackURL(fallback); err != nil {
+			return err
+		}
+	}
+	if depth, err := getConfigInt("INVOKE_QUEUE_DEPTH"); err == nil && depth < 0 {
+		return fmt.Errorf("invalid INVOKE_QUEUE_DEPTH %v: must not be negative", depth)
+	}
+	if _, err := loadHeaderRules(); err != nil {
+		return err
+	}
+	return checkSettings()
+}
+
+// LAMBDA_NAME can be left out when no request would go to it: when they're
+// all replayed, or every route names its function or state machine, and
+// either there are routes, APIs with routes of their own, they will be
+// discovered, FALLBACK_URL takes the rest or GraphQL resolvers name theirs.
+func lambdaNameRequired() bool {
+	if replayOnly() {
+		return false
+	}
+	table := currentRoutes()
+	for _, rt := range table {
+		if rt.Function == "" && rt.StateMachineArn == "" {
+			return true
+		}
+	}
+	if cfg := currentConfigFile(); cfg != nil && len(cfg.APIs) > 0 {
+		return false
+	}
+	if getConfig("FALLBACK_URL") != "" {
+		return false
+	}
+	if getConfig("GRAPHQL_PATH") != "" {
+		if cfg := currentConfigFile(); cfg != nil {
+			for _, res := range cfg.Resolvers {
+				if res.Function == "" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return len(table) == 0 && getConfig("DISCOVER_INTERVAL") == ""
+}
+
+// Make sure the function can be found at LAMBDA_ENDPOINT.
+func (c *LambdaClient) checkFunction(ctx context.Context, name string) error {
+	_, err := c.GetFunctionWithContext(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(name)})
+	if err == nil {
+		return nil
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		if reqErr.Code() == lambda.ErrCodeResourceNotFoundException {
+			return fmt.Errorf("function %v was not found at %v", name, getConfig("LAMBDA_ENDPOINT"))
+		}
+		// The endpoint answered, it just doesn't implement GetFunction.
+		// lambci only supports Invoke, for example.
+		return nil
+	}
+	return fmt.Errorf("could not reach %v: %v", getConfig("LAMBDA_ENDPOINT"), err)
+}
+
+// Keep checking the function until it is reachable or wait runs out, which
+// helps when docker-compose starts the proxy before the function container.
+func (c *LambdaClient) waitForFunction(name string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := c.checkFunction(ctx, name)
+		cancel()
+		if err == nil || !time.Now().Before(deadline) {
+			return err
+		}
+		logInfo("Waiting for function", logFields{"function": name, "error": err})
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,76 @@
+package invoker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+type getFunctionClient struct {
+	lambdaiface.LambdaAPI
+	Err error
+}
+
+func (m getFunctionClient) GetFunctionWithContext(aws.Context, *lambda.GetFunctionInput, ...request.Option) (*lambda.GetFunctionOutput, error) {
+	return &lambda.GetFunctionOutput{}, m.Err
+}
+
+func TestValidateConfig(t *testing.T) {
+	if err := validateConfig(); err == nil {
+		t.Error("expected an error when LAMBDA_NAME is not set")
+	}
+
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	if err := validateConfig(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	os.Setenv("INTEGRATION_TIMEOUT", "a while")
+	defer os.Unsetenv("INTEGRATION_TIMEOUT")
+	if err := validateConfig(); err == nil {
+		t.Error("expected an error for an invalid INTEGRATION_TIMEOUT")
+	}
+}
+
+func TestValidateQueueDepth(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	os.Setenv("INVOKE_QUEUE_DEPTH", "-1")
+	defer os.Unsetenv("INVOKE_QUEUE_DEPTH")
+	if err := validateConfig(); err == nil {
+		t.Error("expected an error for a negative INVOKE_QUEUE_DEPTH")
+	}
+	os.Setenv("INVOKE_QUEUE_DEPTH", "0")
+	if err := validateConfig(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckFunction(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		ok   bool
+	}{
+		{"found", nil, true},
+		{"not found", awserr.NewRequestFailure(awserr.New(lambda.ErrCodeResourceNotFoundException, "Function not found", nil), 404, ""), false},
+		{"unsupported", awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, ""), true},
+		{"unreachable", awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("connection refused")), false},
+	}
+
+	for _, test := range tests {
+		l := LambdaClient{getFunctionClient{Err: test.err}}
+		err := l.checkFunction(context.Background(), "MyFunction")
+		if (err == nil) != test.ok {
+			t.Errorf("%v: unexpected result %v", test.name, err)
+		}
+	}
+}
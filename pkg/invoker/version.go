@@ -0,0 +1,33 @@
+package invoker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Set at build time, for example:
+//
+//	pkg=github.com/elthrasher/http-lambda-invoker/pkg/invoker
+//	go build -ldflags "-X $pkg.version=v1.2.0 -X $pkg.commit=$(git rev-parse --short HEAD) -X $pkg.date=$(date -u +%FT%TZ)"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// Served by the proxy itself rather than passed to the function.
+const versionPath = "/__invoker/version"
+
+func versionString() string {
+	return fmt.Sprintf("http-lambda-invoker %v (commit %v, built %v)", version, commit, date)
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Version string `json:"version"`
+		Commit  string `json:"commit"`
+		Date    string `json:"date"`
+	}{version, commit, date})
+}
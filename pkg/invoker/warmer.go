@@ -0,0 +1,57 @@
+package invoker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Sent by the warmer so functions can recognise it and return early.
+var warmupPayload = []byte(`{"source":"http-lambda-invoker.warmer","warmup":true}`)
+
+// Functions to keep warm from WARM_FUNCTIONS, falling back to LAMBDA_NAME.
+func warmFunctions() []string {
+	var functions []string
+	for _, name := range strings.Split(getConfig("WARM_FUNCTIONS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			functions = append(functions, name)
+		}
+	}
+	if len(functions) == 0 && getConfig("LAMBDA_NAME") != "" {
+		functions = append(functions, getConfig("LAMBDA_NAME"))
+	}
+	return functions
+}
+
+// Invoke each function once with the warm-up payload.
+func (c *LambdaClient) warm(ctx context.Context, functions []string) {
+	for _, name := range functions {
+		_, err := c.InvokeWithContext(ctx, &lambda.InvokeInput{FunctionName: aws.String(name), Payload: warmupPayload})
+		if err != nil && ctx.Err() == nil {
+			logWarn("Failed to warm function", logFields{"function": name, "error": err})
+		}
+	}
+}
+
+// Keep functions warm every interval until ctx is done, so the first real
+// request after a lull doesn't pay for a cold start.
+func runWarmer(ctx context.Context, interval time.Duration, functions []string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c, err := getLambdaClient()
+		if err != nil {
+			logWarn("Failed to warm functions", logFields{"error": err})
+		} else {
+			c.warm(ctx, functions)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
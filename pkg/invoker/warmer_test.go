@@ -0,0 +1,57 @@
+package invoker
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+type recordingLambdaClient struct {
+	lambdaiface.LambdaAPI
+	Inputs []*lambda.InvokeInput
+}
+
+func (m *recordingLambdaClient) InvokeWithContext(_ aws.Context, in *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	m.Inputs = append(m.Inputs, in)
+	return &lambda.InvokeOutput{}, nil
+}
+
+func TestWarmFunctions(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+
+	if functions := warmFunctions(); !reflect.DeepEqual(functions, []string{"MyFunction"}) {
+		t.Errorf("unexpected functions to warm: got %v want [MyFunction]", functions)
+	}
+
+	os.Setenv("WARM_FUNCTIONS", "users, orders,")
+	defer os.Unsetenv("WARM_FUNCTIONS")
+
+	if functions := warmFunctions(); !reflect.DeepEqual(functions, []string{"users", "orders"}) {
+		t.Errorf("unexpected functions to warm: got %v want [users orders]", functions)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	m := &recordingLambdaClient{}
+	l := LambdaClient{m}
+	l.warm(context.Background(), []string{"users", "orders"})
+
+	if len(m.Inputs) != 2 {
+		t.Fatalf("expected 2 warm-up invocations, got %v", len(m.Inputs))
+	}
+	for i, name := range []string{"users", "orders"} {
+		if got := aws.StringValue(m.Inputs[i].FunctionName); got != name {
+			t.Errorf("warmed unexpected function: got %v want %v", got, name)
+		}
+		if string(m.Inputs[i].Payload) != string(warmupPayload) {
+			t.Errorf("warmed with unexpected payload: %s", m.Inputs[i].Payload)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package invoker
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const traceHeaderName = "X-Amzn-Trace-Id"
+
+// The X-Ray trace header for a request. A header with a Root sent by the
+// client is kept, so the function joins the caller's trace. Otherwise a new
+// unsampled trace is started, the way API Gateway starts one when tracing is
+// off, which keeps the X-Ray SDK happy without a daemon to send segments to.
+func traceHeader(r *http.Request) string {
+	header := r.Header.Get(traceHeaderName)
+	for _, part := range strings.Split(header, ";") {
+		if strings.HasPrefix(strings.TrimSpace(part), "Root=") {
+			return header
+		}
+	}
+	return fmt.Sprintf("Root=%v;Parent=%x;Sampled=0", newTraceID(), randomBytes(8))
+}
+
+// An X-Ray trace ID: a version, the time in seconds and 96 random bits.
+func newTraceID() string {
+	return fmt.Sprintf("1-%08x-%x", time.Now().Unix(), randomBytes(12))
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
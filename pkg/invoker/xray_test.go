@@ -0,0 +1,49 @@
+package invoker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestTraceHeader(t *testing.T) {
+	generated := regexp.MustCompile(`^Root=1-[0-9a-f]{8}-[0-9a-f]{24};Parent=[0-9a-f]{16};Sampled=0$`)
+	if trace := traceHeader(httptest.NewRequest("GET", "/", nil)); !generated.MatchString(trace) {
+		t.Errorf("unexpected generated trace header %q", trace)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+	if trace := traceHeader(req); trace != "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1" {
+		t.Errorf("expected the client's trace to be kept, got %q", trace)
+	}
+}
+
+func TestTraceHeaderReachesInvoke(t *testing.T) {
+	os.Setenv("LAMBDA_NAME", "MyFunction")
+	defer os.Unsetenv("LAMBDA_NAME")
+	var invokeTrace string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invokeTrace = r.Header.Get("X-Amzn-Trace-Id")
+		w.Write([]byte(`{"statusCode":200,"body":"ok"}`))
+	}))
+	defer endpoint.Close()
+
+	c, err := newLambdaClient(clientConfig{Credentials: "static", AccessKeyID: "foo", SecretAccessKey: "bar", Region: "us-east-1", Endpoint: endpoint.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	rr := httptest.NewRecorder()
+	c.invokeLambda(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", rr.Code, rr.Body)
+	}
+	if invokeTrace != "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1" {
+		t.Errorf("expected the trace header on the Invoke call, got %q", invokeTrace)
+	}
+}